@@ -0,0 +1,87 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+type authenticator interface {
+	// login runs before the first dial and again whenever the server answers 401
+	login(ctx context.Context, c *http.Client, server string) error
+	header() http.Header
+}
+
+// ErrTwoFactorRequired is returned by password login when the account has two-factor authentication enabled,
+// such accounts should connect with a device credential token instead.
+var ErrTwoFactorRequired = errors.New("two-factor authentication is required")
+
+type passwordAuth struct {
+	username string
+	password string
+}
+
+func (p *passwordAuth) login(ctx context.Context, c *http.Client, server string) error {
+	body, _ := json.Marshal(map[string]string{"username": p.username, "password": p.password})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server+"/api/v1/user/login", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		var reason struct {
+			Reason string `json:"reason"`
+		}
+		json.NewDecoder(res.Body).Decode(&reason)
+		return &StatusError{StatusCode: res.StatusCode, Reason: reason.Reason}
+	}
+
+	var result struct {
+		Result struct {
+			TwoFactorRequired bool `json:"two_factor_required"`
+		} `json:"result"`
+	}
+	json.NewDecoder(res.Body).Decode(&result)
+	if result.Result.TwoFactorRequired {
+		return ErrTwoFactorRequired
+	}
+	return nil
+}
+
+func (p *passwordAuth) header() http.Header {
+	return nil
+}
+
+type tokenAuth struct {
+	token string
+}
+
+func (t *tokenAuth) login(ctx context.Context, c *http.Client, server string) error {
+	return nil
+}
+
+func (t *tokenAuth) header() http.Header {
+	return http.Header{"Authorization": {"Bearer " + t.token}}
+}
+
+// StatusError is returned when the server refuses a request or a handshake.
+type StatusError struct {
+	StatusCode int
+	Reason     string
+}
+
+func (e *StatusError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("server responded %d", e.StatusCode)
+	}
+	return fmt.Sprintf("server responded %d: %s", e.StatusCode, e.Reason)
+}
@@ -0,0 +1,586 @@
+// Package client connects devices to the relay as a main or sub device.
+//
+// A Conn logs in, keeps the websocket alive with pings and reconnects with
+// jittered exponential backoff until Close is called or the server refuses
+// the device for good (for example the device is not bound).
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	ErrClosed       = errors.New("client: connection closed")
+	ErrNotConnected = errors.New("client: not connected")
+)
+
+type State int
+
+const (
+	StateConnecting State = iota
+	StateConnected
+	StateDisconnected
+	StateClosed
+)
+
+func (s State) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateDisconnected:
+		return "disconnected"
+	case StateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+type Config struct {
+	// Server is the http address of the relay, e.g. http://localhost:8085
+	Server   string
+	Username string
+	Password string
+	// Token is a device credential returned by bind or rotate, when set Username and Password are ignored.
+	Token string
+
+	// HeartbeatInterval defaults to 30s, a negative value disables pings.
+	HeartbeatInterval time.Duration
+	// MinBackoff and MaxBackoff bound the reconnect delay, default 500ms and 30s.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// WriteTimeout defaults to 10s.
+	WriteTimeout time.Duration
+
+	// OnStateChange is called from the connection goroutine, err is set when a connection was lost.
+	OnStateChange func(state State, err error)
+}
+
+func (c *Config) setDefaults() {
+	c.Server = strings.TrimRight(c.Server, "/")
+	if c.HeartbeatInterval == 0 {
+		c.HeartbeatInterval = 30 * time.Second
+	}
+	if c.MinBackoff <= 0 {
+		c.MinBackoff = 500 * time.Millisecond
+	}
+	if c.MaxBackoff < c.MinBackoff {
+		c.MaxBackoff = 30 * time.Second
+	}
+	if c.WriteTimeout <= 0 {
+		c.WriteTimeout = 10 * time.Second
+	}
+}
+
+type Conn struct {
+	config Config
+	auth   authenticator
+	http   *http.Client
+	url    string
+	dialer websocket.Dialer
+	// resumeToken lets the server give back the previous session, with its subscriptions and missed messages
+	resumeToken string
+	ws          *websocket.Conn
+	wsMu        sync.Mutex
+	writeMu     sync.Mutex
+
+	incoming  chan *Message
+	pending   map[string]chan *Frame
+	pendingMu sync.Mutex
+	topics    map[string]bool
+	topicsMu  sync.Mutex
+	seq       atomic.Uint64
+
+	closed    chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+// DialMain connects as the main device, the call fails if the first connection cannot be established.
+func DialMain(ctx context.Context, config Config, mainDeviceId uint64) (*Conn, error) {
+	query := url.Values{"main_device_id": {fmt.Sprint(mainDeviceId)}}
+	return dial(ctx, config, "/api/v1/communication/main", query)
+}
+
+// DialSub connects as a sub device of a main device that is already online.
+func DialSub(ctx context.Context, config Config, mainDeviceId uint64, subDeviceId uint64) (*Conn, error) {
+	query := url.Values{"main_device_id": {fmt.Sprint(mainDeviceId)}, "sub_device_id": {fmt.Sprint(subDeviceId)}}
+	return dial(ctx, config, "/api/v1/communication/sub", query)
+}
+
+func dial(ctx context.Context, config Config, path string, query url.Values) (*Conn, error) {
+	config.setDefaults()
+	u, err := url.Parse(config.Server + path)
+	if err != nil {
+		return nil, err
+	}
+	u.Scheme = strings.Replace(u.Scheme, "http", "ws", 1)
+	u.RawQuery = query.Encode()
+
+	jar, _ := cookiejar.New(nil)
+	c := &Conn{
+		config:   config,
+		auth:     newAuthenticator(config),
+		http:     &http.Client{Jar: jar, Timeout: 10 * time.Second},
+		url:      u.String(),
+		dialer:   websocket.Dialer{Jar: jar, HandshakeTimeout: 10 * time.Second},
+		incoming: make(chan *Message, 64),
+		pending:  make(map[string]chan *Frame),
+		topics:   make(map[string]bool),
+		closed:   make(chan struct{}),
+	}
+
+	c.notify(StateConnecting, nil)
+	if err := c.auth.login(ctx, c.http, config.Server); err != nil {
+		return nil, err
+	}
+	ws, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// set before returning, the caller may send right away
+	c.setConn(ws)
+	go c.run(ws)
+	return c, nil
+}
+
+func newAuthenticator(config Config) authenticator {
+	if config.Token != "" {
+		return &tokenAuth{token: config.Token}
+	}
+	return &passwordAuth{username: config.Username, password: config.Password}
+}
+
+func (c *Conn) notify(state State, err error) {
+	if c.config.OnStateChange != nil {
+		c.config.OnStateChange(state, err)
+	}
+}
+
+func (c *Conn) connect(ctx context.Context) (*websocket.Conn, error) {
+	for attempt := 0; ; attempt++ {
+		u := c.url
+		if c.resumeToken != "" {
+			u += "&" + url.Values{"resume_token": {c.resumeToken}}.Encode()
+		}
+		ws, res, err := c.dialer.DialContext(ctx, u, c.auth.header())
+		if err == nil {
+			c.resumeToken = res.Header.Get("X-Resume-Token")
+			return ws, nil
+		}
+		if res == nil {
+			return nil, err
+		}
+
+		statusErr := &StatusError{StatusCode: res.StatusCode}
+		var body struct {
+			Reason string `json:"reason"`
+			Error  string `json:"error"`
+		}
+		json.NewDecoder(res.Body).Decode(&body)
+		res.Body.Close()
+		statusErr.Reason = body.Reason + body.Error
+
+		// the session expired, log in again once before giving up
+		if res.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			if err := c.auth.login(ctx, c.http, c.config.Server); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return nil, statusErr
+	}
+}
+
+func permanent(err error) bool {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	switch statusErr.StatusCode {
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return true
+	}
+	return false
+}
+
+func (c *Conn) run(ws *websocket.Conn) {
+	for {
+		c.setConn(ws)
+		c.notify(StateConnected, nil)
+		err := c.readLoop(ws)
+		c.setConn(nil)
+		ws.Close()
+		c.failPending()
+
+		select {
+		case <-c.closed:
+			return
+		default:
+		}
+		c.notify(StateDisconnected, err)
+
+		ws = c.reconnect()
+		if ws == nil {
+			return
+		}
+		go c.resubscribe()
+	}
+}
+
+func (c *Conn) reconnect() *websocket.Conn {
+	backoff := c.config.MinBackoff
+	for {
+		// full jitter keeps thousands of devices from reconnecting in lockstep after a restart
+		delay := backoff/2 + time.Duration(rand.Int64N(int64(backoff/2)+1))
+		select {
+		case <-c.closed:
+			return nil
+		case <-time.After(delay):
+		}
+
+		c.notify(StateConnecting, nil)
+		ctx, cancel := context.WithTimeout(context.Background(), c.dialer.HandshakeTimeout)
+		ws, err := c.connect(ctx)
+		cancel()
+		if err == nil {
+			return ws
+		}
+		if permanent(err) {
+			c.terminate(err)
+			return nil
+		}
+
+		c.notify(StateDisconnected, err)
+		backoff *= 2
+		if backoff > c.config.MaxBackoff {
+			backoff = c.config.MaxBackoff
+		}
+	}
+}
+
+func (c *Conn) readLoop(ws *websocket.Conn) error {
+	interval := c.config.HeartbeatInterval
+	stop := make(chan struct{})
+	defer close(stop)
+	if interval > 0 {
+		ws.SetReadDeadline(time.Now().Add(2 * interval))
+		ws.SetPongHandler(func(string) error {
+			return ws.SetReadDeadline(time.Now().Add(2 * interval))
+		})
+		go c.heartbeat(ws, interval, stop)
+	}
+
+	for {
+		messageType, data, err := ws.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if interval > 0 {
+			ws.SetReadDeadline(time.Now().Add(2 * interval))
+		}
+
+		message := &Message{Binary: messageType == websocket.BinaryMessage, Data: data}
+		if !message.Binary {
+			message.Frame = parseFrame(data)
+			if c.resolve(message.Frame) {
+				continue
+			}
+		}
+
+		select {
+		case c.incoming <- message:
+		case <-c.closed:
+			return ErrClosed
+		}
+	}
+}
+
+func (c *Conn) heartbeat(ws *websocket.Conn, interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		c.writeMu.Lock()
+		err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.config.WriteTimeout))
+		c.writeMu.Unlock()
+		if err != nil {
+			ws.Close()
+			return
+		}
+	}
+}
+
+func (c *Conn) setConn(ws *websocket.Conn) {
+	c.wsMu.Lock()
+	defer c.wsMu.Unlock()
+	c.ws = ws
+}
+
+func (c *Conn) conn() *websocket.Conn {
+	c.wsMu.Lock()
+	defer c.wsMu.Unlock()
+	return c.ws
+}
+
+func (c *Conn) terminate(err error) {
+	c.closeOnce.Do(func() {
+		c.err = err
+		close(c.closed)
+		c.notify(StateClosed, err)
+	})
+}
+
+// Close ends the connection for good, pending Receive and Call return ErrClosed.
+func (c *Conn) Close() error {
+	c.terminate(nil)
+	ws := c.conn()
+	if ws == nil {
+		return nil
+	}
+	c.writeMu.Lock()
+	ws.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(c.config.WriteTimeout))
+	c.writeMu.Unlock()
+	return ws.Close()
+}
+
+// Done is closed once the connection is closed for good, Err tells why.
+func (c *Conn) Done() <-chan struct{} {
+	return c.closed
+}
+
+func (c *Conn) Err() error {
+	select {
+	case <-c.closed:
+		if c.err != nil {
+			return c.err
+		}
+		return ErrClosed
+	default:
+		return nil
+	}
+}
+
+// Receive waits for the next inbound message, responses to Call and Subscribe are not returned here.
+func (c *Conn) Receive(ctx context.Context) (*Message, error) {
+	select {
+	case message := <-c.incoming:
+		return message, nil
+	case <-c.closed:
+		return nil, c.Err()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *Conn) write(messageType int, data []byte) error {
+	if err := c.Err(); err != nil {
+		return err
+	}
+	ws := c.conn()
+	if ws == nil {
+		return ErrNotConnected
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	ws.SetWriteDeadline(time.Now().Add(c.config.WriteTimeout))
+	return ws.WriteMessage(messageType, data)
+}
+
+func (c *Conn) SendText(data []byte) error {
+	return c.write(websocket.TextMessage, data)
+}
+
+func (c *Conn) SendBinary(data []byte) error {
+	return c.write(websocket.BinaryMessage, data)
+}
+
+// SendJSON marshals v and sends it as a text message.
+func (c *Conn) SendJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.SendText(data)
+}
+
+func (c *Conn) Send(frame *Frame) error {
+	return c.SendJSON(frame)
+}
+
+// Publish sends a message tagged with a topic, only sub devices subscribed to it receive the message.
+func (c *Conn) Publish(topic string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("client: publish needs a json object: %w", err)
+	}
+	fields["topic"], _ = json.Marshal(topic)
+	return c.SendJSON(fields)
+}
+
+func (c *Conn) nextId() string {
+	return fmt.Sprintf("c%d", c.seq.Add(1))
+}
+
+func (c *Conn) roundTrip(ctx context.Context, frame *Frame) (*Frame, error) {
+	frame.Id = c.nextId()
+	reply := make(chan *Frame, 1)
+	c.pendingMu.Lock()
+	c.pending[frame.Id] = reply
+	c.pendingMu.Unlock()
+	defer func() {
+		c.pendingMu.Lock()
+		delete(c.pending, frame.Id)
+		c.pendingMu.Unlock()
+	}()
+
+	if err := c.Send(frame); err != nil {
+		return nil, err
+	}
+	select {
+	case res, ok := <-reply:
+		if !ok {
+			return nil, ErrNotConnected
+		}
+		if res.Type == FrameTypeError {
+			return nil, &RemoteError{Message: res.Error}
+		}
+		return res, nil
+	case <-c.closed:
+		return nil, c.Err()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *Conn) resolve(frame *Frame) bool {
+	if frame == nil || frame.Id == "" || (frame.Type != FrameTypeResponse && frame.Type != FrameTypeError) {
+		return false
+	}
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	reply, ok := c.pending[frame.Id]
+	if ok {
+		reply <- frame
+		delete(c.pending, frame.Id)
+	}
+	return ok
+}
+
+func (c *Conn) failPending() {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	for id, reply := range c.pending {
+		close(reply)
+		delete(c.pending, id)
+	}
+}
+
+// Call invokes a method on the main device from a sub device, the ctx deadline becomes the server side timeout.
+func (c *Conn) Call(ctx context.Context, method string, params any, result any) error {
+	frame := &Frame{Type: FrameTypeRequest, Method: method}
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		frame.Params = raw
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		frame.TimeoutMs = max(time.Until(deadline).Milliseconds(), 1)
+	}
+
+	res, err := c.roundTrip(ctx, frame)
+	if err != nil {
+		return err
+	}
+	if result == nil || len(res.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(res.Result, result)
+}
+
+// Reply answers a request frame received by the main device.
+func (c *Conn) Reply(request *Frame, result any) error {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return c.Send(&Frame{Type: FrameTypeResponse, Id: request.Id, Result: raw})
+}
+
+func (c *Conn) ReplyError(request *Frame, message string) error {
+	return c.Send(&Frame{Type: FrameTypeError, Id: request.Id, Error: message})
+}
+
+// Subscribe is used by sub devices, the subscription is restored after every reconnect.
+func (c *Conn) Subscribe(ctx context.Context, topic string) ([]string, error) {
+	topics, err := c.subscription(ctx, FrameTypeSubscribe, topic)
+	if err == nil {
+		c.topicsMu.Lock()
+		c.topics[topic] = true
+		c.topicsMu.Unlock()
+	}
+	return topics, err
+}
+
+func (c *Conn) Unsubscribe(ctx context.Context, topic string) ([]string, error) {
+	c.topicsMu.Lock()
+	delete(c.topics, topic)
+	c.topicsMu.Unlock()
+	return c.subscription(ctx, FrameTypeUnsubscribe, topic)
+}
+
+func (c *Conn) subscription(ctx context.Context, frameType string, topic string) ([]string, error) {
+	res, err := c.roundTrip(ctx, &Frame{Type: frameType, Topic: topic})
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Topics []string `json:"topics"`
+	}
+	if err := json.Unmarshal(res.Result, &result); err != nil {
+		return nil, err
+	}
+	return result.Topics, nil
+}
+
+func (c *Conn) resubscribe() {
+	c.topicsMu.Lock()
+	topics := make([]string, 0, len(c.topics))
+	for topic := range c.topics {
+		topics = append(topics, topic)
+	}
+	c.topicsMu.Unlock()
+
+	for _, topic := range topics {
+		ctx, cancel := context.WithTimeout(context.Background(), c.config.WriteTimeout)
+		c.subscription(ctx, FrameTypeSubscribe, topic)
+		cancel()
+	}
+}
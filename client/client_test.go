@@ -0,0 +1,636 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeConn serializes the writes of the relay, replies and forwarded calls come from different goroutines.
+type fakeConn struct {
+	ws     *websocket.Conn
+	mu     sync.Mutex
+	topics map[string]bool
+}
+
+func (f *fakeConn) write(v any) {
+	data, _ := json.Marshal(v)
+	f.writeRaw(data)
+}
+
+func (f *fakeConn) writeRaw(data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ws.WriteMessage(websocket.TextMessage, data)
+}
+
+type route struct {
+	conn *fakeConn
+	id   string
+}
+
+// fakeRelay speaks the login api and the frame protocol of the server with a single main device.
+type fakeRelay struct {
+	server   *httptest.Server
+	upgrader websocket.Upgrader
+
+	mu           sync.Mutex
+	sessions     map[string]bool
+	tokens       map[string]bool
+	logins       int
+	refuse       int
+	main         *fakeConn
+	conns        map[*fakeConn]bool
+	calls        map[string]route
+	resumeTokens []string
+	handshakes   int
+
+	accepted   chan string
+	subscribed chan string
+}
+
+func newFakeRelay(t *testing.T) *fakeRelay {
+	f := &fakeRelay{
+		sessions:   make(map[string]bool),
+		tokens:     make(map[string]bool),
+		conns:      make(map[*fakeConn]bool),
+		calls:      make(map[string]route),
+		accepted:   make(chan string, 64),
+		subscribed: make(chan string, 64),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/user/login", f.handleLogin)
+	mux.HandleFunc("/api/v1/communication/main", f.handleWebsocket)
+	mux.HandleFunc("/api/v1/communication/sub", f.handleWebsocket)
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(func() {
+		f.kick()
+		f.server.Close()
+	})
+	return f
+}
+
+func writeReason(w http.ResponseWriter, statusCode int, reason string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]string{"reason": reason})
+}
+
+func (f *fakeRelay) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	if body.Password != "password" {
+		writeReason(w, http.StatusUnauthorized, "wrong username or password")
+		return
+	}
+	if body.Username == "two-factor" {
+		json.NewEncoder(w).Encode(map[string]any{"result": map[string]any{"two_factor_required": true}})
+		return
+	}
+
+	f.mu.Lock()
+	f.logins++
+	session := fmt.Sprintf("session-%d", f.logins)
+	f.sessions[session] = true
+	f.mu.Unlock()
+	http.SetCookie(w, &http.Cookie{Name: "login", Value: session, Path: "/"})
+	json.NewEncoder(w).Encode(map[string]any{"result": map[string]any{}})
+}
+
+func (f *fakeRelay) handleWebsocket(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.handshakes++
+	f.resumeTokens = append(f.resumeTokens, r.URL.Query().Get("resume_token"))
+	refuse := f.refuse
+	authorized := f.tokens[r.Header.Get("Authorization")]
+	if cookie, err := r.Cookie("login"); err == nil && f.sessions[cookie.Value] {
+		authorized = true
+	}
+	resumeToken := fmt.Sprintf("resume-%d", f.handshakes)
+	f.mu.Unlock()
+
+	if refuse != 0 {
+		writeReason(w, refuse, "refused")
+		return
+	}
+	if !authorized {
+		writeReason(w, http.StatusUnauthorized, "login first")
+		return
+	}
+	ws, err := f.upgrader.Upgrade(w, r, http.Header{"X-Resume-Token": {resumeToken}})
+	if err != nil {
+		return
+	}
+
+	conn := &fakeConn{ws: ws, topics: make(map[string]bool)}
+	isMain := r.URL.Path == "/api/v1/communication/main"
+	f.mu.Lock()
+	f.conns[conn] = true
+	if isMain {
+		f.main = conn
+	}
+	f.mu.Unlock()
+	role := "sub"
+	if isMain {
+		role = "main"
+	}
+	f.accepted <- role
+	go f.serve(conn, isMain)
+}
+
+func (f *fakeRelay) serve(conn *fakeConn, isMain bool) {
+	defer func() {
+		f.mu.Lock()
+		delete(f.conns, conn)
+		if f.main == conn {
+			f.main = nil
+		}
+		f.mu.Unlock()
+		conn.ws.Close()
+	}()
+
+	for {
+		_, data, err := conn.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		frame := parseFrame(data)
+		if isMain {
+			f.fromMain(data, frame)
+		} else if frame != nil {
+			f.fromSub(conn, frame)
+		}
+	}
+}
+
+func (f *fakeRelay) fromMain(data []byte, frame *Frame) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if frame == nil {
+		for conn := range f.conns {
+			if conn != f.main {
+				go conn.writeRaw(data)
+			}
+		}
+		return
+	}
+	call, ok := f.calls[frame.Id]
+	if !ok {
+		return
+	}
+	delete(f.calls, frame.Id)
+	frame.Id = call.id
+	go call.conn.write(frame)
+}
+
+func (f *fakeRelay) fromSub(conn *fakeConn, frame *Frame) {
+	switch frame.Type {
+	case FrameTypeSubscribe, FrameTypeUnsubscribe:
+		f.mu.Lock()
+		if frame.Type == FrameTypeSubscribe {
+			conn.topics[frame.Topic] = true
+		} else {
+			delete(conn.topics, frame.Topic)
+		}
+		topics := make([]string, 0, len(conn.topics))
+		for topic := range conn.topics {
+			topics = append(topics, topic)
+		}
+		f.mu.Unlock()
+		sort.Strings(topics)
+		result, _ := json.Marshal(map[string]any{"topics": topics})
+		conn.write(&Frame{Type: FrameTypeResponse, Id: frame.Id, Result: result})
+		if frame.Type == FrameTypeSubscribe {
+			f.subscribed <- frame.Topic
+		}
+	case FrameTypeRequest:
+		f.mu.Lock()
+		main := f.main
+		id := fmt.Sprintf("server-%s-%p", frame.Id, conn)
+		if main != nil {
+			f.calls[id] = route{conn: conn, id: frame.Id}
+		}
+		f.mu.Unlock()
+		if main == nil {
+			conn.write(&Frame{Type: FrameTypeError, Id: frame.Id, Error: "main device offline"})
+			return
+		}
+		main.write(&Frame{Type: FrameTypeRequest, Id: id, Method: frame.Method, Params: frame.Params})
+	}
+}
+
+// kick closes every websocket from the server side.
+func (f *fakeRelay) kick() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for conn := range f.conns {
+		conn.ws.Close()
+	}
+}
+
+func (f *fakeRelay) expireSessions() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sessions = make(map[string]bool)
+}
+
+func (f *fakeRelay) loginCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.logins
+}
+
+func (f *fakeRelay) waitAccepted(t *testing.T, role string) {
+	t.Helper()
+	select {
+	case got := <-f.accepted:
+		if got != role {
+			t.Fatalf("accepted %s, want %s", got, role)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no %s connection accepted", role)
+	}
+}
+
+func (f *fakeRelay) waitSubscribed(t *testing.T, topic string) {
+	t.Helper()
+	select {
+	case got := <-f.subscribed:
+		if got != topic {
+			t.Fatalf("subscribed %s, want %s", got, topic)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s was not subscribed", topic)
+	}
+}
+
+type stateRecorder chan State
+
+func (s stateRecorder) record(state State, err error) {
+	select {
+	case s <- state:
+	default:
+	}
+}
+
+func (s stateRecorder) wait(t *testing.T, want State) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case state := <-s:
+			if state == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("state %s was not reached", want)
+		}
+	}
+}
+
+func newTestConfig(f *fakeRelay, states stateRecorder) Config {
+	config := Config{
+		Server:            f.server.URL,
+		Username:          "user",
+		Password:          "password",
+		HeartbeatInterval: -1,
+		MinBackoff:        10 * time.Millisecond,
+		MaxBackoff:        50 * time.Millisecond,
+		WriteTimeout:      time.Second,
+	}
+	if states != nil {
+		config.OnStateChange = states.record
+	}
+	return config
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestDialLogsInWithCookie(t *testing.T) {
+	relay := newFakeRelay(t)
+	conn, err := DialMain(testContext(t), newTestConfig(relay, nil), 1)
+	if err != nil {
+		t.Fatalf("DialMain() = %v", err)
+	}
+	if got := relay.loginCount(); got != 1 {
+		t.Fatalf("logins = %d, want 1", got)
+	}
+
+	conn.Close()
+	select {
+	case <-conn.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Done was not closed")
+	}
+	if !errors.Is(conn.Err(), ErrClosed) {
+		t.Fatalf("Err() = %v, want %v", conn.Err(), ErrClosed)
+	}
+	if err := conn.SendText([]byte("late")); !errors.Is(err, ErrClosed) {
+		t.Fatalf("SendText() after Close = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestDialLoginFailed(t *testing.T) {
+	relay := newFakeRelay(t)
+	config := newTestConfig(relay, nil)
+	config.Password = "wrong"
+	_, err := DialMain(testContext(t), config, 1)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized || statusErr.Reason == "" {
+		t.Fatalf("DialMain() = %v, want a 401 StatusError with a reason", err)
+	}
+
+	config.Username = "two-factor"
+	config.Password = "password"
+	if _, err := DialMain(testContext(t), config, 1); !errors.Is(err, ErrTwoFactorRequired) {
+		t.Fatalf("DialMain() = %v, want %v", err, ErrTwoFactorRequired)
+	}
+}
+
+func TestDialWithToken(t *testing.T) {
+	relay := newFakeRelay(t)
+	relay.tokens["Bearer device-token"] = true
+	config := newTestConfig(relay, nil)
+	config.Token = "device-token"
+	conn, err := DialMain(testContext(t), config, 1)
+	if err != nil {
+		t.Fatalf("DialMain() = %v", err)
+	}
+	defer conn.Close()
+	if got := relay.loginCount(); got != 0 {
+		t.Fatalf("logins = %d, token auth should not log in", got)
+	}
+}
+
+func TestReconnectAfterServerClose(t *testing.T) {
+	relay := newFakeRelay(t)
+	states := make(stateRecorder, 64)
+	conn, err := DialMain(testContext(t), newTestConfig(relay, states), 1)
+	if err != nil {
+		t.Fatalf("DialMain() = %v", err)
+	}
+	defer conn.Close()
+	states.wait(t, StateConnected)
+	relay.waitAccepted(t, "main")
+
+	relay.kick()
+	states.wait(t, StateDisconnected)
+	states.wait(t, StateConnected)
+	relay.waitAccepted(t, "main")
+
+	relay.mu.Lock()
+	resumeTokens := relay.resumeTokens
+	relay.mu.Unlock()
+	if len(resumeTokens) != 2 || resumeTokens[0] != "" || resumeTokens[1] != "resume-1" {
+		t.Fatalf("resume tokens = %q, want the token of the first handshake on the second", resumeTokens)
+	}
+	if got := relay.loginCount(); got != 1 {
+		t.Fatalf("logins = %d, the session cookie should be reused", got)
+	}
+}
+
+func TestReloginAfterSessionExpired(t *testing.T) {
+	relay := newFakeRelay(t)
+	states := make(stateRecorder, 64)
+	conn, err := DialMain(testContext(t), newTestConfig(relay, states), 1)
+	if err != nil {
+		t.Fatalf("DialMain() = %v", err)
+	}
+	defer conn.Close()
+	states.wait(t, StateConnected)
+	relay.waitAccepted(t, "main")
+
+	relay.expireSessions()
+	relay.kick()
+	states.wait(t, StateDisconnected)
+	states.wait(t, StateConnected)
+	relay.waitAccepted(t, "main")
+	if got := relay.loginCount(); got != 2 {
+		t.Fatalf("logins = %d, want a second login after the 401", got)
+	}
+}
+
+func TestPermanentErrorClosesConn(t *testing.T) {
+	relay := newFakeRelay(t)
+	conn, err := DialMain(testContext(t), newTestConfig(relay, nil), 1)
+	if err != nil {
+		t.Fatalf("DialMain() = %v", err)
+	}
+	relay.waitAccepted(t, "main")
+
+	relay.mu.Lock()
+	relay.refuse = http.StatusNotFound
+	relay.mu.Unlock()
+	relay.kick()
+	select {
+	case <-conn.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("a 404 on reconnect should close the connection")
+	}
+	var statusErr *StatusError
+	if !errors.As(conn.Err(), &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("Err() = %v, want a 404 StatusError", conn.Err())
+	}
+}
+
+func TestResubscribeAfterReconnect(t *testing.T) {
+	relay := newFakeRelay(t)
+	ctx := testContext(t)
+	states := make(stateRecorder, 64)
+	sub, err := DialSub(ctx, newTestConfig(relay, states), 1, 2)
+	if err != nil {
+		t.Fatalf("DialSub() = %v", err)
+	}
+	defer sub.Close()
+	relay.waitAccepted(t, "sub")
+
+	if _, err := sub.Subscribe(ctx, "sensors/#"); err != nil {
+		t.Fatalf("Subscribe() = %v", err)
+	}
+	relay.waitSubscribed(t, "sensors/#")
+	topics, err := sub.Subscribe(ctx, "alerts")
+	if err != nil || len(topics) != 2 {
+		t.Fatalf("Subscribe() = %v, %v, want both topics", topics, err)
+	}
+	relay.waitSubscribed(t, "alerts")
+	if _, err := sub.Unsubscribe(ctx, "alerts"); err != nil {
+		t.Fatalf("Unsubscribe() = %v", err)
+	}
+
+	relay.kick()
+	relay.waitAccepted(t, "sub")
+	relay.waitSubscribed(t, "sensors/#")
+	select {
+	case topic := <-relay.subscribed:
+		t.Fatalf("%s was resubscribed after Unsubscribe", topic)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestCallReply(t *testing.T) {
+	relay := newFakeRelay(t)
+	ctx := testContext(t)
+	main, err := DialMain(ctx, newTestConfig(relay, nil), 1)
+	if err != nil {
+		t.Fatalf("DialMain() = %v", err)
+	}
+	defer main.Close()
+	relay.waitAccepted(t, "main")
+	sub, err := DialSub(ctx, newTestConfig(relay, nil), 1, 2)
+	if err != nil {
+		t.Fatalf("DialSub() = %v", err)
+	}
+	defer sub.Close()
+	relay.waitAccepted(t, "sub")
+
+	go func() {
+		for {
+			message, err := main.Receive(ctx)
+			if err != nil {
+				return
+			}
+			if message.Frame == nil || message.Frame.Type != FrameTypeRequest {
+				continue
+			}
+			switch message.Frame.Method {
+			case "echo":
+				main.Reply(message.Frame, message.Frame.Params)
+			case "fail":
+				main.ReplyError(message.Frame, "boom")
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var result struct {
+				N int `json:"n"`
+			}
+			if err := sub.Call(ctx, "echo", map[string]int{"n": i}, &result); err != nil {
+				t.Errorf("Call() = %v", err)
+			} else if result.N != i {
+				t.Errorf("Call() result = %d, want %d", result.N, i)
+			}
+		}()
+	}
+	wg.Wait()
+
+	err = sub.Call(ctx, "fail", nil, nil)
+	var remoteErr *RemoteError
+	if !errors.As(err, &remoteErr) || remoteErr.Message != "boom" {
+		t.Fatalf("Call() = %v, want the remote error", err)
+	}
+
+	if err := main.Publish("sensors/temperature", map[string]float64{"celsius": 21.5}); err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	message, err := sub.Receive(ctx)
+	if err != nil {
+		t.Fatalf("Receive() = %v", err)
+	}
+	var published struct {
+		Topic   string  `json:"topic"`
+		Celsius float64 `json:"celsius"`
+	}
+	if err := message.Decode(&published); err != nil || published.Topic != "sensors/temperature" || published.Celsius != 21.5 {
+		t.Fatalf("Receive() = %s, want the published message", message.Data)
+	}
+}
+
+func TestCallFailsWhenConnectionLost(t *testing.T) {
+	relay := newFakeRelay(t)
+	ctx := testContext(t)
+	main, err := DialMain(ctx, newTestConfig(relay, nil), 1)
+	if err != nil {
+		t.Fatalf("DialMain() = %v", err)
+	}
+	defer main.Close()
+	relay.waitAccepted(t, "main")
+	sub, err := DialSub(ctx, newTestConfig(relay, nil), 1, 2)
+	if err != nil {
+		t.Fatalf("DialSub() = %v", err)
+	}
+	defer sub.Close()
+	relay.waitAccepted(t, "sub")
+
+	result := make(chan error, 1)
+	go func() {
+		result <- sub.Call(ctx, "never", nil, nil)
+	}()
+	if _, err := main.Receive(ctx); err != nil {
+		t.Fatalf("Receive() = %v", err)
+	}
+	relay.kick()
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrNotConnected) {
+			t.Fatalf("Call() = %v, want %v", err, ErrNotConnected)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Call did not return after the connection was lost")
+	}
+}
+
+// TestConcurrentWritesDuringReconnect runs writers, pings and reconnects at once, it is meant for -race.
+func TestConcurrentWritesDuringReconnect(t *testing.T) {
+	relay := newFakeRelay(t)
+	config := newTestConfig(relay, nil)
+	config.HeartbeatInterval = 5 * time.Millisecond
+	conn, err := DialMain(testContext(t), config, 1)
+	if err != nil {
+		t.Fatalf("DialMain() = %v", err)
+	}
+
+	var sent atomic.Int64
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if conn.SendText([]byte(`{"celsius":21.5}`)) == nil {
+					sent.Add(1)
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 5; i++ {
+		time.Sleep(20 * time.Millisecond)
+		relay.kick()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+	conn.Close()
+
+	if sent.Load() == 0 {
+		t.Fatal("no message was sent")
+	}
+}
@@ -0,0 +1,83 @@
+// main_device publishes a reading every second and answers "set_mode" calls from its sub devices.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"device-communication/client"
+)
+
+func main() {
+	server := flag.String("server", "http://localhost:8085", "relay address")
+	username := flag.String("username", "", "owner username")
+	password := flag.String("password", "", "owner password")
+	token := flag.String("token", "", "device credential, replaces username and password")
+	mainDeviceId := flag.Uint64("main_device_id", 0, "bound main device id")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	conn, err := client.DialMain(ctx, client.Config{
+		Server:   *server,
+		Username: *username,
+		Password: *password,
+		Token:    *token,
+		OnStateChange: func(state client.State, err error) {
+			log.Printf("state %s %v", state, err)
+		},
+	}, *mainDeviceId)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	go func() {
+		mode := "idle"
+		for {
+			message, err := conn.Receive(ctx)
+			if err != nil {
+				return
+			}
+			frame := message.Frame
+			if frame == nil || frame.Type != client.FrameTypeRequest {
+				continue
+			}
+
+			switch frame.Method {
+			case "set_mode":
+				var params struct {
+					Mode string `json:"mode"`
+				}
+				if err := json.Unmarshal(frame.Params, &params); err != nil || params.Mode == "" {
+					conn.ReplyError(frame, "params.mode is required")
+					continue
+				}
+				mode = params.Mode
+				conn.Reply(frame, map[string]string{"mode": mode})
+			default:
+				conn.ReplyError(frame, "unknown method "+frame.Method)
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			err := conn.Publish("sensors/temperature", map[string]any{"celsius": 21.5, "time": now})
+			if err != nil && err != client.ErrNotConnected {
+				log.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// sub_device subscribes to the temperature readings and switches the main device into "eco" mode.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"device-communication/client"
+)
+
+func main() {
+	server := flag.String("server", "http://localhost:8085", "relay address")
+	username := flag.String("username", "", "owner username")
+	password := flag.String("password", "", "owner password")
+	token := flag.String("token", "", "device credential, replaces username and password")
+	mainDeviceId := flag.Uint64("main_device_id", 0, "bound main device id")
+	subDeviceId := flag.Uint64("sub_device_id", 0, "bound sub device id")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	conn, err := client.DialSub(ctx, client.Config{
+		Server:   *server,
+		Username: *username,
+		Password: *password,
+		Token:    *token,
+	}, *mainDeviceId, *subDeviceId)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Subscribe(ctx, "sensors/#"); err != nil {
+		log.Fatal(err)
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	var result struct {
+		Mode string `json:"mode"`
+	}
+	err = conn.Call(callCtx, "set_mode", map[string]string{"mode": "eco"}, &result)
+	cancel()
+	if err != nil {
+		log.Printf("set_mode failed: %s", err)
+	} else {
+		log.Printf("main device is in %s mode", result.Mode)
+	}
+
+	for {
+		message, err := conn.Receive(ctx)
+		if err != nil {
+			log.Printf("stopped: %s", err)
+			return
+		}
+		var reading struct {
+			Celsius float64   `json:"celsius"`
+			Time    time.Time `json:"time"`
+		}
+		if err := message.Decode(&reading); err == nil {
+			log.Printf("%.1f°C at %s", reading.Celsius, reading.Time.Format(time.TimeOnly))
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package client
+
+import "encoding/json"
+
+const (
+	FrameTypeRequest     = "request"
+	FrameTypeResponse    = "response"
+	FrameTypeError       = "error"
+	FrameTypeSubscribe   = "subscribe"
+	FrameTypeUnsubscribe = "unsubscribe"
+)
+
+// Frame is the json envelope understood by the server, see the readme for every type.
+type Frame struct {
+	Type      string          `json:"type"`
+	Id        string          `json:"id,omitempty"`
+	Topic     string          `json:"topic,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	TimeoutMs int64           `json:"timeout_ms,omitempty"`
+}
+
+// Message is one inbound message, Frame is nil when the payload is not a frame.
+type Message struct {
+	Binary bool
+	Data   []byte
+	Frame  *Frame
+}
+
+// Decode unmarshals the raw payload, use it for application messages that are not frames.
+func (m *Message) Decode(v any) error {
+	return json.Unmarshal(m.Data, v)
+}
+
+func parseFrame(data []byte) *Frame {
+	var frame Frame
+	if err := json.Unmarshal(data, &frame); err != nil || frame.Type == "" {
+		return nil
+	}
+	return &frame
+}
+
+// RemoteError is returned by Call when the other side answered with an error frame.
+type RemoteError struct {
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return e.Message
+}
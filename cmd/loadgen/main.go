@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+)
+
+type options struct {
+	server      string
+	rooms       int
+	subs        int
+	maxSubs     int
+	rate        float64
+	duration    time.Duration
+	payload     int
+	prefix      string
+	password    string
+	concurrency int
+}
+
+func main() {
+	var o options
+	flag.StringVar(&o.server, "server", "http://localhost:8085", "local server address")
+	flag.IntVar(&o.rooms, "rooms", 10, "number of rooms (one user and one main device per room)")
+	flag.IntVar(&o.subs, "subs", 1, "sub devices per room")
+	flag.IntVar(&o.maxSubs, "max-subs", 1, "limit.max_sub_device_per_main_device of the server, -subs may not exceed it")
+	flag.Float64Var(&o.rate, "rate", 1, "messages per second sent by each main device")
+	flag.DurationVar(&o.duration, "duration", 30*time.Second, "how long to send messages")
+	flag.IntVar(&o.payload, "payload", 128, "padding bytes added to every message")
+	flag.StringVar(&o.prefix, "prefix", fmt.Sprintf("loadgen%d", time.Now().Unix()), "username and device id prefix, reuse it to run again with the users and devices of an earlier run")
+	flag.StringVar(&o.password, "password", "loadgen-password", "password of the generated users")
+	flag.IntVar(&o.concurrency, "concurrency", 50, "rooms set up in parallel")
+	flag.Parse()
+
+	if err := checkLocal(o.server); err != nil {
+		log.Fatal(err)
+	}
+	if o.rooms <= 0 || o.subs < 0 || o.rate <= 0 || o.concurrency <= 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if o.subs > o.maxSubs {
+		log.Fatalf("-subs %d is above the sub device limit %d, raise limit.max_sub_device_per_main_device on the server and pass it as -max-subs", o.subs, o.maxSubs)
+	}
+
+	stats := newStats()
+	rooms := make([]*room, o.rooms)
+	sem := make(chan struct{}, o.concurrency)
+	var wg sync.WaitGroup
+	setupStart := time.Now()
+	for i := range rooms {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			rooms[i] = setupRoom(o, i, stats)
+		}(i)
+	}
+	wg.Wait()
+	log.Printf("set up %d rooms in %s", o.rooms, time.Since(setupStart).Round(time.Millisecond))
+
+	stop := make(chan struct{})
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		select {
+		case <-time.After(o.duration):
+		case <-interrupt:
+		}
+		close(stop)
+	}()
+
+	stats.start()
+	for _, r := range rooms {
+		if r != nil && r.main != nil {
+			wg.Add(1)
+			go func(r *room) {
+				defer wg.Done()
+				r.drive(o, stats, stop)
+			}(r)
+		}
+	}
+	wg.Wait()
+
+	// give in-flight messages a moment to arrive before closing
+	time.Sleep(time.Second)
+	for _, r := range rooms {
+		if r != nil {
+			r.close()
+		}
+	}
+	stats.report(os.Stdout)
+}
+
+func checkLocal(server string) error {
+	u, err := url.Parse(server)
+	if err != nil {
+		return err
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("loadgen only runs against a local instance, got %q", host)
+}
+
+func wsURL(server string, path string, query url.Values) string {
+	u, _ := url.Parse(server)
+	u.Scheme = strings.Replace(u.Scheme, "http", "ws", 1)
+	u.Path = path
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type room struct {
+	client       *http.Client
+	jar          http.CookieJar
+	mainDeviceId uint64
+	main         *websocket.Conn
+	subs         []*websocket.Conn
+	wg           sync.WaitGroup
+}
+
+type message struct {
+	Seq    int64  `json:"seq"`
+	SentAt int64  `json:"sent_at"`
+	Pad    string `json:"pad,omitempty"`
+}
+
+type apiResponse struct {
+	Result json.RawMessage `json:"result"`
+	Reason string          `json:"reason"`
+}
+
+func setupRoom(o options, i int, stats *stats) *room {
+	jar, _ := cookiejar.New(nil)
+	r := &room{client: &http.Client{Jar: jar, Timeout: 10 * time.Second}, jar: jar}
+	username := fmt.Sprintf("%s_%d", o.prefix, i)
+
+	status, _, err := r.call(o.server, http.MethodPost, "/api/v1/user/register", map[string]any{
+		"username": username,
+		"password": o.password,
+		"name":     username,
+		"email":    username + "@loadgen.local",
+	})
+	if err != nil || (status != http.StatusOK && status != http.StatusConflict) {
+		stats.fail("register", status, err)
+		return nil
+	}
+
+	status, _, err = r.call(o.server, http.MethodPost, "/api/v1/user/login", map[string]any{
+		"username": username,
+		"password": o.password,
+	})
+	if err != nil || status != http.StatusOK {
+		stats.fail("login", status, err)
+		return nil
+	}
+
+	var mainDevice struct {
+		MainDeviceId uint64 `json:"main_device_id"`
+	}
+	status, result, err := r.call(o.server, http.MethodPut, "/api/v1/device/main", map[string]any{
+		"platform":  "loadgen",
+		"version":   "1",
+		"device_id": username + "_main",
+	})
+	var bound map[string]uint64
+	if status == http.StatusConflict {
+		// a reused -prefix binds the same device ids again, take the ids of the earlier run
+		bound, err = r.boundDevices(o.server)
+		mainDevice.MainDeviceId = bound[username+"_main"]
+		if err != nil || mainDevice.MainDeviceId == 0 {
+			stats.fail("bind_main", status, err)
+			return nil
+		}
+	} else if err != nil || status != http.StatusOK || json.Unmarshal(result, &mainDevice) != nil {
+		stats.fail("bind_main", status, err)
+		return nil
+	}
+	r.mainDeviceId = mainDevice.MainDeviceId
+
+	subDeviceIds := make([]uint64, 0, o.subs)
+	for j := 0; j < o.subs; j++ {
+		var subDevice struct {
+			SubDeviceId uint64 `json:"sub_device_id"`
+		}
+		deviceId := fmt.Sprintf("%s_sub_%d", username, j)
+		status, result, err := r.call(o.server, http.MethodPut, "/api/v1/device/sub", map[string]any{
+			"main_device_id": r.mainDeviceId,
+			"platform":       "loadgen",
+			"version":        "1",
+			"device_id":      deviceId,
+		})
+		if status == http.StatusConflict {
+			if bound == nil {
+				bound, err = r.boundDevices(o.server)
+			}
+			if subDevice.SubDeviceId = bound[deviceId]; err != nil || subDevice.SubDeviceId == 0 {
+				stats.fail("bind_sub", status, err)
+				continue
+			}
+		} else if err != nil || status != http.StatusOK || json.Unmarshal(result, &subDevice) != nil {
+			stats.fail("bind_sub", status, err)
+			continue
+		}
+		subDeviceIds = append(subDeviceIds, subDevice.SubDeviceId)
+	}
+
+	dialer := websocket.Dialer{Jar: r.jar, HandshakeTimeout: 10 * time.Second}
+	query := url.Values{"main_device_id": {fmt.Sprint(r.mainDeviceId)}}
+	start := time.Now()
+	main, _, err := dialer.Dial(wsURL(o.server, "/api/v1/communication/main", query), nil)
+	if err != nil {
+		stats.fail("connect_main", 0, err)
+		return r
+	}
+	stats.connected(time.Since(start))
+	r.main = main
+	r.wg.Add(1)
+	go r.drain(main)
+
+	for _, subDeviceId := range subDeviceIds {
+		query := url.Values{"main_device_id": {fmt.Sprint(r.mainDeviceId)}, "sub_device_id": {fmt.Sprint(subDeviceId)}}
+		start := time.Now()
+		sub, _, err := dialer.Dial(wsURL(o.server, "/api/v1/communication/sub", query), nil)
+		if err != nil {
+			stats.fail("connect_sub", 0, err)
+			continue
+		}
+		stats.connected(time.Since(start))
+		r.subs = append(r.subs, sub)
+		r.wg.Add(1)
+		go r.receive(sub, stats)
+	}
+	return r
+}
+
+func (r *room) call(server string, method string, path string, body any) (int, json.RawMessage, error) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return 0, nil, err
+	}
+	req, err := http.NewRequest(method, strings.TrimRight(server, "/")+path, bytes.NewReader(raw))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close()
+
+	var response apiResponse
+	json.NewDecoder(res.Body).Decode(&response)
+	if res.StatusCode != http.StatusOK && response.Reason != "" {
+		return res.StatusCode, nil, fmt.Errorf("%s", response.Reason)
+	}
+	return res.StatusCode, response.Result, nil
+}
+
+// boundDevices maps the device_id of every main and sub device of the user to its id.
+func (r *room) boundDevices(server string) (map[string]uint64, error) {
+	status, result, err := r.call(server, http.MethodGet, "/api/v1/device/", nil)
+	if err != nil {
+		return nil, err
+	} else if status != http.StatusOK {
+		return nil, fmt.Errorf("list devices responded %d", status)
+	}
+
+	var devices struct {
+		MainDevices []struct {
+			Id         uint64 `json:"id"`
+			DeviceId   string `json:"device_id"`
+			SubDevices []struct {
+				Id       uint64 `json:"id"`
+				DeviceId string `json:"device_id"`
+			} `json:"sub_devices"`
+		} `json:"main_devices"`
+	}
+	if err := json.Unmarshal(result, &devices); err != nil {
+		return nil, err
+	}
+	ids := make(map[string]uint64)
+	for _, mainDevice := range devices.MainDevices {
+		ids[mainDevice.DeviceId] = mainDevice.Id
+		for _, subDevice := range mainDevice.SubDevices {
+			ids[subDevice.DeviceId] = subDevice.Id
+		}
+	}
+	return ids, nil
+}
+
+func (r *room) drive(o options, stats *stats, stop <-chan struct{}) {
+	pad := strings.Repeat("x", o.payload)
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / o.rate))
+	defer ticker.Stop()
+
+	var seq int64
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		seq++
+		data, _ := json.Marshal(&message{Seq: seq, SentAt: time.Now().UnixNano(), Pad: pad})
+		if err := r.main.WriteMessage(websocket.TextMessage, data); err != nil {
+			stats.fail("send", 0, err)
+			return
+		}
+		stats.sent(len(data))
+	}
+}
+
+func (r *room) receive(conn *websocket.Conn, stats *stats) {
+	defer r.wg.Done()
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var m message
+		if json.Unmarshal(data, &m) != nil || m.SentAt == 0 {
+			continue
+		}
+		stats.received(len(data), time.Duration(time.Now().UnixNano()-m.SentAt))
+	}
+}
+
+// the main device gets nothing in this scenario but must keep reading to handle control frames
+func (r *room) drain(conn *websocket.Conn) {
+	defer r.wg.Done()
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func (r *room) close() {
+	for _, sub := range r.subs {
+		sub.Close()
+	}
+	if r.main != nil {
+		r.main.Close()
+	}
+	r.wg.Wait()
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+type stats struct {
+	mu            sync.Mutex
+	startedAt     time.Time
+	sentCount     int64
+	sentBytes     int64
+	receivedCount int64
+	receivedBytes int64
+	latencies     []time.Duration
+	connects      []time.Duration
+	failures      map[string]int
+	lastErrors    map[string]string
+}
+
+func newStats() *stats {
+	return &stats{
+		failures:   make(map[string]int),
+		lastErrors: make(map[string]string),
+	}
+}
+
+func (s *stats) start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.startedAt = time.Now()
+}
+
+func (s *stats) fail(stage string, status int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[stage]++
+	if err != nil {
+		s.lastErrors[stage] = err.Error()
+	} else {
+		s.lastErrors[stage] = fmt.Sprintf("http status %d", status)
+	}
+}
+
+func (s *stats) connected(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connects = append(s.connects, d)
+}
+
+func (s *stats) sent(size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sentCount++
+	s.sentBytes += int64(size)
+}
+
+func (s *stats) received(size int, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.receivedCount++
+	s.receivedBytes += int64(size)
+	s.latencies = append(s.latencies, latency)
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted)-1) * p)
+	return sorted[i]
+}
+
+func (s *stats) report(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elapsed := time.Since(s.startedAt).Seconds()
+
+	fmt.Fprintf(w, "connections: %d established\n", len(s.connects))
+	printPercentiles(w, "connect latency", s.connects)
+	fmt.Fprintf(w, "sent:        %d messages, %.1f msg/s, %.1f KiB/s\n", s.sentCount, float64(s.sentCount)/elapsed, float64(s.sentBytes)/1024/elapsed)
+	fmt.Fprintf(w, "received:    %d messages, %.1f msg/s, %.1f KiB/s\n", s.receivedCount, float64(s.receivedCount)/elapsed, float64(s.receivedBytes)/1024/elapsed)
+	printPercentiles(w, "relay latency", s.latencies)
+
+	stages := make([]string, 0, len(s.failures))
+	for stage := range s.failures {
+		stages = append(stages, stage)
+	}
+	sort.Strings(stages)
+	if len(stages) == 0 {
+		fmt.Fprintln(w, "failures:    none")
+	}
+	for _, stage := range stages {
+		fmt.Fprintf(w, "failures:    %-12s %d (last: %s)\n", stage, s.failures[stage], s.lastErrors[stage])
+	}
+}
+
+func printPercentiles(w io.Writer, name string, values []time.Duration) {
+	sorted := append([]time.Duration(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	if len(sorted) == 0 {
+		fmt.Fprintf(w, "%s: no samples\n", name)
+		return
+	}
+	fmt.Fprintf(w, "%s: p50 %s, p90 %s, p99 %s, max %s\n", name,
+		percentile(sorted, 0.5), percentile(sorted, 0.9), percentile(sorted, 0.99), sorted[len(sorted)-1])
+}
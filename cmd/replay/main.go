@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"device-communication/src/recording"
+
+	"github.com/gorilla/websocket"
+)
+
+type options struct {
+	file         string
+	server       string
+	username     string
+	password     string
+	role         string
+	mainDeviceId uint64
+	subDeviceId  uint64
+	speed        float64
+	dry          bool
+}
+
+func main() {
+	var o options
+	flag.StringVar(&o.file, "file", "", "recording file (.jsonl)")
+	flag.StringVar(&o.server, "server", "http://localhost:8085", "server address")
+	flag.StringVar(&o.username, "username", "", "username of the device owner")
+	flag.StringVar(&o.password, "password", "", "password of the device owner")
+	flag.StringVar(&o.role, "role", "main", "replay the frames sent by main or sub")
+	flag.Uint64Var(&o.mainDeviceId, "main_device_id", 0, "main device id to connect as, defaults to the recorded one")
+	flag.Uint64Var(&o.subDeviceId, "sub_device_id", 0, "sub device id, required when role is sub")
+	flag.Float64Var(&o.speed, "speed", 1, "playback speed factor, 0 sends without delay")
+	flag.BoolVar(&o.dry, "dry", false, "print frames to stdout instead of connecting")
+	flag.Parse()
+
+	if o.file == "" || (o.role != "main" && o.role != "sub") || (o.role == "sub" && o.subDeviceId == 0) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	entries, err := recording.ReadFile(o.file)
+	if err != nil {
+		log.Fatalf("read %s: %s", o.file, err)
+	}
+	entries = filterEntries(entries, o)
+	if len(entries) == 0 {
+		log.Fatalf("no %s frames in %s", o.role, o.file)
+	}
+
+	if o.mainDeviceId == 0 {
+		fmt.Sscanf(filepath.Base(o.file), "%d_%d_", new(uint64), &o.mainDeviceId)
+	}
+
+	var conn *websocket.Conn
+	if !o.dry {
+		conn, err = dial(o)
+		if err != nil {
+			log.Fatalf("connect: %s", err)
+		}
+		defer conn.Close()
+		go func() {
+			for {
+				_, message, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				fmt.Printf("<- %s\n", message)
+			}
+		}()
+	}
+
+	start := time.Now()
+	for _, entry := range entries {
+		if o.speed > 0 {
+			due := start.Add(time.Duration(float64(entry.OffsetMs)/o.speed) * time.Millisecond)
+			time.Sleep(time.Until(due))
+		}
+
+		if o.dry {
+			fmt.Printf("%8dms %s opcode=%d %s\n", entry.OffsetMs, entry.Direction, entry.Opcode, entry.Data)
+			continue
+		}
+		if err := conn.WriteMessage(entry.Opcode, entry.Data); err != nil {
+			log.Fatalf("write: %s", err)
+		}
+		fmt.Printf("-> %s\n", entry.Data)
+	}
+
+	if conn != nil {
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "replay done"))
+		time.Sleep(time.Second)
+	}
+}
+
+func filterEntries(entries []*recording.Entry, o options) []*recording.Entry {
+	var filtered []*recording.Entry
+	for _, entry := range entries {
+		if entry.Opcode == websocket.CloseMessage {
+			continue
+		}
+		if o.role == "main" && entry.Direction == recording.DirectionMainIn {
+			filtered = append(filtered, entry)
+		}
+		if o.role == "sub" && entry.Direction == recording.DirectionSubIn && entry.SubDeviceId == o.subDeviceId {
+			filtered = append(filtered, entry)
+		}
+	}
+
+	// keep the first frame at offset 0 so playback starts right away
+	if len(filtered) > 0 {
+		first := filtered[0].OffsetMs
+		for _, entry := range filtered {
+			entry.OffsetMs -= first
+		}
+	}
+	return filtered
+}
+
+func dial(o options) (*websocket.Conn, error) {
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar, Timeout: 10 * time.Second}
+	body, _ := json.Marshal(map[string]string{"username": o.username, "password": o.password})
+	res, err := client.Post(o.server+"/api/v1/user/login", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("login responded %d", res.StatusCode)
+	}
+
+	u, err := url.Parse(o.server)
+	if err != nil {
+		return nil, err
+	}
+	u.Scheme = strings.Replace(u.Scheme, "http", "ws", 1)
+	query := url.Values{}
+	query.Set("main_device_id", fmt.Sprint(o.mainDeviceId))
+	if o.role == "main" {
+		u.Path = "/api/v1/communication/main"
+	} else {
+		u.Path = "/api/v1/communication/sub"
+		query.Set("sub_device_id", fmt.Sprint(o.subDeviceId))
+	}
+	u.RawQuery = query.Encode()
+
+	dialer := websocket.Dialer{Jar: jar, HandshakeTimeout: 10 * time.Second}
+	conn, _, err := dialer.Dial(u.String(), nil)
+	return conn, err
+}
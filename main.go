@@ -5,16 +5,41 @@ import (
 
 	"device-communication/src/config"
 	"device-communication/src/controller"
+	"device-communication/src/mqtt"
+	"device-communication/src/rpc"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
+	if err := config.GlobalConfig.Connect(); err != nil {
+		panic(fmt.Sprintf("connection init error: %s", err.Error()))
+	}
+
 	gin.SetMode(gin.ReleaseMode)
 	root := gin.New()
+	root.ContextWithFallback = true
 	root.SetTrustedProxies([]string{"192.168.1.1", "127.0.0.1"})
+	root.GET("/metrics", gin.WrapH(promhttp.Handler()))
 	apiv1 := root.Group("/api/v1")
 	controller.MiddlewareInit(apiv1)
+	if m := config.GlobalConfig.YamlConfig.Mqtt; m.Enabled {
+		go func() {
+			if err := mqtt.GetServer().ListenAndServe(fmt.Sprintf(":%d", m.Port)); err != nil {
+				panic(fmt.Sprintf("mqtt listener error: %s", err.Error()))
+			}
+		}()
+	}
+
+	if g := config.GlobalConfig.YamlConfig.Grpc; g.Enabled {
+		go func() {
+			if err := rpc.GetServer().ListenAndServe(fmt.Sprintf(":%d", g.Port)); err != nil {
+				panic(fmt.Sprintf("grpc listener error: %s", err.Error()))
+			}
+		}()
+	}
+
 	port := config.GlobalConfig.YamlConfig.Server.Port
 	root.Run(fmt.Sprintf(":%d", port))
 }
@@ -5,15 +5,26 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// SetUUID uses the trace id as request id when the request is traced.
 func SetUUID(c *gin.Context) {
 	requestID := uuid.New().String()
+	if spanContext := trace.SpanContextFromContext(c.Request.Context()); spanContext.HasTraceID() {
+		requestID = spanContext.TraceID().String()
+	}
 	c.Set("RequestID", requestID)
+	c.Header("X-Request-Id", requestID)
 }
 
 func GetUUID(c context.Context) string {
 	val := c.Value("RequestID")
 	uuid, _ := val.(string)
+	if uuid == "" {
+		if spanContext := trace.SpanContextFromContext(c); spanContext.HasTraceID() {
+			return spanContext.TraceID().String()
+		}
+	}
 	return uuid
 }
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/gin-contrib/sessions"
@@ -13,6 +15,7 @@ import (
 	"gopkg.in/yaml.v2"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/plugin/opentelemetry/tracing"
 )
 
 type config struct {
@@ -42,12 +45,99 @@ type config struct {
 		PoolSize     int    `yaml:"max_connection"`
 		MinIdleConns int    `yaml:"min_connection"`
 	} `yaml:"redis"`
+	Limit struct {
+		MaxMainDevice        int64 `yaml:"max_main_device_per_user"`
+		MaxSubDevice         int64 `yaml:"max_sub_device_per_main_device"`
+		MainDeviceIdle       int64 `yaml:"main_device_idle_second"`
+		MaxMessageByte       int64 `yaml:"max_message_byte"`
+		MaxPolicyIdle        int64 `yaml:"max_policy_idle_second"`
+		MaxPolicyMessageByte int64 `yaml:"max_policy_message_byte"`
+	} `yaml:"limit"`
+	Webhook struct {
+		Timeout      int `yaml:"timeout_second"`
+		MaxAttempts  int `yaml:"max_attempts"`
+		BackoffBase  int `yaml:"backoff_base_second"`
+		BackoffMax   int `yaml:"backoff_max_second"`
+		Workers      int `yaml:"workers"`
+		PollInterval int `yaml:"poll_interval_second"`
+		// AllowPrivateAddress lets webhooks call loopback and internal addresses, for local development only
+		AllowPrivateAddress bool `yaml:"allow_private_address"`
+	} `yaml:"webhook"`
+	Mqtt struct {
+		Enabled       bool `yaml:"enabled"`
+		Port          int  `yaml:"port"`
+		MaxPacketSize int  `yaml:"max_packet_byte"`
+	} `yaml:"mqtt"`
+	Grpc struct {
+		Enabled bool `yaml:"enabled"`
+		Port    int  `yaml:"port"`
+	} `yaml:"grpc"`
+	Recording struct {
+		Directory   string `yaml:"directory"`
+		MaxBytes    int64  `yaml:"max_byte"`
+		MaxDuration int    `yaml:"max_duration_second"`
+	} `yaml:"recording"`
+	Mail struct {
+		Sender    string `yaml:"sender"`
+		From      string `yaml:"from"`
+		Directory string `yaml:"directory"`
+		Smtp      struct {
+			Host     string `yaml:"host"`
+			Port     int    `yaml:"port"`
+			Username string `yaml:"username"`
+			Password string `yaml:"password"`
+		} `yaml:"smtp"`
+	} `yaml:"mail"`
+	PasswordReset struct {
+		Url                  string `yaml:"url"`
+		TokenTTL             int    `yaml:"token_ttl_second"`
+		Window               int    `yaml:"window_second"`
+		MaxRequestPerAccount int64  `yaml:"max_request_per_account"`
+		MaxRequestPerIp      int64  `yaml:"max_request_per_ip"`
+	} `yaml:"password_reset"`
+	EmailVerification struct {
+		Url                 string `yaml:"url"`
+		TokenTTL            int    `yaml:"token_ttl_second"`
+		Window              int    `yaml:"window_second"`
+		MaxResendPerAccount int64  `yaml:"max_resend_per_account"`
+		AllowUnverifiedBind bool   `yaml:"allow_unverified_bind"`
+		AllowUnverifiedRoom bool   `yaml:"allow_unverified_room"`
+	} `yaml:"email_verification"`
+	LoginLockout struct {
+		MaxFailurePerAccount int64 `yaml:"max_failure_per_account"`
+		MaxFailurePerIp      int64 `yaml:"max_failure_per_ip"`
+		Window               int   `yaml:"window_second"`
+		LockoutBase          int   `yaml:"lockout_base_second"`
+		LockoutMax           int   `yaml:"lockout_max_second"`
+		DeviceLoginCache     int   `yaml:"device_login_cache_second"`
+	} `yaml:"login_lockout"`
+	TwoFactor struct {
+		Issuer             string `yaml:"issuer"`
+		ChallengeTTL       int    `yaml:"challenge_ttl_second"`
+		RecoveryCodeNumber int    `yaml:"recovery_code_number"`
+	} `yaml:"two_factor"`
+	AccountDeletion struct {
+		Grace         int `yaml:"grace_second"`
+		PurgeInterval int `yaml:"purge_interval_second"`
+		PurgeBatch    int `yaml:"purge_batch"`
+	} `yaml:"account_deletion"`
+	Admin struct {
+		Usernames []string `yaml:"usernames"`
+	} `yaml:"admin"`
+	Tracing struct {
+		Exporter    string  `yaml:"exporter"`
+		Endpoint    string  `yaml:"endpoint"`
+		Insecure    bool    `yaml:"insecure"`
+		ServiceName string  `yaml:"service_name"`
+		SampleRatio float64 `yaml:"sample_ratio"`
+	} `yaml:"tracing"`
 }
 
 type allConfigs struct {
 	YamlConfig   config
 	DB           *gorm.DB
 	RedisSession redisStore.Store
+	Redis        *redis.Client
 }
 
 var GlobalConfig allConfigs
@@ -60,22 +150,54 @@ func init() {
 		panic(fmt.Sprintf("load yaml file error: %s", err.Error()))
 	}
 
-	fmt.Println("pg connection init...")
+	fmt.Println("pg client init...")
 	err = GlobalConfig.postgreInit()
 	if err != nil {
-		panic(fmt.Sprintf("pg connection init error: %s", err.Error()))
+		panic(fmt.Sprintf("pg client init error: %s", err.Error()))
 	}
 
-	fmt.Println("redis connection init...")
-	err = GlobalConfig.redisInit()
+	fmt.Println("redis client init...")
+	GlobalConfig.redisInit()
+	fmt.Println("Init done")
+}
+
+// Connect checks postgres and redis and opens the login session store, the server calls it once before serving.
+// Importing a package only builds the clients, so nothing is dialed until a query or Connect.
+func (a *allConfigs) Connect() error {
+	fmt.Println("pg connection check...")
+	sqlDB, err := a.DB.DB()
 	if err != nil {
-		panic(fmt.Sprintf("redis connection init error: %s", err.Error()))
+		return err
 	}
-	fmt.Println("Init done")
+	if err := sqlDB.Ping(); err != nil {
+		return fmt.Errorf("pg connection error: %w", err)
+	}
+
+	fmt.Println("redis connection check...")
+	if err := a.Redis.Ping(context.Background()).Err(); err != nil {
+		return fmt.Errorf("redis connection error: %w", err)
+	}
+
+	r := a.YamlConfig.Redis
+	s := a.YamlConfig.Server.Session
+	// sessions share the db of Redis so a login session can be revoked by its key
+	store, err := redisStore.NewStoreWithDB(r.PoolSize, "tcp", r.Address, r.Password, strconv.Itoa(r.DBNumber), []byte(s.SecretKey))
+	if err != nil {
+		return err
+	}
+	store.Options(sessions.Options{
+		Path:     "/",
+		MaxAge:   s.Age,
+		HttpOnly: s.HttpOnly,
+		Secure:   s.Secure,
+	})
+	a.RedisSession = store
+	fmt.Println("Connect done")
+	return nil
 }
 
 func (a *allConfigs) yamlInit() error {
-	file, err := os.Open("src/config/config.yaml")
+	file, err := os.Open(configPath())
 	if err != nil {
 		return err
 	}
@@ -87,6 +209,25 @@ func (a *allConfigs) yamlInit() error {
 	return err
 }
 
+// configPath is relative to the module root, tests run from the directory of their package.
+func configPath() string {
+	path := "src/config/config.yaml"
+	dir, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, path)); err == nil {
+			return filepath.Join(dir, path)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return path
+		}
+		dir = parent
+	}
+}
+
 func (a *allConfigs) postgreInit() error {
 	d := a.YamlConfig.Database
 	sslMode := ""
@@ -103,10 +244,14 @@ func (a *allConfigs) postgreInit() error {
 		d.Host, d.DBUser, d.DBPassword, dbName, d.Port, sslMode,
 	)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// the connection is checked by Connect
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{DisableAutomaticPing: true})
 	if err != nil {
 		log.Fatal("failed to connect to the database", dsn, err)
 	}
+	if err := db.Use(tracing.NewPlugin(tracing.WithoutMetrics())); err != nil {
+		return err
+	}
 
 	sqlDB, err := db.DB()
 	if err != nil {
@@ -119,34 +264,15 @@ func (a *allConfigs) postgreInit() error {
 	return nil
 }
 
-func (a *allConfigs) redisInit() error {
+func (a *allConfigs) redisInit() {
 	r := a.YamlConfig.Redis
-	s := a.YamlConfig.Server.Session
-	rdb := redis.NewClient(&redis.Options{
+	a.Redis = redis.NewClient(&redis.Options{
 		Addr:         r.Address,
 		Password:     r.Password,
 		DB:           r.DBNumber,
 		PoolSize:     r.PoolSize,
 		MinIdleConns: r.MinIdleConns,
 	})
-
-	if err := rdb.Ping(context.Background()).Err(); err != nil {
-		return err
-	}
-
-	store, err := redisStore.NewStore(r.PoolSize, "tcp", r.Address, r.Password, []byte(s.SecretKey))
-	if err != nil {
-		return err
-	}
-	store.Options(sessions.Options{
-		Path:     "/",
-		MaxAge:   s.Age,
-		HttpOnly: s.HttpOnly,
-		Secure:   s.Secure,
-	})
-
-	a.RedisSession = store
-	return nil
 }
 
 func (a *allConfigs) NewTransection() *gorm.DB {
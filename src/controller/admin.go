@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"device-communication/src/dto"
+	"device-communication/src/dtoError"
+	"device-communication/src/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var admin AdminController
+
+func adminGroupRouter(g *gin.RouterGroup) {
+	group := g.Group("/admin")
+	group.Use(GetAdminFilter())
+	group.POST("/user/unlock", admin.UnlockAccount)
+	group.GET("/lockout_event", admin.GetLockoutEvents)
+}
+
+type AdminController interface {
+	UnlockAccount(c *gin.Context)
+	GetLockoutEvents(c *gin.Context)
+}
+
+type adminControllerImpl struct {
+	errWarper    dtoError.ServiceErrorWarpper
+	adminService service.AdminService
+}
+
+func (ctl *adminControllerImpl) UnlockAccount(c *gin.Context) {
+	var req dto.UnlockAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		serviceErr := ctl.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	_, _, username := GetSessionValue(c)
+	req.Operator = username
+	res, serviceErr := ctl.adminService.UnlockAccount(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (ctl *adminControllerImpl) GetLockoutEvents(c *gin.Context) {
+	var req dto.GetLockoutEventsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		serviceErr := ctl.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	res, serviceErr := ctl.adminService.GetLockoutEvents(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func init() {
+	admin = &adminControllerImpl{
+		errWarper:    dtoError.GetServiceErrorWarpper(),
+		adminService: service.GetAdminService(),
+	}
+}
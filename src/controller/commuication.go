@@ -11,9 +11,14 @@ import (
 
 func communicationGroupRouter(g *gin.RouterGroup) {
 	group := g.Group("/communication")
-	group.Use(GetLoginFilter())
+	group.Use(GetDeviceAuthFilter())
 	group.GET("/main", communication.MainDeviceConnection)
 	group.GET("/sub", communication.SubDeviceConnection)
+	group.POST("/command", communication.SendCommand)
+	group.POST("/recording/start", communication.StartRecording)
+	group.POST("/recording/stop", communication.StopRecording)
+	group.GET("/recording", communication.GetRecordings)
+	group.GET("/recording/file", communication.GetRecordingFile)
 }
 
 var communication CommunicationController
@@ -21,6 +26,11 @@ var communication CommunicationController
 type CommunicationController interface {
 	MainDeviceConnection(c *gin.Context)
 	SubDeviceConnection(c *gin.Context)
+	SendCommand(c *gin.Context)
+	StartRecording(c *gin.Context)
+	StopRecording(c *gin.Context)
+	GetRecordings(c *gin.Context)
+	GetRecordingFile(c *gin.Context)
 }
 
 type communicationControllerImpl struct {
@@ -42,11 +52,16 @@ func (ctl *communicationControllerImpl) MainDeviceConnection(c *gin.Context) {
 		return
 	}
 
-	_, id, _ := GetSessionValue(c)
+	id, serviceErr := GetDeviceOwner(c, req.MainDeviceId, 0)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
 	req.UserId = id
+	req.LoginSessionId = GetLoginSessionId(c)
 	err := ctl.communication.MainDeviceConnection(c, &req, c.Writer, c.Request)
 	if err != nil {
-		c.JSON(err.ToJsonResponse())
+		c.JSON(err.ToJsonResponse(c))
 		return
 	}
 }
@@ -58,11 +73,126 @@ func (ctl *communicationControllerImpl) SubDeviceConnection(c *gin.Context) {
 		return
 	}
 
-	_, id, _ := GetSessionValue(c)
+	id, serviceErr := GetDeviceOwner(c, req.MainDeviceId, req.SubDeviceId)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
 	req.UserId = id
+	req.LoginSessionId = GetLoginSessionId(c)
 	err := ctl.communication.SubDeviceConnection(c, &req, c.Writer, c.Request)
 	if err != nil {
-		c.JSON(err.ToJsonResponse())
+		c.JSON(err.ToJsonResponse(c))
+		return
+	}
+}
+
+func (ctl *communicationControllerImpl) SendCommand(c *gin.Context) {
+	var req dto.MainDeviceCommandRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		serviceErr := ctl.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	id, serviceErr := GetDeviceOwner(c, req.MainDeviceId, 0)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	req.UserId = id
+	res, serviceErr := ctl.communication.SendCommandToMainDevice(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (ctl *communicationControllerImpl) StartRecording(c *gin.Context) {
+	var req dto.StartRecordingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		serviceErr := ctl.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	id, serviceErr := GetDeviceOwner(c, req.MainDeviceId, 0)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	req.UserId = id
+	res, serviceErr := ctl.communication.StartRecording(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (ctl *communicationControllerImpl) StopRecording(c *gin.Context) {
+	var req dto.StopRecordingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		serviceErr := ctl.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	id, serviceErr := GetDeviceOwner(c, req.MainDeviceId, 0)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	req.UserId = id
+	res, serviceErr := ctl.communication.StopRecording(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (ctl *communicationControllerImpl) GetRecordings(c *gin.Context) {
+	var req dto.GetRecordingsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		serviceErr := ctl.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	id, serviceErr := GetDeviceOwner(c, req.MainDeviceId, 0)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	req.UserId = id
+	res, serviceErr := ctl.communication.GetRecordings(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (ctl *communicationControllerImpl) GetRecordingFile(c *gin.Context) {
+	var req dto.GetRecordingFileRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		serviceErr := ctl.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	id, serviceErr := GetDeviceOwner(c, req.MainDeviceId, 0)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	req.UserId = id
+	path, serviceErr := ctl.communication.GetRecordingFile(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
 		return
 	}
+	c.FileAttachment(path, req.Name)
 }
@@ -0,0 +1,264 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"device-communication/client"
+	"device-communication/src/dtoError"
+	"device-communication/src/model"
+	"device-communication/src/repository"
+	"device-communication/src/service"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	testUserId       = 1
+	testMainDeviceId = 10
+	testMainToken    = service.DeviceTokenPrefix + "main"
+	testAlertsToken  = service.DeviceTokenPrefix + "alerts"
+	testSensorsToken = service.DeviceTokenPrefix + "sensors"
+)
+
+func hashTestToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type fakeCredentialRepository struct {
+	repository.CredentialRepository
+	credentials map[string]*model.DeviceCredential
+}
+
+func (f *fakeCredentialRepository) UseCredential(ctx context.Context, tokenHash string, now time.Time) (*model.DeviceCredential, bool, error) {
+	cred, ok := f.credentials[tokenHash]
+	return cred, ok, nil
+}
+
+// fakeDeviceRepository binds every sub device to the one main device of the test user.
+type fakeDeviceRepository struct {
+	repository.DeviceRepository
+}
+
+func (f *fakeDeviceRepository) GetMainDevice(ctx context.Context, userId uint64, mainDeviceId uint64) (*model.MainDevice, bool, error) {
+	if userId != testUserId || mainDeviceId != testMainDeviceId {
+		return nil, false, nil
+	}
+	return &model.MainDevice{Id: mainDeviceId, UserId: userId}, true, nil
+}
+
+func (f *fakeDeviceRepository) CheckSubDeviceBinding(ctx context.Context, userId uint64, mainDeviceId uint64, subDeviceId uint64) (bool, error) {
+	return userId == testUserId && mainDeviceId == testMainDeviceId, nil
+}
+
+type fakePolicyRepository struct {
+	repository.PolicyRepository
+}
+
+func (f *fakePolicyRepository) GetPolicy(ctx context.Context, mainDeviceId uint64) (*model.RoomPolicy, bool, error) {
+	return &model.RoomPolicy{
+		MainDeviceId:      mainDeviceId,
+		MaxSubDevice:      8,
+		IdleTimeoutSecond: 60,
+		AllowedFrameTypes: "request,response,error,subscribe,unsubscribe,control",
+		MaxMessageByte:    1 << 16,
+	}, true, nil
+}
+
+type fakeSchemaRepository struct {
+	repository.SchemaRepository
+}
+
+func (f *fakeSchemaRepository) GetSchema(ctx context.Context, userId uint64, platform string, version string) (*model.MessageSchema, bool, error) {
+	return nil, false, nil
+}
+
+type fakeUserRepository struct {
+	repository.UserRepository
+}
+
+func (f *fakeUserRepository) GetUserById(ctx context.Context, ID uint64) (*model.User, bool, error) {
+	return &model.User{Id: ID, EmailVerified: true}, true, nil
+}
+
+type nopDispatcher struct{}
+
+func (nopDispatcher) Publish(userId uint64, event string, data any) {}
+func (nopDispatcher) Invalidate(userId uint64)                      {}
+
+// trackingListener keeps the accepted connections, dropping them makes every device reconnect.
+type trackingListener struct {
+	net.Listener
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func (l *trackingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.mu.Lock()
+		l.conns = append(l.conns, conn)
+		l.mu.Unlock()
+	}
+	return conn, err
+}
+
+func (l *trackingListener) dropAll() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, conn := range l.conns {
+		conn.Close()
+	}
+	l.conns = nil
+}
+
+// newTestRelay mounts the communication routes on a service with fake repositories.
+func newTestRelay(t *testing.T) (*httptest.Server, *trackingListener) {
+	deviceRepo := &fakeDeviceRepository{}
+	credentialRepo := &fakeCredentialRepository{credentials: map[string]*model.DeviceCredential{
+		hashTestToken(testMainToken):    {UserId: testUserId, MainDeviceId: testMainDeviceId},
+		hashTestToken(testAlertsToken):  {UserId: testUserId, MainDeviceId: testMainDeviceId, SubDeviceId: 20},
+		hashTestToken(testSensorsToken): {UserId: testUserId, MainDeviceId: testMainDeviceId, SubDeviceId: 21},
+	}}
+
+	previousFilter, previousController := deviceAuthFilter, communication
+	t.Cleanup(func() {
+		deviceAuthFilter, communication = previousFilter, previousController
+	})
+	deviceAuthFilter = newDeviceAuthFilter(service.NewDeviceCredentialService(credentialRepo, deviceRepo))
+	communication = &communicationControllerImpl{
+		errWarper: dtoError.GetServiceErrorWarpper(),
+		communication: service.NewCommunicationSerivice(
+			&fakeUserRepository{}, deviceRepo, &fakeSchemaRepository{}, &fakePolicyRepository{}, nopDispatcher{}),
+	}
+
+	gin.SetMode(gin.TestMode)
+	root := gin.New()
+	root.ContextWithFallback = true
+	communicationGroupRouter(root.Group("/api/v1"))
+
+	server := httptest.NewUnstartedServer(root)
+	listener := &trackingListener{Listener: server.Listener}
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+	return server, listener
+}
+
+type stateRecorder chan client.State
+
+func (s stateRecorder) record(state client.State, err error) {
+	select {
+	case s <- state:
+	default:
+	}
+}
+
+func (s stateRecorder) wait(t *testing.T, want client.State) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case state := <-s:
+			if state == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("state %s was not reached", want)
+		}
+	}
+}
+
+func dialTestDevice(t *testing.T, ctx context.Context, server *httptest.Server, token string, subDeviceId uint64) (*client.Conn, stateRecorder) {
+	t.Helper()
+	states := make(stateRecorder, 64)
+	config := client.Config{
+		Server:            server.URL,
+		Token:             token,
+		HeartbeatInterval: -1,
+		MinBackoff:        10 * time.Millisecond,
+		MaxBackoff:        50 * time.Millisecond,
+		OnStateChange:     states.record,
+	}
+	var conn *client.Conn
+	var err error
+	if subDeviceId == 0 {
+		conn, err = client.DialMain(ctx, config, testMainDeviceId)
+	} else {
+		conn, err = client.DialSub(ctx, config, testMainDeviceId, subDeviceId)
+	}
+	if err != nil {
+		t.Fatalf("dial device %d: %v", subDeviceId, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	states.wait(t, client.StateConnected)
+	return conn, states
+}
+
+func expectTopic(t *testing.T, ctx context.Context, conn *client.Conn, topic string) {
+	t.Helper()
+	message, err := conn.Receive(ctx)
+	if err != nil {
+		t.Fatalf("Receive() = %v, want a message on %s", err, topic)
+	}
+	var body struct {
+		Topic string `json:"topic"`
+	}
+	if err := message.Decode(&body); err != nil || body.Topic != topic {
+		t.Fatalf("received %s, want a message on %s", message.Data, topic)
+	}
+}
+
+func TestClientThroughCommunicationRoutes(t *testing.T) {
+	server, listener := newTestRelay(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	main, mainStates := dialTestDevice(t, ctx, server, testMainToken, 0)
+	alerts, alertsStates := dialTestDevice(t, ctx, server, testAlertsToken, 20)
+	sensors, sensorsStates := dialTestDevice(t, ctx, server, testSensorsToken, 21)
+	if _, err := alerts.Subscribe(ctx, "alerts/#"); err != nil {
+		t.Fatalf("Subscribe() = %v", err)
+	}
+	if _, err := sensors.Subscribe(ctx, "sensors/#"); err != nil {
+		t.Fatalf("Subscribe() = %v", err)
+	}
+
+	// each sub device sees only its own topic, a wrong delivery would come first
+	publish := func() {
+		t.Helper()
+		if err := main.Publish("sensors/temperature", map[string]any{"celsius": 21.5}); err != nil {
+			t.Fatalf("Publish() = %v", err)
+		}
+		if err := main.Publish("alerts/fire", map[string]any{"room": "kitchen"}); err != nil {
+			t.Fatalf("Publish() = %v", err)
+		}
+	}
+	publish()
+	expectTopic(t, ctx, alerts, "alerts/fire")
+	expectTopic(t, ctx, sensors, "sensors/temperature")
+
+	// every device reconnects with its resume token and keeps its subscriptions
+	listener.dropAll()
+	for _, states := range []stateRecorder{mainStates, alertsStates, sensorsStates} {
+		states.wait(t, client.StateConnected)
+	}
+	publish()
+	expectTopic(t, ctx, alerts, "alerts/fire")
+	expectTopic(t, ctx, sensors, "sensors/temperature")
+
+	// a wrong token is refused by the device filter
+	_, err := client.DialSub(ctx, client.Config{Server: server.URL, Token: service.DeviceTokenPrefix + "unknown"}, testMainDeviceId, 22)
+	var statusErr *client.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("DialSub() with an unknown token = %v, want %d", err, http.StatusUnauthorized)
+	}
+}
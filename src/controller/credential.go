@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"device-communication/src/dto"
+	"device-communication/src/dtoError"
+	"device-communication/src/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var credential CredentialController
+
+func credentialGroupRouter(g *gin.RouterGroup) {
+	group := g.Group("/device/credential")
+	group.Use(GetLoginFilter())
+	group.POST("/rotate", credential.RotateCredential)
+	group.DELETE("/", credential.RevokeCredential)
+	group.GET("/", credential.GetCredentials)
+}
+
+type CredentialController interface {
+	RotateCredential(c *gin.Context)
+	RevokeCredential(c *gin.Context)
+	GetCredentials(c *gin.Context)
+}
+
+type credentialControllerImpl struct {
+	errWarper         dtoError.ServiceErrorWarpper
+	credentialService service.DeviceCredentialService
+}
+
+func (ctl *credentialControllerImpl) RotateCredential(c *gin.Context) {
+	var req dto.RotateDeviceCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		serviceErr := ctl.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	_, id, _ := GetSessionValue(c)
+	req.UserId = id
+	res, serviceErr := ctl.credentialService.RotateCredential(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (ctl *credentialControllerImpl) RevokeCredential(c *gin.Context) {
+	var req dto.RevokeDeviceCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		serviceErr := ctl.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	_, id, _ := GetSessionValue(c)
+	req.UserId = id
+	res, serviceErr := ctl.credentialService.RevokeCredential(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (ctl *credentialControllerImpl) GetCredentials(c *gin.Context) {
+	_, id, _ := GetSessionValue(c)
+	req := dto.GetDeviceCredentialsRequest{UserId: id}
+	res, serviceErr := ctl.credentialService.GetCredentials(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func init() {
+	credential = &credentialControllerImpl{
+		errWarper:         dtoError.GetServiceErrorWarpper(),
+		credentialService: service.GetDeviceCredentialService(),
+	}
+}
@@ -16,6 +16,8 @@ func deviceGroupRouter(g *gin.RouterGroup) {
 	group.Use(GetLoginFilter())
 	group.PUT("/main", device.BindMainDevice)
 	group.DELETE("/main", device.UnBindMainDevice)
+	group.GET("/main/policy", device.GetRoomPolicy)
+	group.PUT("/main/policy", device.UpdateRoomPolicy)
 	group.PUT("/sub", device.BindSubDevice)
 	group.DELETE("/sub", device.UnBindSubDevice)
 	group.GET("/", device.GetDevicesByUserId)
@@ -27,6 +29,8 @@ type DeviceController interface {
 	BindSubDevice(c *gin.Context)
 	UnBindSubDevice(c *gin.Context)
 	GetDevicesByUserId(c *gin.Context)
+	GetRoomPolicy(c *gin.Context)
+	UpdateRoomPolicy(c *gin.Context)
 }
 
 type deviceControllerImpl struct {
@@ -38,7 +42,7 @@ func (d *deviceControllerImpl) BindMainDevice(c *gin.Context) {
 	var req dto.BindMainDeviceRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		serviceErr := d.errWarper.NewParseParametersFailedError(err)
-		c.JSON(serviceErr.ToJsonResponse())
+		c.JSON(serviceErr.ToJsonResponse(c))
 		return
 	}
 
@@ -46,7 +50,7 @@ func (d *deviceControllerImpl) BindMainDevice(c *gin.Context) {
 	req.UserId = id
 	res, serviceErr := d.deviceService.BindMainDevice(c, &req)
 	if serviceErr != nil {
-		c.JSON(serviceErr.ToJsonResponse())
+		c.JSON(serviceErr.ToJsonResponse(c))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"result": res})
@@ -56,7 +60,7 @@ func (d *deviceControllerImpl) BindSubDevice(c *gin.Context) {
 	var req dto.BindSubDeviceRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		serviceErr := d.errWarper.NewParseParametersFailedError(err)
-		c.JSON(serviceErr.ToJsonResponse())
+		c.JSON(serviceErr.ToJsonResponse(c))
 		return
 	}
 
@@ -64,7 +68,7 @@ func (d *deviceControllerImpl) BindSubDevice(c *gin.Context) {
 	req.UserId = id
 	res, serviceErr := d.deviceService.BindSubDevice(c, &req)
 	if serviceErr != nil {
-		c.JSON(serviceErr.ToJsonResponse())
+		c.JSON(serviceErr.ToJsonResponse(c))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"result": res})
@@ -75,7 +79,7 @@ func (d *deviceControllerImpl) GetDevicesByUserId(c *gin.Context) {
 	req := dto.GetDevicesByUserIdRequest{UserId: id}
 	res, serviceErr := d.deviceService.GetDevicesByUserId(c, &req)
 	if serviceErr != nil {
-		c.JSON(serviceErr.ToJsonResponse())
+		c.JSON(serviceErr.ToJsonResponse(c))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"result": res})
@@ -85,7 +89,7 @@ func (d *deviceControllerImpl) UnBindMainDevice(c *gin.Context) {
 	var req dto.UnbindMainDeviceRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		serviceErr := d.errWarper.NewParseParametersFailedError(err)
-		c.JSON(serviceErr.ToJsonResponse())
+		c.JSON(serviceErr.ToJsonResponse(c))
 		return
 	}
 
@@ -93,7 +97,7 @@ func (d *deviceControllerImpl) UnBindMainDevice(c *gin.Context) {
 	req.UserId = id
 	res, serviceErr := d.deviceService.UnBindMainDevice(c, &req)
 	if serviceErr != nil {
-		c.JSON(serviceErr.ToJsonResponse())
+		c.JSON(serviceErr.ToJsonResponse(c))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"result": res})
@@ -103,7 +107,7 @@ func (d *deviceControllerImpl) UnBindSubDevice(c *gin.Context) {
 	var req dto.UnbindSubDeviceRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		serviceErr := d.errWarper.NewParseParametersFailedError(err)
-		c.JSON(serviceErr.ToJsonResponse())
+		c.JSON(serviceErr.ToJsonResponse(c))
 		return
 	}
 
@@ -111,7 +115,43 @@ func (d *deviceControllerImpl) UnBindSubDevice(c *gin.Context) {
 	req.UserId = id
 	res, serviceErr := d.deviceService.UnBindSubDevice(c, &req)
 	if serviceErr != nil {
-		c.JSON(serviceErr.ToJsonResponse())
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (d *deviceControllerImpl) GetRoomPolicy(c *gin.Context) {
+	var req dto.GetRoomPolicyRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		serviceErr := d.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	_, id, _ := GetSessionValue(c)
+	req.UserId = id
+	res, serviceErr := d.deviceService.GetRoomPolicy(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (d *deviceControllerImpl) UpdateRoomPolicy(c *gin.Context) {
+	var req dto.UpdateRoomPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		serviceErr := d.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	_, id, _ := GetSessionValue(c)
+	req.UserId = id
+	res, serviceErr := d.deviceService.UpdateRoomPolicy(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"result": res})
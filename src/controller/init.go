@@ -6,5 +6,9 @@ func MiddlewareInit(g *gin.RouterGroup) {
 	commonMiddleware(g)
 	userGroupRouter(g)
 	deviceGroupRouter(g)
+	credentialGroupRouter(g)
 	communicationGroupRouter(g)
+	webhookGroupRouter(g)
+	schemaGroupRouter(g)
+	adminGroupRouter(g)
 }
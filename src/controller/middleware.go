@@ -1,23 +1,41 @@
 package controller
 
 import (
+	"device-communication/src/common"
 	"device-communication/src/config"
+	"device-communication/src/dto"
+	"device-communication/src/dtoError"
+	"device-communication/src/metrics"
+	"device-communication/src/service"
+	"device-communication/src/telemetry"
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 var loginFilter gin.HandlerFunc
 var customRecoveryFilter gin.HandlerFunc
-var readLoginSession gin.HandlerFunc
+var metricsFilter gin.HandlerFunc
+var deviceAuthFilter gin.HandlerFunc
+var adminFilter gin.HandlerFunc
+
+const deviceIdentityKey = "device_identity"
 
 func commonMiddleware(g *gin.RouterGroup) {
 	g.Use(
+		otelgin.Middleware(telemetry.ServiceName()),
+		common.SetUUID,
+		metricsFilter,
 		customRecoveryFilter,
-		readLoginSession,
+		// the store is opened by config.Connect before the routes are mounted
+		sessions.Sessions("login", config.GlobalConfig.RedisSession),
 	)
 }
 
@@ -53,7 +71,60 @@ func init() {
 		c.Next()
 	}
 
-	readLoginSession = sessions.Sessions("login", config.GlobalConfig.RedisSession)
+	deviceAuthFilter = newDeviceAuthFilter(service.GetDeviceCredentialService())
+
+	adminFilter = func(c *gin.Context) {
+		ok, _, username := GetSessionValue(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not logged in"})
+			c.Abort()
+			return
+		}
+		if !slices.Contains(config.GlobalConfig.YamlConfig.Admin.Usernames, username) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+
+	metricsFilter = func(c *gin.Context) {
+		if c.IsWebsocket() {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HttpDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Observe(time.Since(start).Seconds())
+	}
+}
+
+// devices may present their own credential, everything else falls back to the login session
+func newDeviceAuthFilter(credential service.DeviceCredentialService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Query("token")
+		if header := c.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+			token = strings.TrimPrefix(header, "Bearer ")
+		}
+		if token == "" {
+			loginFilter(c)
+			return
+		}
+
+		identity, serviceErr := credential.AuthenticateDevice(c, token)
+		if serviceErr != nil {
+			c.JSON(serviceErr.ToJsonResponse(c))
+			c.Abort()
+			return
+		}
+		c.Set(deviceIdentityKey, identity)
+		c.Next()
+	}
 }
 
 func SetSessionValue(c *gin.Context, ID uint64, username string) (string, error) {
@@ -83,6 +154,52 @@ func GetSessionValue(c *gin.Context) (bool, uint64, string) {
 	return true, id, username
 }
 
+// ClearSession expires the login session, the store deletes it from redis.
+func ClearSession(c *gin.Context) error {
+	session := sessions.Default(c)
+	session.Clear()
+	session.Options(sessions.Options{Path: "/", MaxAge: -1})
+	return session.Save()
+}
+
+// GetLoginSessionId is empty when the request is authenticated by a device credential.
+func GetLoginSessionId(c *gin.Context) string {
+	if _, ok := GetDeviceIdentity(c); ok {
+		return ""
+	}
+	return sessions.Default(c).ID()
+}
+
+func GetDeviceIdentity(c *gin.Context) (*dto.DeviceIdentity, bool) {
+	value, ok := c.Get(deviceIdentityKey)
+	if !ok {
+		return nil, false
+	}
+	identity, ok := value.(*dto.DeviceIdentity)
+	return identity, ok
+}
+
+// GetDeviceOwner resolves the user of a request, a device credential only grants access to its own device.
+func GetDeviceOwner(c *gin.Context, mainDeviceId uint64, subDeviceId uint64) (uint64, *dtoError.ServiceError) {
+	identity, ok := GetDeviceIdentity(c)
+	if !ok {
+		_, id, _ := GetSessionValue(c)
+		return id, nil
+	}
+	if identity.MainDeviceId != mainDeviceId || identity.SubDeviceId != subDeviceId {
+		return 0, dtoError.GetServiceErrorWarpper().NewDeviceCredentialMismatchError()
+	}
+	return identity.UserId, nil
+}
+
+func GetDeviceAuthFilter() func(*gin.Context) {
+	return deviceAuthFilter
+}
+
+func GetAdminFilter() func(*gin.Context) {
+	return adminFilter
+}
+
 func GetLoginFilter() func(*gin.Context) {
 	return loginFilter
 }
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"device-communication/src/dto"
+	"device-communication/src/dtoError"
+	"device-communication/src/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var schema SchemaController
+
+func schemaGroupRouter(g *gin.RouterGroup) {
+	group := g.Group("/schema")
+	group.Use(GetLoginFilter())
+	group.PUT("/", schema.RegisterMessageSchema)
+	group.GET("/", schema.GetMessageSchemas)
+	group.DELETE("/", schema.DeleteMessageSchema)
+	group.GET("/violation", schema.GetSchemaViolations)
+}
+
+type SchemaController interface {
+	RegisterMessageSchema(c *gin.Context)
+	GetMessageSchemas(c *gin.Context)
+	DeleteMessageSchema(c *gin.Context)
+	GetSchemaViolations(c *gin.Context)
+}
+
+type schemaControllerImpl struct {
+	errWarper     dtoError.ServiceErrorWarpper
+	schemaService service.SchemaService
+}
+
+func init() {
+	schema = &schemaControllerImpl{
+		errWarper:     dtoError.GetServiceErrorWarpper(),
+		schemaService: service.GetSchemaService(),
+	}
+}
+
+func (s *schemaControllerImpl) RegisterMessageSchema(c *gin.Context) {
+	var req dto.RegisterMessageSchemaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		serviceErr := s.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	_, id, _ := GetSessionValue(c)
+	req.UserId = id
+	res, serviceErr := s.schemaService.RegisterMessageSchema(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (s *schemaControllerImpl) GetMessageSchemas(c *gin.Context) {
+	_, id, _ := GetSessionValue(c)
+	req := dto.GetMessageSchemasRequest{UserId: id}
+	res, serviceErr := s.schemaService.GetMessageSchemas(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (s *schemaControllerImpl) DeleteMessageSchema(c *gin.Context) {
+	var req dto.DeleteMessageSchemaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		serviceErr := s.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	_, id, _ := GetSessionValue(c)
+	req.UserId = id
+	res, serviceErr := s.schemaService.DeleteMessageSchema(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (s *schemaControllerImpl) GetSchemaViolations(c *gin.Context) {
+	var req dto.GetSchemaViolationsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		serviceErr := s.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	_, id, _ := GetSessionValue(c)
+	req.UserId = id
+	res, serviceErr := s.schemaService.GetSchemaViolations(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
@@ -14,26 +14,70 @@ func userGroupRouter(g *gin.RouterGroup) {
 	group := g.Group("/user")
 	group.POST("/register", user.Register)
 	group.POST("/login", user.Login)
+	group.POST("/login/2fa", user.VerifyLoginChallenge)
 	group.PUT("/reset_password", user.ResetPassword)
+	group.POST("/forgot_password", user.ForgotPassword)
+	group.PUT("/forgot_password/reset", user.ConfirmPasswordReset)
+	group.POST("/logout", GetLoginFilter(), user.Logout)
+	group.POST("/email/verify", user.VerifyEmail)
+	group.POST("/email/resend", GetLoginFilter(), user.ResendVerification)
+	group.GET("/me", GetLoginFilter(), user.GetProfile)
+	group.PATCH("/me", GetLoginFilter(), user.UpdateProfile)
+	group.DELETE("/me", GetLoginFilter(), user.DeleteAccount)
+	group.POST("/restore", user.RestoreAccount)
+
+	twoFactorGroup := group.Group("/2fa")
+	twoFactorGroup.Use(GetLoginFilter())
+	twoFactorGroup.POST("/enroll", user.EnrollTotp)
+	twoFactorGroup.POST("/confirm", user.ConfirmTotp)
+	twoFactorGroup.DELETE("/", user.DisableTotp)
+
+	sessionGroup := group.Group("/session")
+	sessionGroup.Use(GetLoginFilter())
+	sessionGroup.GET("/", user.GetSessions)
+	sessionGroup.DELETE("/", user.RevokeSession)
+	sessionGroup.DELETE("/all", user.RevokeAllSessions)
 }
 
 type UserController interface {
 	Register(c *gin.Context)
 	Login(c *gin.Context)
+	VerifyLoginChallenge(c *gin.Context)
+	GetProfile(c *gin.Context)
+	UpdateProfile(c *gin.Context)
+	DeleteAccount(c *gin.Context)
+	RestoreAccount(c *gin.Context)
+	EnrollTotp(c *gin.Context)
+	ConfirmTotp(c *gin.Context)
+	DisableTotp(c *gin.Context)
 	ResetPassword(c *gin.Context)
+	ForgotPassword(c *gin.Context)
+	ConfirmPasswordReset(c *gin.Context)
+	VerifyEmail(c *gin.Context)
+	ResendVerification(c *gin.Context)
+	Logout(c *gin.Context)
+	GetSessions(c *gin.Context)
+	RevokeSession(c *gin.Context)
+	RevokeAllSessions(c *gin.Context)
 }
 
 type userControllerImpl struct {
-	errWarper   dtoError.ServiceErrorWarpper
-	userService service.UserService
+	errWarper           dtoError.ServiceErrorWarpper
+	userService         service.UserService
+	loginSessionService service.LoginSessionService
+	twoFactorService    service.TwoFactorService
+	accountService      service.AccountService
 }
 
 var user UserController
 
 func init() {
 	user = &userControllerImpl{
-		errWarper:   dtoError.GetServiceErrorWarpper(),
-		userService: service.GetUserService(),
+		errWarper:           dtoError.GetServiceErrorWarpper(),
+		userService:         service.GetUserService(),
+		loginSessionService: service.GetLoginSessionService(),
+		twoFactorService:    service.GetTwoFactorService(),
+		accountService:      service.GetAccountService(),
 	}
 }
 
@@ -41,13 +85,13 @@ func (u *userControllerImpl) Register(c *gin.Context) {
 	var req dto.UserRegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		serviceErr := u.errWarper.NewParseParametersFailedError(err)
-		c.JSON(serviceErr.ToJsonResponse())
+		c.JSON(serviceErr.ToJsonResponse(c))
 		return
 	}
 
 	res, serviceErr := u.userService.UserRegisterService(c, &req)
 	if serviceErr != nil {
-		c.JSON(serviceErr.ToJsonResponse())
+		c.JSON(serviceErr.ToJsonResponse(c))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"result": res})
@@ -57,21 +101,115 @@ func (u *userControllerImpl) Login(c *gin.Context) {
 	var req dto.UserLoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		serviceErr := u.errWarper.NewParseParametersFailedError(err)
-		c.JSON(serviceErr.ToJsonResponse())
+		c.JSON(serviceErr.ToJsonResponse(c))
 		return
 	}
 
+	req.Ip = c.ClientIP()
 	res, serviceErr := service.GetUserService().UserLoginService(c, &req)
 	if serviceErr != nil {
-		c.JSON(serviceErr.ToJsonResponse())
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	if res.Challenge != "" {
+		c.JSON(http.StatusOK, gin.H{"result": dto.LoginChallengeResponse{TwoFactorRequired: true, Challenge: res.Challenge}})
+		return
+	}
+	u.startSession(c, res)
+}
+
+func (u *userControllerImpl) VerifyLoginChallenge(c *gin.Context) {
+	var req dto.VerifyLoginChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		serviceErr := u.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	req.Ip = c.ClientIP()
+	res, serviceErr := u.userService.VerifyLoginChallengeService(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
 		return
 	}
+	u.startSession(c, res)
+}
 
-	_, err := SetSessionValue(c, res.ID, res.Username)
+func (u *userControllerImpl) startSession(c *gin.Context, res *dto.UserLoginResponse) {
+	// the cookie may still carry the session of a previous login
+	if ok, previousId, _ := GetSessionValue(c); ok {
+		req := dto.LogoutRequest{UserId: previousId, SessionId: GetLoginSessionId(c)}
+		if serviceErr := u.loginSessionService.Logout(c, &req); serviceErr != nil {
+			c.JSON(serviceErr.ToJsonResponse(c))
+			return
+		}
+	}
+
+	sessionId, err := SetSessionValue(c, res.ID, res.Username)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{})
 		return
 	}
+
+	serviceErr := u.loginSessionService.RecordSession(c, &dto.RecordLoginSessionRequest{
+		UserId:    res.ID,
+		SessionId: sessionId,
+		Ip:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func (u *userControllerImpl) EnrollTotp(c *gin.Context) {
+	_, id, _ := GetSessionValue(c)
+	req := dto.EnrollTotpRequest{UserId: id}
+	res, serviceErr := u.twoFactorService.EnrollTotp(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (u *userControllerImpl) ConfirmTotp(c *gin.Context) {
+	var req dto.ConfirmTotpRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		serviceErr := u.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	_, id, _ := GetSessionValue(c)
+	req.UserId = id
+	res, serviceErr := u.twoFactorService.ConfirmTotp(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (u *userControllerImpl) DisableTotp(c *gin.Context) {
+	var req dto.DisableTotpRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		serviceErr := u.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	_, id, _ := GetSessionValue(c)
+	req.UserId = id
+	req.Ip = c.ClientIP()
+	serviceErr := u.twoFactorService.DisableTotp(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{})
 }
 
@@ -79,15 +217,231 @@ func (u *userControllerImpl) ResetPassword(c *gin.Context) {
 	var req dto.ResetPasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		serviceErr := u.errWarper.NewParseParametersFailedError(err)
-		c.JSON(serviceErr.ToJsonResponse())
+		c.JSON(serviceErr.ToJsonResponse(c))
 		return
 	}
 
+	req.Ip = c.ClientIP()
 	serviceErr := service.GetUserService().ResetPasswordService(c, &req)
 	if serviceErr != nil {
-		c.JSON(serviceErr.ToJsonResponse())
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func (u *userControllerImpl) ForgotPassword(c *gin.Context) {
+	var req dto.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		serviceErr := u.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	req.Ip = c.ClientIP()
+	serviceErr := u.userService.ForgotPasswordService(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func (u *userControllerImpl) ConfirmPasswordReset(c *gin.Context) {
+	var req dto.ConfirmPasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		serviceErr := u.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	serviceErr := u.userService.ConfirmPasswordResetService(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func (u *userControllerImpl) VerifyEmail(c *gin.Context) {
+	var req dto.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		serviceErr := u.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
 		return
 	}
 
+	serviceErr := u.userService.VerifyEmailService(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{})
 }
+
+func (u *userControllerImpl) ResendVerification(c *gin.Context) {
+	_, id, _ := GetSessionValue(c)
+	req := dto.ResendVerificationRequest{UserId: id}
+	serviceErr := u.userService.ResendVerificationService(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func (u *userControllerImpl) GetProfile(c *gin.Context) {
+	_, id, _ := GetSessionValue(c)
+	req := dto.GetProfileRequest{UserId: id}
+	res, serviceErr := u.userService.GetProfileService(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (u *userControllerImpl) UpdateProfile(c *gin.Context) {
+	var req dto.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		serviceErr := u.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	_, id, username := GetSessionValue(c)
+	req.UserId = id
+	res, serviceErr := u.userService.UpdateProfileService(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	if res.Username != username {
+		if _, err := SetSessionValue(c, res.ID, res.Username); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (u *userControllerImpl) DeleteAccount(c *gin.Context) {
+	var req dto.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		serviceErr := u.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	_, id, _ := GetSessionValue(c)
+	req.UserId = id
+	req.Ip = c.ClientIP()
+	res, serviceErr := u.accountService.DeleteAccount(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	if err := ClearSession(c); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (u *userControllerImpl) RestoreAccount(c *gin.Context) {
+	var req dto.RestoreAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		serviceErr := u.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	req.Ip = c.ClientIP()
+	serviceErr := u.accountService.RestoreAccount(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func (u *userControllerImpl) Logout(c *gin.Context) {
+	_, id, _ := GetSessionValue(c)
+	req := dto.LogoutRequest{UserId: id, SessionId: GetLoginSessionId(c)}
+	serviceErr := u.loginSessionService.Logout(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	if err := ClearSession(c); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+func (u *userControllerImpl) GetSessions(c *gin.Context) {
+	_, id, _ := GetSessionValue(c)
+	req := dto.GetLoginSessionsRequest{UserId: id, CurrentSessionId: GetLoginSessionId(c)}
+	res, serviceErr := u.loginSessionService.GetSessions(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (u *userControllerImpl) RevokeSession(c *gin.Context) {
+	var req dto.RevokeLoginSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		serviceErr := u.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	_, id, _ := GetSessionValue(c)
+	req.UserId = id
+	res, serviceErr := u.loginSessionService.RevokeSession(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	if req.SessionId == GetLoginSessionId(c) {
+		if err := ClearSession(c); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (u *userControllerImpl) RevokeAllSessions(c *gin.Context) {
+	var req dto.RevokeAllLoginSessionsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		serviceErr := u.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	_, id, _ := GetSessionValue(c)
+	req.UserId = id
+	req.CurrentSessionId = GetLoginSessionId(c)
+	res, serviceErr := u.loginSessionService.RevokeAllSessions(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	if !req.ExceptCurrent {
+		if err := ClearSession(c); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
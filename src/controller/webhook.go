@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"device-communication/src/dto"
+	"device-communication/src/dtoError"
+	"device-communication/src/model"
+	"device-communication/src/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var webhook WebhookController
+
+func webhookGroupRouter(g *gin.RouterGroup) {
+	group := g.Group("/webhook")
+	group.Use(GetLoginFilter())
+	group.PUT("/", webhook.CreateWebhook)
+	group.GET("/", webhook.GetWebhooks)
+	group.DELETE("/", webhook.DeleteWebhook)
+	group.GET("/delivery", webhook.GetWebhookDeliveries)
+	group.GET("/dead_letter", webhook.GetDeadLetters)
+	group.POST("/delivery/redeliver", webhook.RedeliverWebhook)
+}
+
+type WebhookController interface {
+	CreateWebhook(c *gin.Context)
+	GetWebhooks(c *gin.Context)
+	DeleteWebhook(c *gin.Context)
+	GetWebhookDeliveries(c *gin.Context)
+	GetDeadLetters(c *gin.Context)
+	RedeliverWebhook(c *gin.Context)
+}
+
+type webhookControllerImpl struct {
+	errWarper      dtoError.ServiceErrorWarpper
+	webhookService service.WebhookService
+}
+
+func init() {
+	webhook = &webhookControllerImpl{
+		errWarper:      dtoError.GetServiceErrorWarpper(),
+		webhookService: service.GetWebhookService(),
+	}
+}
+
+func (w *webhookControllerImpl) CreateWebhook(c *gin.Context) {
+	var req dto.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		serviceErr := w.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	_, id, _ := GetSessionValue(c)
+	req.UserId = id
+	res, serviceErr := w.webhookService.CreateWebhook(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (w *webhookControllerImpl) GetWebhooks(c *gin.Context) {
+	_, id, _ := GetSessionValue(c)
+	req := dto.GetWebhooksRequest{UserId: id}
+	res, serviceErr := w.webhookService.GetWebhooks(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (w *webhookControllerImpl) DeleteWebhook(c *gin.Context) {
+	var req dto.DeleteWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		serviceErr := w.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	_, id, _ := GetSessionValue(c)
+	req.UserId = id
+	res, serviceErr := w.webhookService.DeleteWebhook(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (w *webhookControllerImpl) GetWebhookDeliveries(c *gin.Context) {
+	var req dto.GetWebhookDeliveriesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		serviceErr := w.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	_, id, _ := GetSessionValue(c)
+	req.UserId = id
+	res, serviceErr := w.webhookService.GetWebhookDeliveries(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (w *webhookControllerImpl) GetDeadLetters(c *gin.Context) {
+	var req dto.GetWebhookDeliveriesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		serviceErr := w.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	_, id, _ := GetSessionValue(c)
+	req.UserId = id
+	req.Status = model.WebhookDeliveryStatusDead
+	res, serviceErr := w.webhookService.GetWebhookDeliveries(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+func (w *webhookControllerImpl) RedeliverWebhook(c *gin.Context) {
+	var req dto.RedeliverWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		serviceErr := w.errWarper.NewParseParametersFailedError(err)
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+
+	_, id, _ := GetSessionValue(c)
+	req.UserId = id
+	res, serviceErr := w.webhookService.RedeliverWebhook(c, &req)
+	if serviceErr != nil {
+		c.JSON(serviceErr.ToJsonResponse(c))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
@@ -0,0 +1,31 @@
+package dto
+
+import "time"
+
+type UnlockAccountRequest struct {
+	Operator string
+	Username string `json:"username" binding:"required"`
+	Ip       string `json:"ip"`
+}
+
+type UnlockAccountResponse struct {
+	Ok bool `json:"ok"`
+}
+
+type GetLockoutEventsRequest struct {
+	Username string `form:"username"`
+}
+
+type GetLockoutEventsResponse struct {
+	Events []*LockoutEvent `json:"events"`
+}
+
+type LockoutEvent struct {
+	Id          uint64     `json:"id"`
+	Username    string     `json:"username"`
+	Ip          string     `json:"ip"`
+	Event       string     `json:"event"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	Operator    string     `json:"operator,omitempty"`
+	CreateTime  time.Time  `json:"create_time"`
+}
@@ -1,12 +1,124 @@
 package dto
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type MainDeviceConnectionRequest struct {
-	UserId       uint64 `binding:"-"`
-	MainDeviceId uint64 `form:"main_device_id" binding:"required"`
+	UserId         uint64 `binding:"-"`
+	MainDeviceId   uint64 `form:"main_device_id" binding:"required"`
+	ResumeToken    string `form:"resume_token"`
+	LoginSessionId string `binding:"-"`
 }
 
 type SubDeviceConnectionRequest struct {
+	UserId         uint64 `binding:"-"`
+	MainDeviceId   uint64 `form:"main_device_id" binding:"required"`
+	SubDeviceId    uint64 `form:"sub_device_id" binding:"required"`
+	ResumeToken    string `form:"resume_token"`
+	LoginSessionId string `binding:"-"`
+}
+
+type MainDeviceCommandRequest struct {
+	UserId       uint64          `binding:"-"`
+	MainDeviceId uint64          `json:"main_device_id" binding:"required"`
+	Method       string          `json:"method" binding:"required"`
+	Params       json.RawMessage `json:"params"`
+	TimeoutMs    int64           `json:"timeout_ms" binding:"required,min=1,max=60000"`
+}
+
+type MainDeviceCommandResponse struct {
+	Id     string          `json:"id"`
+	Result json.RawMessage `json:"result"`
+}
+
+const (
+	DeviceFrameTypeRequest     = "request"
+	DeviceFrameTypeResponse    = "response"
+	DeviceFrameTypeError       = "error"
+	DeviceFrameTypeSubscribe   = "subscribe"
+	DeviceFrameTypeUnsubscribe = "unsubscribe"
+	// control frames from the main device are answered by the server and never relayed
+	DeviceFrameTypeControl = "control"
+)
+
+const (
+	ControlMethodListSubs  = "list_subs"
+	ControlMethodKickSub   = "kick_sub"
+	ControlMethodGetPolicy = "get_policy"
+	ControlMethodCloseRoom = "close_room"
+)
+
+type DeviceFrame struct {
+	Type      string          `json:"type"`
+	Id        string          `json:"id,omitempty"`
+	Topic     string          `json:"topic,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	TimeoutMs int64           `json:"timeout_ms,omitempty"`
+}
+
+type SubscriptionResult struct {
+	Topics []string `json:"topics"`
+}
+
+type ListSubsResult struct {
+	Subs []*ConnectedSubDevice `json:"subs"`
+}
+
+type ConnectedSubDevice struct {
+	SubDeviceId uint64   `json:"sub_device_id"`
+	Topics      []string `json:"topics"`
+}
+
+type KickSubParams struct {
+	SubDeviceId uint64 `json:"sub_device_id"`
+	Reason      string `json:"reason"`
+}
+
+type ControlResult struct {
+	Ok bool `json:"ok"`
+}
+
+type StartRecordingRequest struct {
+	UserId       uint64 `binding:"-"`
+	MainDeviceId uint64 `json:"main_device_id" binding:"required"`
+}
+
+type StartRecordingResponse struct {
+	Name string `json:"name"`
+}
+
+type StopRecordingRequest struct {
+	UserId       uint64 `binding:"-"`
+	MainDeviceId uint64 `json:"main_device_id" binding:"required"`
+}
+
+type StopRecordingResponse struct {
+	Name string `json:"name"`
+}
+
+type GetRecordingsRequest struct {
+	UserId       uint64 `binding:"-"`
+	MainDeviceId uint64 `form:"main_device_id"`
+}
+
+type GetRecordingsResponse struct {
+	Recordings []*Recording `json:"recordings"`
+}
+
+type Recording struct {
+	Name         string    `json:"name"`
+	MainDeviceId uint64    `json:"main_device_id"`
+	Size         int64     `json:"size"`
+	UpdateTime   time.Time `json:"update_time"`
+}
+
+type GetRecordingFileRequest struct {
 	UserId       uint64 `binding:"-"`
-	MainDeviceId uint64 `form:"main_device_id" binding:"required"`
-	SubDeviceId  uint64 `form:"sub_device_id" binding:"required"`
+	MainDeviceId uint64 `form:"main_device_id"`
+	Name         string `form:"name" binding:"required"`
 }
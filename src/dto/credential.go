@@ -0,0 +1,47 @@
+package dto
+
+import "time"
+
+type DeviceIdentity struct {
+	UserId       uint64
+	MainDeviceId uint64
+	SubDeviceId  uint64
+}
+
+type RotateDeviceCredentialRequest struct {
+	UserId       uint64
+	MainDeviceId uint64 `json:"main_device_id" binding:"required"`
+	SubDeviceId  uint64 `json:"sub_device_id"`
+}
+
+type RotateDeviceCredentialResponse struct {
+	CredentialId uint64 `json:"credential_id"`
+	Token        string `json:"token"`
+}
+
+type RevokeDeviceCredentialRequest struct {
+	UserId       uint64
+	MainDeviceId uint64 `json:"main_device_id" binding:"required"`
+	SubDeviceId  uint64 `json:"sub_device_id"`
+}
+
+type RevokeDeviceCredentialResponse struct {
+	Ok bool `json:"ok"`
+}
+
+type GetDeviceCredentialsRequest struct {
+	UserId uint64
+}
+
+type GetDeviceCredentialsResponse struct {
+	Credentials []*DeviceCredential `json:"credentials"`
+}
+
+type DeviceCredential struct {
+	Id           uint64     `json:"id"`
+	MainDeviceId uint64     `json:"main_device_id"`
+	SubDeviceId  uint64     `json:"sub_device_id,omitempty"`
+	TokenPrefix  string     `json:"token_prefix"`
+	LastUsedTime *time.Time `json:"last_used_time"`
+	CreateTime   time.Time  `json:"create_time"`
+}
@@ -10,6 +10,7 @@ type BindMainDeviceRequest struct {
 type BindMainDeviceResponse struct {
 	Ok           bool   `json:"ok"`
 	MainDeviceId uint64 `json:"main_device_id"`
+	Token        string `json:"token"`
 }
 
 type UnbindMainDeviceRequest struct {
@@ -33,6 +34,7 @@ type BindSubDeviceRequest struct {
 type BindSubDeviceResponse struct {
 	Ok          bool   `json:"ok"`
 	SubDeviceId uint64 `json:"sub_device_id"`
+	Token       string `json:"token"`
 }
 
 type UnbindSubDeviceRequest struct {
@@ -68,3 +70,34 @@ type SubDevice struct {
 	Version      string `json:"version"`
 	DeviceId     string `json:"device_id"`
 }
+
+type GetRoomPolicyRequest struct {
+	UserId       uint64 `binding:"-"`
+	MainDeviceId uint64 `form:"main_device_id" binding:"required"`
+}
+
+type GetRoomPolicyResponse struct {
+	Policy *RoomPolicy `json:"policy"`
+}
+
+type UpdateRoomPolicyRequest struct {
+	UserId            uint64   `binding:"-"`
+	MainDeviceId      uint64   `json:"main_device_id" binding:"required"`
+	MaxSubDevice      int64    `json:"max_sub_device" binding:"required,min=1"`
+	IdleTimeoutSecond int64    `json:"idle_timeout_second" binding:"required,min=10"`
+	AllowedFrameTypes []string `json:"allowed_frame_types" binding:"required,dive,oneof=request response error subscribe unsubscribe control"`
+	MaxMessageByte    int64    `json:"max_message_byte" binding:"required,min=1"`
+}
+
+type UpdateRoomPolicyResponse struct {
+	Policy *RoomPolicy `json:"policy"`
+}
+
+type RoomPolicy struct {
+	MainDeviceId      uint64   `json:"main_device_id"`
+	MaxSubDevice      int64    `json:"max_sub_device"`
+	IdleTimeoutSecond int64    `json:"idle_timeout_second"`
+	AllowedFrameTypes []string `json:"allowed_frame_types"`
+	MaxMessageByte    int64    `json:"max_message_byte"`
+	Default           bool     `json:"default"`
+}
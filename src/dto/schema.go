@@ -0,0 +1,64 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type RegisterMessageSchemaRequest struct {
+	UserId   uint64
+	Platform string          `json:"platform" binding:"required"`
+	Version  string          `json:"version" binding:"required"`
+	Schema   json.RawMessage `json:"schema" binding:"required"`
+	Policy   string          `json:"policy" binding:"required,oneof=reject flag quarantine"`
+}
+
+type RegisterMessageSchemaResponse struct {
+	SchemaId uint64 `json:"schema_id"`
+}
+
+type GetMessageSchemasRequest struct {
+	UserId uint64
+}
+
+type GetMessageSchemasResponse struct {
+	Schemas []*MessageSchema `json:"schemas"`
+}
+
+type MessageSchema struct {
+	Id         uint64          `json:"id"`
+	Platform   string          `json:"platform"`
+	Version    string          `json:"version"`
+	Schema     json.RawMessage `json:"schema"`
+	Policy     string          `json:"policy"`
+	UpdateTime time.Time       `json:"update_time"`
+}
+
+type DeleteMessageSchemaRequest struct {
+	UserId   uint64
+	SchemaId uint64 `json:"schema_id" binding:"required"`
+}
+
+type DeleteMessageSchemaResponse struct {
+	Ok bool `json:"ok"`
+}
+
+type GetSchemaViolationsRequest struct {
+	UserId       uint64 `binding:"-"`
+	MainDeviceId uint64 `form:"main_device_id"`
+	Action       string `form:"action" binding:"omitempty,oneof=flag quarantine"`
+}
+
+type GetSchemaViolationsResponse struct {
+	Violations []*SchemaViolation `json:"violations"`
+}
+
+type SchemaViolation struct {
+	Id           uint64    `json:"id"`
+	SchemaId     uint64    `json:"schema_id"`
+	MainDeviceId uint64    `json:"main_device_id"`
+	Action       string    `json:"action"`
+	Message      string    `json:"message"`
+	Reason       string    `json:"reason"`
+	CreateTime   time.Time `json:"create_time"`
+}
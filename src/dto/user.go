@@ -1,5 +1,7 @@
 package dto
 
+import "time"
+
 type UserRegisterRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
@@ -14,15 +16,160 @@ type UserRegisterResponse struct {
 type UserLoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	Ip       string
 }
 
 type UserLoginResponse struct {
 	ID       uint64 `json:"id" binding:"required"`
 	Username string `json:"username" binding:"required"`
+	// Challenge is set instead of logging in when the user has two-factor authentication enabled
+	Challenge string `json:"challenge"`
+}
+
+type LoginChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	Challenge         string `json:"challenge"`
+}
+
+type VerifyLoginChallengeRequest struct {
+	Challenge string `json:"challenge" binding:"required"`
+	Code      string `json:"code" binding:"required"`
+	Ip        string
+}
+
+type GetProfileRequest struct {
+	UserId uint64
+}
+
+type ProfileResponse struct {
+	ID            uint64    `json:"id"`
+	Username      string    `json:"username"`
+	Name          string    `json:"name"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	CreateTime    time.Time `json:"create_time"`
+}
+
+// UpdateProfileRequest only changes the fields that are present.
+type UpdateProfileRequest struct {
+	UserId   uint64
+	Username *string `json:"username" binding:"omitempty,min=1,max=50"`
+	Name     *string `json:"name" binding:"omitempty,min=1,max=50"`
+	Email    *string `json:"email" binding:"omitempty,email"`
+}
+
+type DeleteAccountRequest struct {
+	UserId   uint64
+	Password string `json:"password" binding:"required"`
+	// Code is required when two-factor authentication is enabled
+	Code string `json:"code"`
+	Ip   string
+}
+
+type DeleteAccountResponse struct {
+	PurgeTime time.Time `json:"purge_time"`
+}
+
+type RestoreAccountRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	Ip       string
 }
 
 type ResetPasswordRequest struct {
 	Username    string `json:"username" binding:"required"`
 	Password    string `json:"password" binding:"required"`
 	NewPassword string `json:"new_password" binding:"required"`
+	Ip          string
+}
+
+type RecordLoginSessionRequest struct {
+	UserId    uint64
+	SessionId string
+	Ip        string
+	UserAgent string
+}
+
+type LogoutRequest struct {
+	UserId    uint64
+	SessionId string
+}
+
+type GetLoginSessionsRequest struct {
+	UserId           uint64
+	CurrentSessionId string
+}
+
+type GetLoginSessionsResponse struct {
+	Sessions []*LoginSession `json:"sessions"`
+}
+
+type LoginSession struct {
+	SessionId  string    `json:"session_id"`
+	CreateTime time.Time `json:"create_time"`
+	Ip         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	Current    bool      `json:"current"`
+}
+
+type RevokeLoginSessionRequest struct {
+	UserId    uint64
+	SessionId string `json:"session_id" binding:"required"`
+}
+
+type RevokeLoginSessionResponse struct {
+	Ok bool `json:"ok"`
+}
+
+type RevokeAllLoginSessionsRequest struct {
+	UserId           uint64
+	CurrentSessionId string
+	ExceptCurrent    bool `form:"except_current"`
+}
+
+type RevokeAllLoginSessionsResponse struct {
+	Count int `json:"count"`
+}
+
+type ForgotPasswordRequest struct {
+	Username string `json:"username" binding:"required"`
+	Ip       string
+}
+
+type ConfirmPasswordResetRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResendVerificationRequest struct {
+	UserId uint64
+}
+
+type EnrollTotpRequest struct {
+	UserId uint64
+}
+
+type EnrollTotpResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningUri string `json:"provisioning_uri"`
+}
+
+type ConfirmTotpRequest struct {
+	UserId uint64
+	Code   string `json:"code" binding:"required"`
+}
+
+type ConfirmTotpResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type DisableTotpRequest struct {
+	UserId   uint64
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+	Ip       string
 }
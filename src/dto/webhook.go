@@ -0,0 +1,70 @@
+package dto
+
+import "time"
+
+type CreateWebhookRequest struct {
+	UserId uint64
+	Url    string   `json:"url" binding:"required,url"`
+	Events []string `json:"events" binding:"required,min=1"`
+}
+
+type CreateWebhookResponse struct {
+	WebhookId uint64 `json:"webhook_id"`
+	Secret    string `json:"secret"`
+}
+
+type GetWebhooksRequest struct {
+	UserId uint64
+}
+
+type GetWebhooksResponse struct {
+	Webhooks []*Webhook `json:"webhooks"`
+}
+
+type Webhook struct {
+	Id         uint64    `json:"id"`
+	Url        string    `json:"url"`
+	Events     []string  `json:"events"`
+	CreateTime time.Time `json:"create_time"`
+}
+
+type DeleteWebhookRequest struct {
+	UserId    uint64
+	WebhookId uint64 `json:"webhook_id" binding:"required"`
+}
+
+type DeleteWebhookResponse struct {
+	Ok bool `json:"ok"`
+}
+
+type GetWebhookDeliveriesRequest struct {
+	UserId    uint64 `binding:"-"`
+	WebhookId uint64 `form:"webhook_id"`
+	Status    string `form:"status" binding:"omitempty,oneof=pending succeeded dead"`
+}
+
+type GetWebhookDeliveriesResponse struct {
+	Deliveries []*WebhookDelivery `json:"deliveries"`
+}
+
+type WebhookDelivery struct {
+	Id          uint64    `json:"id"`
+	WebhookId   uint64    `json:"webhook_id"`
+	Event       string    `json:"event"`
+	Payload     string    `json:"payload"`
+	Status      string    `json:"status"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error"`
+	NextRetryAt time.Time `json:"next_retry_time"`
+	CreateTime  time.Time `json:"create_time"`
+	UpdateTime  time.Time `json:"update_time"`
+}
+
+type RedeliverWebhookRequest struct {
+	UserId     uint64
+	DeliveryId uint64 `json:"delivery_id" binding:"required"`
+}
+
+type RedeliverWebhookResponse struct {
+	Ok bool `json:"ok"`
+}
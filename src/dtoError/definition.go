@@ -1,19 +1,35 @@
 package dtoError
 
 import (
+	"context"
+	"device-communication/src/common"
+	"math"
+	"strconv"
+	"time"
+
 	"github.com/gin-gonic/gin"
 )
 
 type ServiceError struct {
+	Type           string
 	StatusCode     int
 	InternalError  error
 	ExtrenalReason string
+	RetryAfter     time.Duration
 }
 
-func (s *ServiceError) ToJsonResponse() (statusCode int, H *gin.H) {
+func (s *ServiceError) ToJsonResponse(ctx context.Context) (statusCode int, H *gin.H) {
 	statusCode = s.StatusCode
 	H = &gin.H{
-		"reason": s.ExtrenalReason,
+		"reason":     s.ExtrenalReason,
+		"request_id": common.GetUUID(ctx),
+	}
+	if s.RetryAfter > 0 {
+		second := int64(math.Ceil(s.RetryAfter.Seconds()))
+		(*H)["retry_after_second"] = second
+		if c, ok := ctx.(*gin.Context); ok {
+			c.Header("Retry-After", strconv.FormatInt(second, 10))
+		}
 	}
 	return
 }
@@ -24,15 +40,23 @@ type ServiceErrorWarpper interface {
 	userErrorWarpper
 	dbErrorWarpper
 	deviceErrorWarpper
+	webhookErrorWarpper
+	schemaErrorWarpper
+	recordingErrorWarpper
+	credentialErrorWarpper
 }
 
 type websocketErrorWarpper interface {
 	NewWebsocketUpgradeFailedError(err error) *ServiceError
 	NewRoomCreateFailedError(reason string) *ServiceError
+	NewMainDeviceOfflineError() *ServiceError
+	NewDeviceCommandTimeoutError(timeout time.Duration) *ServiceError
+	NewDeviceCommandFailedError(reason string) *ServiceError
 }
 
 type commonErrorWarpper interface {
 	NewParseParametersFailedError(err error) *ServiceError
+	NewTooManyRequestsError(retryAfter time.Duration) *ServiceError
 }
 
 type userErrorWarpper interface {
@@ -42,6 +66,16 @@ type userErrorWarpper interface {
 	NewUsernameExist(username string) *ServiceError
 	NewUserNotExist(Id uint64) *ServiceError
 	NewPasswordInvaildError(err error) *ServiceError
+	NewLoginSessionNotFoundError() *ServiceError
+	NewPasswordResetTokenInvalidError() *ServiceError
+	NewEmailNotVerifiedError() *ServiceError
+	NewEmailAlreadyVerifiedError() *ServiceError
+	NewEmailVerificationTokenInvalidError() *ServiceError
+	NewAccountLockedError(retryAfter time.Duration) *ServiceError
+	NewTotpAlreadyEnabledError() *ServiceError
+	NewTotpNotEnrolledError() *ServiceError
+	NewTwoFactorCodeInvalidError() *ServiceError
+	NewLoginChallengeInvalidError() *ServiceError
 }
 
 type dbErrorWarpper interface {
@@ -56,4 +90,30 @@ type deviceErrorWarpper interface {
 	NewSubDeviceTooManyError(count int64) *ServiceError
 	NewMainDeviceNotBindingError() *ServiceError
 	NewSubDeviceNotBindingError() *ServiceError
+	NewRoomPolicyInvalidError(reason string) *ServiceError
+}
+
+type webhookErrorWarpper interface {
+	NewWebhookTooManyError(count int64) *ServiceError
+	NewWebhookNotFoundError() *ServiceError
+	NewWebhookUrlNotAllowedError(err error) *ServiceError
+	NewWebhookDeliveryNotFoundError() *ServiceError
+}
+
+type schemaErrorWarpper interface {
+	NewSchemaInvalidError(err error) *ServiceError
+	NewSchemaNotFoundError() *ServiceError
+}
+
+type recordingErrorWarpper interface {
+	NewRecordingAlreadyStartedError() *ServiceError
+	NewRecordingNotStartedError() *ServiceError
+	NewRecordingNotFoundError() *ServiceError
+	NewRecordingFailedError(err error) *ServiceError
+}
+
+type credentialErrorWarpper interface {
+	NewDeviceCredentialInvalidError() *ServiceError
+	NewDeviceCredentialMismatchError() *ServiceError
+	NewDeviceCredentialNotFoundError() *ServiceError
 }
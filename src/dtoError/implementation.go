@@ -3,6 +3,7 @@ package dtoError
 import (
 	"fmt"
 	"net/http"
+	"time"
 )
 
 var s ServiceErrorWarpper = &ServiceErrorWarpperImpl{}
@@ -11,6 +12,7 @@ type ServiceErrorWarpperImpl struct{}
 
 func (s *ServiceErrorWarpperImpl) NewRoomCreateFailedError(reason string) *ServiceError {
 	return &ServiceError{
+		Type:           "room_create_failed",
 		StatusCode:     http.StatusBadRequest,
 		InternalError:  nil,
 		ExtrenalReason: reason,
@@ -19,14 +21,43 @@ func (s *ServiceErrorWarpperImpl) NewRoomCreateFailedError(reason string) *Servi
 
 func (s *ServiceErrorWarpperImpl) NewWebsocketUpgradeFailedError(err error) *ServiceError {
 	return &ServiceError{
+		Type:           "websocket_upgrade_failed",
 		StatusCode:     http.StatusInternalServerError,
 		InternalError:  nil,
 		ExtrenalReason: "websocket upgrade failed",
 	}
 }
 
+func (s *ServiceErrorWarpperImpl) NewMainDeviceOfflineError() *ServiceError {
+	return &ServiceError{
+		Type:           "main_device_offline",
+		StatusCode:     http.StatusServiceUnavailable,
+		InternalError:  nil,
+		ExtrenalReason: "main device offline",
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewDeviceCommandTimeoutError(timeout time.Duration) *ServiceError {
+	return &ServiceError{
+		Type:           "device_command_timeout",
+		StatusCode:     http.StatusGatewayTimeout,
+		InternalError:  nil,
+		ExtrenalReason: fmt.Sprintf("main device did not reply within %s", timeout),
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewDeviceCommandFailedError(reason string) *ServiceError {
+	return &ServiceError{
+		Type:           "device_command_failed",
+		StatusCode:     http.StatusBadGateway,
+		InternalError:  nil,
+		ExtrenalReason: fmt.Sprintf("main device error: %s", reason),
+	}
+}
+
 func (s *ServiceErrorWarpperImpl) NewMainDeviceNotBindingError() *ServiceError {
 	return &ServiceError{
+		Type:           "main_device_not_binding",
 		StatusCode:     http.StatusNotFound,
 		InternalError:  nil,
 		ExtrenalReason: "device not binding",
@@ -35,6 +66,7 @@ func (s *ServiceErrorWarpperImpl) NewMainDeviceNotBindingError() *ServiceError {
 
 func (s *ServiceErrorWarpperImpl) NewSubDeviceNotBindingError() *ServiceError {
 	return &ServiceError{
+		Type:           "sub_device_not_binding",
 		StatusCode:     http.StatusNotFound,
 		InternalError:  nil,
 		ExtrenalReason: "device not binding",
@@ -43,6 +75,7 @@ func (s *ServiceErrorWarpperImpl) NewSubDeviceNotBindingError() *ServiceError {
 
 func (s *ServiceErrorWarpperImpl) NewRepeatDeviceError() *ServiceError {
 	return &ServiceError{
+		Type:           "repeat_device",
 		StatusCode:     http.StatusConflict,
 		InternalError:  nil,
 		ExtrenalReason: "device has already binded",
@@ -51,6 +84,7 @@ func (s *ServiceErrorWarpperImpl) NewRepeatDeviceError() *ServiceError {
 
 func (s *ServiceErrorWarpperImpl) NewMainDeviceTooManyError(count int64) *ServiceError {
 	return &ServiceError{
+		Type:           "main_device_too_many",
 		StatusCode:     http.StatusConflict,
 		InternalError:  nil,
 		ExtrenalReason: fmt.Sprintf("Allow %d max devices per user", count),
@@ -59,14 +93,25 @@ func (s *ServiceErrorWarpperImpl) NewMainDeviceTooManyError(count int64) *Servic
 
 func (s *ServiceErrorWarpperImpl) NewSubDeviceTooManyError(count int64) *ServiceError {
 	return &ServiceError{
+		Type:           "sub_device_too_many",
 		StatusCode:     http.StatusConflict,
 		InternalError:  nil,
 		ExtrenalReason: fmt.Sprintf("Allow %d max sub devices per main device", count),
 	}
 }
 
+func (s *ServiceErrorWarpperImpl) NewRoomPolicyInvalidError(reason string) *ServiceError {
+	return &ServiceError{
+		Type:           "room_policy_invalid",
+		StatusCode:     http.StatusBadRequest,
+		InternalError:  nil,
+		ExtrenalReason: reason,
+	}
+}
+
 func (s *ServiceErrorWarpperImpl) NewParseParametersFailedError(err error) *ServiceError {
 	return &ServiceError{
+		Type:           "parse_parameters_failed",
 		StatusCode:     http.StatusBadRequest,
 		InternalError:  err,
 		ExtrenalReason: err.Error(),
@@ -75,6 +120,7 @@ func (s *ServiceErrorWarpperImpl) NewParseParametersFailedError(err error) *Serv
 
 func (s *ServiceErrorWarpperImpl) NewPasswordInvaildError(err error) *ServiceError {
 	return &ServiceError{
+		Type:           "password_invalid",
 		StatusCode:     http.StatusBadRequest,
 		InternalError:  err,
 		ExtrenalReason: err.Error(),
@@ -83,6 +129,7 @@ func (s *ServiceErrorWarpperImpl) NewPasswordInvaildError(err error) *ServiceErr
 
 func (s *ServiceErrorWarpperImpl) NewDBCommitServiceError(err error) *ServiceError {
 	return &ServiceError{
+		Type:           "db_commit",
 		StatusCode:     http.StatusInternalServerError,
 		InternalError:  err,
 		ExtrenalReason: "Service Temporary Unavailable",
@@ -91,6 +138,7 @@ func (s *ServiceErrorWarpperImpl) NewDBCommitServiceError(err error) *ServiceErr
 
 func (s *ServiceErrorWarpperImpl) NewDBNoAffectedServiceError() *ServiceError {
 	return &ServiceError{
+		Type:           "db_no_affected",
 		StatusCode:     http.StatusOK,
 		InternalError:  nil,
 		ExtrenalReason: "No Affected Data",
@@ -99,6 +147,7 @@ func (s *ServiceErrorWarpperImpl) NewDBNoAffectedServiceError() *ServiceError {
 
 func (s *ServiceErrorWarpperImpl) NewDBServiceError(err error) *ServiceError {
 	return &ServiceError{
+		Type:           "db",
 		StatusCode:     http.StatusInternalServerError,
 		InternalError:  err,
 		ExtrenalReason: "Service Temporary Unavailable",
@@ -107,6 +156,7 @@ func (s *ServiceErrorWarpperImpl) NewDBServiceError(err error) *ServiceError {
 
 func (s *ServiceErrorWarpperImpl) NewLoginFailedServiceError(err error) *ServiceError {
 	return &ServiceError{
+		Type:           "login_failed",
 		StatusCode:     http.StatusUnauthorized,
 		InternalError:  err,
 		ExtrenalReason: "LoginFailed",
@@ -115,6 +165,7 @@ func (s *ServiceErrorWarpperImpl) NewLoginFailedServiceError(err error) *Service
 
 func (s *ServiceErrorWarpperImpl) NewRessetPasswordServiceError() *ServiceError {
 	return &ServiceError{
+		Type:           "reset_password_failed",
 		StatusCode:     http.StatusUnauthorized,
 		InternalError:  nil,
 		ExtrenalReason: "ResetPasswordFailed",
@@ -123,6 +174,7 @@ func (s *ServiceErrorWarpperImpl) NewRessetPasswordServiceError() *ServiceError
 
 func (s *ServiceErrorWarpperImpl) NewUserHasRegisterdError(username string) *ServiceError {
 	return &ServiceError{
+		Type:           "user_has_registered",
 		StatusCode:     http.StatusConflict,
 		InternalError:  nil,
 		ExtrenalReason: fmt.Sprintf("user %s has already registered", username),
@@ -131,6 +183,7 @@ func (s *ServiceErrorWarpperImpl) NewUserHasRegisterdError(username string) *Ser
 
 func (s *ServiceErrorWarpperImpl) NewUserNotExist(Id uint64) *ServiceError {
 	return &ServiceError{
+		Type:           "user_not_exist",
 		StatusCode:     http.StatusUnauthorized,
 		InternalError:  nil,
 		ExtrenalReason: fmt.Sprintf("user %d does not exist", Id),
@@ -139,12 +192,231 @@ func (s *ServiceErrorWarpperImpl) NewUserNotExist(Id uint64) *ServiceError {
 
 func (s *ServiceErrorWarpperImpl) NewUsernameExist(username string) *ServiceError {
 	return &ServiceError{
+		Type:           "username_exist",
 		StatusCode:     http.StatusConflict,
 		InternalError:  nil,
 		ExtrenalReason: fmt.Sprintf("username %s is used", username),
 	}
 }
 
+func (s *ServiceErrorWarpperImpl) NewLoginSessionNotFoundError() *ServiceError {
+	return &ServiceError{
+		Type:           "login_session_not_found",
+		StatusCode:     http.StatusNotFound,
+		InternalError:  nil,
+		ExtrenalReason: "login session not found",
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewPasswordResetTokenInvalidError() *ServiceError {
+	return &ServiceError{
+		Type:           "password_reset_token_invalid",
+		StatusCode:     http.StatusBadRequest,
+		InternalError:  nil,
+		ExtrenalReason: "password reset token is invalid or expired",
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewEmailNotVerifiedError() *ServiceError {
+	return &ServiceError{
+		Type:           "email_not_verified",
+		StatusCode:     http.StatusForbidden,
+		InternalError:  nil,
+		ExtrenalReason: "email is not verified",
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewEmailAlreadyVerifiedError() *ServiceError {
+	return &ServiceError{
+		Type:           "email_already_verified",
+		StatusCode:     http.StatusConflict,
+		InternalError:  nil,
+		ExtrenalReason: "email is already verified",
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewEmailVerificationTokenInvalidError() *ServiceError {
+	return &ServiceError{
+		Type:           "email_verification_token_invalid",
+		StatusCode:     http.StatusBadRequest,
+		InternalError:  nil,
+		ExtrenalReason: "email verification token is invalid or expired",
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewAccountLockedError(retryAfter time.Duration) *ServiceError {
+	return &ServiceError{
+		Type:           "account_locked",
+		StatusCode:     http.StatusLocked,
+		InternalError:  nil,
+		ExtrenalReason: "account is locked after too many failed logins",
+		RetryAfter:     retryAfter,
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewTotpAlreadyEnabledError() *ServiceError {
+	return &ServiceError{
+		Type:           "totp_already_enabled",
+		StatusCode:     http.StatusConflict,
+		InternalError:  nil,
+		ExtrenalReason: "two-factor authentication is already enabled",
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewTotpNotEnrolledError() *ServiceError {
+	return &ServiceError{
+		Type:           "totp_not_enrolled",
+		StatusCode:     http.StatusNotFound,
+		InternalError:  nil,
+		ExtrenalReason: "two-factor authentication is not enrolled",
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewTwoFactorCodeInvalidError() *ServiceError {
+	return &ServiceError{
+		Type:           "two_factor_code_invalid",
+		StatusCode:     http.StatusUnauthorized,
+		InternalError:  nil,
+		ExtrenalReason: "two-factor code is invalid",
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewLoginChallengeInvalidError() *ServiceError {
+	return &ServiceError{
+		Type:           "login_challenge_invalid",
+		StatusCode:     http.StatusUnauthorized,
+		InternalError:  nil,
+		ExtrenalReason: "login challenge is invalid or expired, log in again",
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewTooManyRequestsError(retryAfter time.Duration) *ServiceError {
+	return &ServiceError{
+		Type:           "too_many_requests",
+		StatusCode:     http.StatusTooManyRequests,
+		InternalError:  nil,
+		ExtrenalReason: "too many requests",
+		RetryAfter:     retryAfter,
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewWebhookTooManyError(count int64) *ServiceError {
+	return &ServiceError{
+		Type:           "webhook_too_many",
+		StatusCode:     http.StatusConflict,
+		InternalError:  nil,
+		ExtrenalReason: fmt.Sprintf("Allow %d max webhooks per user", count),
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewWebhookUrlNotAllowedError(err error) *ServiceError {
+	return &ServiceError{
+		Type:           "webhook_url_not_allowed",
+		StatusCode:     http.StatusBadRequest,
+		InternalError:  err,
+		ExtrenalReason: "webhook url must be http(s) and resolve to a public address",
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewWebhookNotFoundError() *ServiceError {
+	return &ServiceError{
+		Type:           "webhook_not_found",
+		StatusCode:     http.StatusNotFound,
+		InternalError:  nil,
+		ExtrenalReason: "webhook not found",
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewWebhookDeliveryNotFoundError() *ServiceError {
+	return &ServiceError{
+		Type:           "webhook_delivery_not_found",
+		StatusCode:     http.StatusNotFound,
+		InternalError:  nil,
+		ExtrenalReason: "webhook delivery not found",
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewSchemaInvalidError(err error) *ServiceError {
+	return &ServiceError{
+		Type:           "schema_invalid",
+		StatusCode:     http.StatusBadRequest,
+		InternalError:  err,
+		ExtrenalReason: "invalid json schema, refs must point inside the schema (#...)",
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewSchemaNotFoundError() *ServiceError {
+	return &ServiceError{
+		Type:           "schema_not_found",
+		StatusCode:     http.StatusNotFound,
+		InternalError:  nil,
+		ExtrenalReason: "schema not found",
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewRecordingAlreadyStartedError() *ServiceError {
+	return &ServiceError{
+		Type:           "recording_already_started",
+		StatusCode:     http.StatusConflict,
+		InternalError:  nil,
+		ExtrenalReason: "this main device is already recording",
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewRecordingNotStartedError() *ServiceError {
+	return &ServiceError{
+		Type:           "recording_not_started",
+		StatusCode:     http.StatusNotFound,
+		InternalError:  nil,
+		ExtrenalReason: "this main device is not recording",
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewRecordingNotFoundError() *ServiceError {
+	return &ServiceError{
+		Type:           "recording_not_found",
+		StatusCode:     http.StatusNotFound,
+		InternalError:  nil,
+		ExtrenalReason: "recording not found",
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewRecordingFailedError(err error) *ServiceError {
+	return &ServiceError{
+		Type:           "recording_failed",
+		StatusCode:     http.StatusInternalServerError,
+		InternalError:  err,
+		ExtrenalReason: "recording failed",
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewDeviceCredentialInvalidError() *ServiceError {
+	return &ServiceError{
+		Type:           "device_credential_invalid",
+		StatusCode:     http.StatusUnauthorized,
+		InternalError:  nil,
+		ExtrenalReason: "invalid or revoked device credential",
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewDeviceCredentialMismatchError() *ServiceError {
+	return &ServiceError{
+		Type:           "device_credential_mismatch",
+		StatusCode:     http.StatusForbidden,
+		InternalError:  nil,
+		ExtrenalReason: "device credential does not belong to this device",
+	}
+}
+
+func (s *ServiceErrorWarpperImpl) NewDeviceCredentialNotFoundError() *ServiceError {
+	return &ServiceError{
+		Type:           "device_credential_not_found",
+		StatusCode:     http.StatusNotFound,
+		InternalError:  nil,
+		ExtrenalReason: "device credential not found",
+	}
+}
+
 func GetServiceErrorWarpper() ServiceErrorWarpper {
 	return s
 }
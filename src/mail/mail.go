@@ -0,0 +1,111 @@
+package mail
+
+import (
+	"context"
+	"device-communication/src/config"
+	logger "device-communication/src/log"
+	"fmt"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	SenderLog  = "log"
+	SenderFile = "file"
+	SenderSmtp = "smtp"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Sender interface {
+	Send(ctx context.Context, message *Message) error
+}
+
+func format(from string, message *Message) []byte {
+	return []byte(fmt.Sprintf(
+		"From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		from, message.To, message.Subject, time.Now().Format(time.RFC1123Z), message.Body,
+	))
+}
+
+type smtpSender struct {
+	address string
+	auth    smtp.Auth
+	from    string
+}
+
+func NewSmtpSender(host string, port int, username string, password string, from string) Sender {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &smtpSender{
+		address: host + ":" + strconv.Itoa(port),
+		auth:    auth,
+		from:    from,
+	}
+}
+
+func (s *smtpSender) Send(ctx context.Context, message *Message) error {
+	return smtp.SendMail(s.address, s.auth, s.from, []string{message.To}, format(s.from, message))
+}
+
+// logSender only prints the mail, it is meant for development.
+type logSender struct {
+	from   string
+	logger logger.Logger
+}
+
+func NewLogSender(from string) Sender {
+	return &logSender{from: from, logger: logger.NewInfoLogger()}
+}
+
+func (l *logSender) Send(ctx context.Context, message *Message) error {
+	l.logger.Info("", "mail.Send", string(format(l.from, message)), nil)
+	return nil
+}
+
+// fileSender writes every mail to its own .eml file so tests can read them back.
+type fileSender struct {
+	directory string
+	from      string
+}
+
+func NewFileSender(directory string, from string) Sender {
+	return &fileSender{directory: directory, from: from}
+}
+
+func (f *fileSender) Send(ctx context.Context, message *Message) error {
+	if err := os.MkdirAll(f.directory, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d.eml", time.Now().UnixNano())
+	return os.WriteFile(filepath.Join(f.directory, name), format(f.from, message), 0o644)
+}
+
+var sender Sender
+
+func init() {
+	m := config.GlobalConfig.YamlConfig.Mail
+	switch m.Sender {
+	case SenderSmtp:
+		sender = NewSmtpSender(m.Smtp.Host, m.Smtp.Port, m.Smtp.Username, m.Smtp.Password, m.From)
+	case SenderFile:
+		sender = NewFileSender(m.Directory, m.From)
+	case SenderLog, "":
+		sender = NewLogSender(m.From)
+	default:
+		panic(fmt.Sprintf("unknown mail sender: %s", m.Sender))
+	}
+}
+
+func GetSender() Sender {
+	return sender
+}
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "device_communication"
+
+const (
+	RoleMain = "main"
+	RoleSub  = "sub"
+)
+
+const (
+	DirectionMainToSub = "main_to_sub"
+	DirectionSubToMain = "sub_to_main"
+)
+
+const (
+	DropWriteFailed         = "write_failed"
+	DropUnsupportedType     = "unsupported_type"
+	DropInvalidTopic        = "invalid_topic"
+	DropSchemaRejected      = "schema_rejected"
+	DropSchemaQuarantined   = "schema_quarantined"
+	DropInflightLimit       = "inflight_limit"
+	DropResumeBuffer        = "resume_buffer_full"
+	DropMessageTooLarge     = "message_too_large"
+	DropFrameTypeNotAllowed = "frame_type_not_allowed"
+)
+
+var (
+	Rooms = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "rooms",
+		Help:      "Number of open rooms.",
+	})
+	Connections = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "connections",
+		Help:      "Number of attached device connections by role.",
+	}, []string{"role"})
+	RelayedMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relayed_messages_total",
+		Help:      "Messages relayed between devices.",
+	}, []string{"direction"})
+	RelayedBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relayed_bytes_total",
+		Help:      "Bytes relayed between devices.",
+	}, []string{"direction"})
+	DroppedFrames = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dropped_frames_total",
+		Help:      "Frames that were not relayed.",
+	}, []string{"reason"})
+	Resumes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "resumes_total",
+		Help:      "Device sessions resumed within the grace window, by role.",
+	}, []string{"role"})
+	UpgradeFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upgrade_failures_total",
+		Help:      "Device connections refused, by role and ServiceError type.",
+	}, []string{"role", "type"})
+	DeviceOperations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "device_operations_total",
+		Help:      "Bind and unbind operations.",
+	}, []string{"operation", "role", "result"})
+	HttpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of http handlers.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+	RepositoryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "repository_query_duration_seconds",
+		Help:      "Latency of repository queries.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"repository", "method"})
+)
+
+func Relayed(direction string, size int) {
+	RelayedMessages.WithLabelValues(direction).Inc()
+	RelayedBytes.WithLabelValues(direction).Add(float64(size))
+}
+
+func Dropped(reason string) {
+	DroppedFrames.WithLabelValues(reason).Inc()
+}
+
+// ObserveRepository is deferred at the top of a repository method: defer metrics.ObserveRepository("device", "GetMainDevice", time.Now())
+func ObserveRepository(repository string, method string, start time.Time) {
+	RepositoryDuration.WithLabelValues(repository, method).Observe(time.Since(start).Seconds())
+}
+
+func DeviceOperation(operation string, role string, ok bool) {
+	result := "ok"
+	if !ok {
+		result = "error"
+	}
+	DeviceOperations.WithLabelValues(operation, role, result).Inc()
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// DeviceCredential authenticates one device, SubDeviceId is 0 for a main device.
+type DeviceCredential struct {
+	Id           uint64     `gorm:"primaryKey;column:id"`
+	UserId       uint64     `gorm:"not null;column:user_id"`
+	MainDeviceId uint64     `gorm:"not null;column:main_device_id"`
+	SubDeviceId  uint64     `gorm:"not null;column:sub_device_id"`
+	TokenHash    string     `gorm:"not null;column:token_hash"`
+	TokenPrefix  string     `gorm:"not null;column:token_prefix"`
+	LastUsedAt   *time.Time `gorm:"column:last_used_time"`
+	RevokedAt    *time.Time `gorm:"column:revoke_time"`
+	Base
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+const (
+	LockoutEventLocked   = "locked"
+	LockoutEventUnlocked = "unlocked"
+)
+
+type LockoutEvent struct {
+	Id          uint64     `gorm:"primaryKey;column:id"`
+	Username    string     `gorm:"not null;column:username"`
+	Ip          string     `gorm:"not null;column:ip"`
+	Event       string     `gorm:"not null;column:event"`
+	LockedUntil *time.Time `gorm:"column:locked_until"`
+	Operator    string     `gorm:"not null;column:operator"`
+	Base
+}
@@ -0,0 +1,11 @@
+package model
+
+type RoomPolicy struct {
+	Id                uint64 `gorm:"primaryKey;column:id"`
+	MainDeviceId      uint64 `gorm:"not null;uniqueIndex;column:main_device_id"`
+	MaxSubDevice      int64  `gorm:"not null;column:max_sub_device"`
+	IdleTimeoutSecond int64  `gorm:"not null;column:idle_timeout_second"`
+	AllowedFrameTypes string `gorm:"not null;column:allowed_frame_types"`
+	MaxMessageByte    int64  `gorm:"not null;column:max_message_byte"`
+	Base
+}
@@ -0,0 +1,28 @@
+package model
+
+const (
+	SchemaPolicyReject     = "reject"
+	SchemaPolicyFlag       = "flag"
+	SchemaPolicyQuarantine = "quarantine"
+)
+
+type MessageSchema struct {
+	Id       uint64 `gorm:"primaryKey;column:id"`
+	UserId   uint64 `gorm:"not null;column:user_id"`
+	Platform string `gorm:"not null;column:platform"`
+	Version  string `gorm:"not null;column:version"`
+	Schema   string `gorm:"not null;column:schema"`
+	Policy   string `gorm:"not null;column:policy"`
+	Base
+}
+
+type SchemaViolation struct {
+	Id           uint64 `gorm:"primaryKey;column:id"`
+	SchemaId     uint64 `gorm:"not null;column:schema_id"`
+	UserId       uint64 `gorm:"not null;column:user_id"`
+	MainDeviceId uint64 `gorm:"not null;column:main_device_id"`
+	Action       string `gorm:"not null;column:action"`
+	Message      string `gorm:"not null;column:message"`
+	Reason       string `gorm:"not null;column:reason"`
+	Base
+}
@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// LoginSession is kept in redis next to the session of gin-contrib/sessions.
+type LoginSession struct {
+	SessionId  string    `json:"session_id"`
+	CreateTime time.Time `json:"create_time"`
+	Ip         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+}
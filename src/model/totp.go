@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// UserTotp is the TOTP secret of a user, it only protects the login once Enabled.
+type UserTotp struct {
+	Id           uint64 `gorm:"primaryKey;column:id"`
+	UserId       uint64 `gorm:"not null;column:user_id"`
+	Secret       string `gorm:"not null;column:secret"`
+	Enabled      bool   `gorm:"not null;column:enabled"`
+	LastUsedStep int64  `gorm:"not null;column:last_used_step"`
+	Base
+}
+
+type RecoveryCode struct {
+	Id       uint64     `gorm:"primaryKey;column:id"`
+	UserId   uint64     `gorm:"not null;column:user_id"`
+	CodeHash string     `gorm:"not null;column:code_hash"`
+	UsedAt   *time.Time `gorm:"column:used_time"`
+	Base
+}
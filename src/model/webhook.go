@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+const (
+	WebhookDeliveryStatusPending   = "pending"
+	WebhookDeliveryStatusSucceeded = "succeeded"
+	WebhookDeliveryStatusDead      = "dead"
+)
+
+type Webhook struct {
+	Id     uint64 `gorm:"primaryKey;column:id"`
+	UserId uint64 `gorm:"not null;column:user_id"`
+	Url    string `gorm:"not null;column:url"`
+	Secret string `gorm:"not null;column:secret"`
+	Events string `gorm:"not null;column:events"`
+	Base
+}
+
+type WebhookDelivery struct {
+	Id          uint64    `gorm:"primaryKey;column:id"`
+	WebhookId   uint64    `gorm:"not null;column:webhook_id"`
+	Event       string    `gorm:"not null;column:event"`
+	Payload     string    `gorm:"not null;column:payload"`
+	Status      string    `gorm:"not null;column:status"`
+	Attempts    int       `gorm:"not null;column:attempts"`
+	LastError   string    `gorm:"not null;column:last_error"`
+	NextRetryAt time.Time `gorm:"not null;column:next_retry_time"`
+	Base
+}
@@ -0,0 +1,236 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	packetConnect     byte = 1
+	packetConnack     byte = 2
+	packetPublish     byte = 3
+	packetPuback      byte = 4
+	packetPubrec      byte = 5
+	packetPubrel      byte = 6
+	packetPubcomp     byte = 7
+	packetSubscribe   byte = 8
+	packetSuback      byte = 9
+	packetUnsubscribe byte = 10
+	packetUnsuback    byte = 11
+	packetPingreq     byte = 12
+	packetPingresp    byte = 13
+	packetDisconnect  byte = 14
+)
+
+const (
+	connackAccepted           byte = 0
+	connackBadProtocolVersion byte = 1
+	connackIdentifierRejected byte = 2
+	connackBadCredentials     byte = 4
+	connackNotAuthorized      byte = 5
+)
+
+const subackFailure byte = 0x80
+
+var (
+	errMalformedPacket = errors.New("malformed mqtt packet")
+	errPacketTooLarge  = errors.New("mqtt packet too large")
+)
+
+type packet struct {
+	kind  byte
+	flags byte
+	body  []byte
+}
+
+type connectPacket struct {
+	protocolName  string
+	protocolLevel byte
+	cleanSession  bool
+	keepAlive     uint16
+	clientId      string
+	username      string
+	password      string
+}
+
+type publishPacket struct {
+	topic    string
+	qos      byte
+	packetId uint16
+	payload  []byte
+}
+
+type subscribePacket struct {
+	packetId uint16
+	topics   []string
+}
+
+func readPacket(r *bufio.Reader, maxSize int) (*packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	length := 0
+	multiplier := 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return nil, errMalformedPacket
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		length += int(b&0x7f) * multiplier
+		multiplier *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	if length > maxSize {
+		return nil, errPacketTooLarge
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return &packet{kind: header >> 4, flags: header & 0x0f, body: body}, nil
+}
+
+func encodePacket(kind byte, flags byte, body []byte) []byte {
+	out := make([]byte, 0, len(body)+5)
+	out = append(out, kind<<4|flags)
+	length := len(body)
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if length == 0 {
+			break
+		}
+	}
+	return append(out, body...)
+}
+
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) uint16() uint16 {
+	if d.err != nil || len(d.buf) < 2 {
+		d.err = errMalformedPacket
+		return 0
+	}
+	v := binary.BigEndian.Uint16(d.buf)
+	d.buf = d.buf[2:]
+	return v
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil || len(d.buf) < 1 {
+		d.err = errMalformedPacket
+		return 0
+	}
+	v := d.buf[0]
+	d.buf = d.buf[1:]
+	return v
+}
+
+func (d *decoder) bytes() []byte {
+	n := int(d.uint16())
+	if d.err != nil || len(d.buf) < n {
+		d.err = errMalformedPacket
+		return nil
+	}
+	v := d.buf[:n]
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+func appendString(out []byte, s string) []byte {
+	out = binary.BigEndian.AppendUint16(out, uint16(len(s)))
+	return append(out, s...)
+}
+
+func decodeConnect(p *packet) (*connectPacket, error) {
+	d := &decoder{buf: p.body}
+	c := &connectPacket{}
+	c.protocolName = d.string()
+	c.protocolLevel = d.byte()
+	flags := d.byte()
+	c.keepAlive = d.uint16()
+	c.cleanSession = flags&0x02 != 0
+	c.clientId = d.string()
+	if flags&0x04 != 0 {
+		d.string()
+		d.bytes()
+	}
+	if flags&0x80 != 0 {
+		c.username = d.string()
+	}
+	if flags&0x40 != 0 {
+		c.password = string(d.bytes())
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return c, nil
+}
+
+func decodePublish(p *packet) (*publishPacket, error) {
+	d := &decoder{buf: p.body}
+	pub := &publishPacket{qos: (p.flags >> 1) & 0x03}
+	pub.topic = d.string()
+	if pub.qos > 0 {
+		pub.packetId = d.uint16()
+	}
+	if d.err != nil || pub.qos > 2 {
+		return nil, errMalformedPacket
+	}
+	pub.payload = d.buf
+	return pub, nil
+}
+
+func decodeSubscribe(p *packet, withQos bool) (*subscribePacket, error) {
+	d := &decoder{buf: p.body}
+	sub := &subscribePacket{packetId: d.uint16()}
+	for d.err == nil && len(d.buf) > 0 {
+		sub.topics = append(sub.topics, d.string())
+		if withQos {
+			d.byte()
+		}
+	}
+	if d.err != nil || len(sub.topics) == 0 {
+		return nil, errMalformedPacket
+	}
+	return sub, nil
+}
+
+func encodeConnack(code byte) []byte {
+	return encodePacket(packetConnack, 0, []byte{0, code})
+}
+
+func encodePublish(topic string, payload []byte) []byte {
+	body := appendString(make([]byte, 0, len(topic)+len(payload)+2), topic)
+	return encodePacket(packetPublish, 0, append(body, payload...))
+}
+
+func encodePacketId(kind byte, flags byte, packetId uint16) []byte {
+	return encodePacket(kind, flags, binary.BigEndian.AppendUint16(nil, packetId))
+}
+
+func encodeSuback(packetId uint16, codes []byte) []byte {
+	body := binary.BigEndian.AppendUint16(nil, packetId)
+	return encodePacket(packetSuback, 0, append(body, codes...))
+}
@@ -0,0 +1,381 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"device-communication/src/config"
+	"device-communication/src/dto"
+	logger "device-communication/src/log"
+	"device-communication/src/service"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	directionIn  = "in"
+	directionOut = "out"
+)
+
+type Server interface {
+	ListenAndServe(address string) error
+}
+
+type serverImpl struct {
+	communication  service.CommunicationSerivice
+	user           service.UserService
+	credential     service.DeviceCredentialService
+	logger         logger.Logger
+	connectTimeout time.Duration
+	writeTimeout   time.Duration
+	maxPacketSize  int
+}
+
+var server Server
+
+func init() {
+	server = &serverImpl{
+		communication:  service.GetCommunicationSerivice(),
+		user:           service.GetUserService(),
+		credential:     service.GetDeviceCredentialService(),
+		logger:         logger.NewInfoLogger(),
+		connectTimeout: 10 * time.Second,
+		writeTimeout:   10 * time.Second,
+		maxPacketSize:  config.GlobalConfig.YamlConfig.Mqtt.MaxPacketSize,
+	}
+}
+
+func GetServer() Server {
+	return server
+}
+
+func (s *serverImpl) ListenAndServe(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serve(conn)
+	}
+}
+
+func (s *serverImpl) serve(conn net.Conn) {
+	client := &clientSession{
+		server:  s,
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		pubrecs: make(map[uint16]bool),
+	}
+	defer client.close()
+	if !client.handshake() {
+		return
+	}
+	defer client.detach()
+
+	for {
+		if client.keepAlive > 0 {
+			conn.SetReadDeadline(time.Now().Add(client.keepAlive * 3 / 2))
+		} else {
+			conn.SetReadDeadline(time.Time{})
+		}
+
+		p, err := readPacket(client.reader, s.maxPacketSize)
+		if err != nil {
+			return
+		}
+		if !client.handle(p) {
+			return
+		}
+	}
+}
+
+type deviceTopic struct {
+	mainDeviceId uint64
+	subDeviceId  uint64
+	direction    string
+}
+
+// devices/{main_id}/in, devices/{main_id}/out, devices/{main_id}/sub/{sub_id}/in, devices/{main_id}/sub/{sub_id}/out
+func parseDeviceTopic(topic string) (deviceTopic, bool) {
+	parts := strings.Split(topic, "/")
+	if len(parts) < 3 || parts[0] != "devices" {
+		return deviceTopic{}, false
+	}
+
+	var t deviceTopic
+	var err error
+	t.mainDeviceId, err = strconv.ParseUint(parts[1], 10, 64)
+	if err != nil || t.mainDeviceId == 0 {
+		return deviceTopic{}, false
+	}
+
+	switch {
+	case len(parts) == 3:
+		t.direction = parts[2]
+	case len(parts) == 5 && parts[2] == "sub":
+		t.subDeviceId, err = strconv.ParseUint(parts[3], 10, 64)
+		if err != nil || t.subDeviceId == 0 {
+			return deviceTopic{}, false
+		}
+		t.direction = parts[4]
+	default:
+		return deviceTopic{}, false
+	}
+
+	if t.direction != directionIn && t.direction != directionOut {
+		return deviceTopic{}, false
+	}
+	return t, true
+}
+
+func (t deviceTopic) inbox() string {
+	if t.subDeviceId == 0 {
+		return fmt.Sprintf("devices/%d/%s", t.mainDeviceId, directionIn)
+	}
+	return fmt.Sprintf("devices/%d/sub/%d/%s", t.mainDeviceId, t.subDeviceId, directionIn)
+}
+
+type clientSession struct {
+	server    *serverImpl
+	conn      net.Conn
+	reader    *bufio.Reader
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+	clientId  string
+	userId    uint64
+	keepAlive time.Duration
+	device    service.DeviceSession
+	identity  deviceTopic
+	// set when the client logged in with a device credential instead of an account
+	credential *dto.DeviceIdentity
+	// packet ids of qos 2 publishes that got a PUBREC but no PUBREL yet, only used by the read loop
+	pubrecs map[uint16]bool
+}
+
+func (c *clientSession) handshake() bool {
+	c.conn.SetReadDeadline(time.Now().Add(c.server.connectTimeout))
+	p, err := readPacket(c.reader, c.server.maxPacketSize)
+	if err != nil || p.kind != packetConnect {
+		return false
+	}
+
+	connect, err := decodeConnect(p)
+	if err != nil {
+		return false
+	}
+	if connect.protocolName != "MQTT" || connect.protocolLevel != 4 {
+		c.write(encodeConnack(connackBadProtocolVersion))
+		return false
+	}
+	if connect.clientId == "" && !connect.cleanSession {
+		c.write(encodeConnack(connackIdentifierRejected))
+		return false
+	}
+	if service.IsDeviceToken(connect.password) {
+		identity, serviceErr := c.server.credential.AuthenticateDevice(context.Background(), connect.password)
+		if serviceErr != nil {
+			c.server.logger.Info("", "c.server.credential.AuthenticateDevice", connect.clientId, serviceErr.InternalError)
+			c.write(encodeConnack(connackBadCredentials))
+			return false
+		}
+		c.credential = identity
+		c.userId = identity.UserId
+	} else {
+		if connect.username == "" {
+			c.write(encodeConnack(connackNotAuthorized))
+			return false
+		}
+
+		ip, _, _ := net.SplitHostPort(c.conn.RemoteAddr().String())
+		res, serviceErr := c.server.user.DeviceLoginService(context.Background(), &dto.UserLoginRequest{
+			Username: connect.username,
+			Password: connect.password,
+			Ip:       ip,
+		})
+		if serviceErr != nil {
+			c.server.logger.Info("", "c.server.user.DeviceLoginService", connect.clientId, serviceErr.InternalError)
+			c.write(encodeConnack(connackBadCredentials))
+			return false
+		}
+		if res.Challenge != "" {
+			// a password is not enough with two-factor authentication, such devices use a credential
+			c.write(encodeConnack(connackNotAuthorized))
+			return false
+		}
+		c.userId = res.ID
+	}
+
+	c.clientId = connect.clientId
+	c.keepAlive = time.Duration(connect.keepAlive) * time.Second
+	return c.write(encodeConnack(connackAccepted)) == nil
+}
+
+func (c *clientSession) handle(p *packet) bool {
+	switch p.kind {
+	case packetPublish:
+		pub, err := decodePublish(p)
+		if err != nil {
+			return false
+		}
+		// a retransmitted qos 2 publish was relayed already, it only needs the PUBREC again
+		if pub.qos == 2 && c.pubrecs[pub.packetId] {
+			return c.write(encodePacketId(packetPubrec, 0, pub.packetId)) == nil
+		}
+		// the ack tells the client the message is delivered, so it goes out only after the relay
+		if !c.publish(pub) {
+			return false
+		}
+		switch pub.qos {
+		case 1:
+			return c.write(encodePacketId(packetPuback, 0, pub.packetId)) == nil
+		case 2:
+			c.pubrecs[pub.packetId] = true
+			return c.write(encodePacketId(packetPubrec, 0, pub.packetId)) == nil
+		}
+		return true
+	case packetPubrel:
+		if len(p.body) < 2 {
+			return false
+		}
+		packetId := binary.BigEndian.Uint16(p.body)
+		delete(c.pubrecs, packetId)
+		return c.write(encodePacketId(packetPubcomp, 0, packetId)) == nil
+	case packetSubscribe:
+		sub, err := decodeSubscribe(p, true)
+		if err != nil {
+			return false
+		}
+		codes := make([]byte, 0, len(sub.topics))
+		for _, topic := range sub.topics {
+			if c.subscribe(topic) {
+				codes = append(codes, 0)
+			} else {
+				codes = append(codes, subackFailure)
+			}
+		}
+		return c.write(encodeSuback(sub.packetId, codes)) == nil
+	case packetUnsubscribe:
+		unsub, err := decodeSubscribe(p, false)
+		if err != nil {
+			return false
+		}
+		return c.write(encodePacketId(packetUnsuback, 0, unsub.packetId)) == nil
+	case packetPingreq:
+		return c.write(encodePacket(packetPingresp, 0, nil)) == nil
+	case packetDisconnect:
+		return false
+	default:
+		return false
+	}
+}
+
+func (c *clientSession) publish(pub *publishPacket) bool {
+	topic, ok := parseDeviceTopic(pub.topic)
+	if !ok || topic.direction != directionOut {
+		c.server.logger.Info("", "parseDeviceTopic", pub.topic, nil)
+		return true
+	}
+	if !c.attach(topic) {
+		return false
+	}
+
+	c.device.HandleMessage(websocket.TextMessage, pub.payload)
+	return true
+}
+
+func (c *clientSession) subscribe(filter string) bool {
+	topic, ok := parseDeviceTopic(filter)
+	if !ok || topic.direction != directionIn {
+		return false
+	}
+	return c.attach(topic)
+}
+
+func (c *clientSession) attach(topic deviceTopic) bool {
+	if c.device != nil {
+		return c.identity.mainDeviceId == topic.mainDeviceId && c.identity.subDeviceId == topic.subDeviceId
+	}
+
+	conn := &deviceConnection{client: c, topic: topic.inbox()}
+	data := map[string]any{"client_id": c.clientId, "topic": conn.topic}
+	if c.credential != nil && (c.credential.MainDeviceId != topic.mainDeviceId || c.credential.SubDeviceId != topic.subDeviceId) {
+		c.server.logger.Info("", "clientSession.attach", data, nil)
+		return false
+	}
+	if topic.subDeviceId == 0 {
+		device, err := c.server.communication.AttachMainDevice(context.Background(), &dto.MainDeviceConnectionRequest{
+			UserId:       c.userId,
+			MainDeviceId: topic.mainDeviceId,
+		}, conn)
+		if err != nil {
+			c.server.logger.Info("", "c.server.communication.AttachMainDevice", data, err.InternalError)
+			return false
+		}
+		c.device = device
+	} else {
+		device, err := c.server.communication.AttachSubDevice(context.Background(), &dto.SubDeviceConnectionRequest{
+			UserId:       c.userId,
+			MainDeviceId: topic.mainDeviceId,
+			SubDeviceId:  topic.subDeviceId,
+		}, conn)
+		if err != nil {
+			c.server.logger.Info("", "c.server.communication.AttachSubDevice", data, err.InternalError)
+			return false
+		}
+		c.device = device
+	}
+
+	c.identity = topic
+	return true
+}
+
+func (c *clientSession) detach() {
+	if c.device != nil {
+		c.device.Close()
+	}
+}
+
+func (c *clientSession) write(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(c.server.writeTimeout))
+	_, err := c.conn.Write(data)
+	return err
+}
+
+func (c *clientSession) close() {
+	c.closeOnce.Do(func() {
+		c.conn.Close()
+	})
+}
+
+type deviceConnection struct {
+	client *clientSession
+	topic  string
+}
+
+func (d *deviceConnection) WriteMessage(messageType int, data []byte) error {
+	switch messageType {
+	case websocket.TextMessage, websocket.BinaryMessage:
+		return d.client.write(encodePublish(d.topic, data))
+	default:
+		return nil
+	}
+}
+
+func (d *deviceConnection) Close() error {
+	d.client.close()
+	return nil
+}
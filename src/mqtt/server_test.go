@@ -0,0 +1,138 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	logger "device-communication/src/log"
+)
+
+// recordingConn keeps what the server writes, the session only writes and closes in these tests.
+type recordingConn struct {
+	net.Conn
+	written bytes.Buffer
+}
+
+func (r *recordingConn) Write(b []byte) (int, error) {
+	return r.written.Write(b)
+}
+
+func (r *recordingConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+func (r *recordingConn) Close() error {
+	return nil
+}
+
+// replies drains the packets written since the last call.
+func (r *recordingConn) replies(t *testing.T) []*packet {
+	t.Helper()
+	var packets []*packet
+	reader := bufio.NewReader(&r.written)
+	for reader.Buffered() > 0 || r.written.Len() > 0 {
+		p, err := readPacket(reader, 1<<16)
+		if err != nil {
+			t.Fatalf("readPacket() = %v", err)
+		}
+		packets = append(packets, p)
+	}
+	return packets
+}
+
+type fakeDeviceSession struct {
+	messages []string
+}
+
+func (f *fakeDeviceSession) HandleMessage(messageType int, message []byte) {
+	f.messages = append(f.messages, string(message))
+}
+
+func (f *fakeDeviceSession) ResumeToken() string { return "" }
+func (f *fakeDeviceSession) Suspend()            {}
+func (f *fakeDeviceSession) Kick(reason string)  {}
+func (f *fakeDeviceSession) Close()              {}
+
+// newTestSession returns a session already attached as main device 1.
+func newTestSession() (*clientSession, *recordingConn, *fakeDeviceSession) {
+	conn := &recordingConn{}
+	device := &fakeDeviceSession{}
+	c := &clientSession{
+		server:   &serverImpl{logger: logger.NewInfoLogger(), writeTimeout: time.Second},
+		conn:     conn,
+		pubrecs:  make(map[uint16]bool),
+		device:   device,
+		identity: deviceTopic{mainDeviceId: 1},
+	}
+	return c, conn, device
+}
+
+func publishPacketOf(topic string, qos byte, packetId uint16, payload string) *packet {
+	body := appendString(nil, topic)
+	if qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, packetId)
+	}
+	return &packet{kind: packetPublish, flags: qos << 1, body: append(body, payload...)}
+}
+
+func expectAck(t *testing.T, replies []*packet, kind byte, packetId uint16) {
+	t.Helper()
+	if len(replies) != 1 || replies[0].kind != kind || binary.BigEndian.Uint16(replies[0].body) != packetId {
+		t.Fatalf("replies = %+v, want one packet of kind %d for id %d", replies, kind, packetId)
+	}
+}
+
+func TestPublishQos1AckAfterRelay(t *testing.T) {
+	c, conn, device := newTestSession()
+	if !c.handle(publishPacketOf("devices/1/out", 1, 7, "hello")) {
+		t.Fatal("handle() closed the connection")
+	}
+	expectAck(t, conn.replies(t), packetPuback, 7)
+	if len(device.messages) != 1 || device.messages[0] != "hello" {
+		t.Fatalf("relayed %q, want the payload once", device.messages)
+	}
+
+	// another device's topic fails the attach check, the client must not see an ack
+	if c.handle(publishPacketOf("devices/2/out", 1, 8, "stolen")) {
+		t.Fatal("handle() kept a connection that published to another device")
+	}
+	if replies := conn.replies(t); len(replies) != 0 {
+		t.Fatalf("replies = %+v, want no ack", replies)
+	}
+	if len(device.messages) != 1 {
+		t.Fatalf("relayed %q, want the rejected publish dropped", device.messages)
+	}
+}
+
+func TestPublishQos2RelaysOnceUntilPubrel(t *testing.T) {
+	c, conn, device := newTestSession()
+	c.handle(publishPacketOf("devices/1/out", 2, 9, "first"))
+	expectAck(t, conn.replies(t), packetPubrec, 9)
+
+	// the client did not get the PUBREC and retransmits with DUP
+	retransmit := publishPacketOf("devices/1/out", 2, 9, "first")
+	retransmit.flags |= 0x08
+	if !c.handle(retransmit) {
+		t.Fatal("handle() closed the connection on a retransmission")
+	}
+	expectAck(t, conn.replies(t), packetPubrec, 9)
+	if len(device.messages) != 1 {
+		t.Fatalf("relayed %q, want the retransmission dropped", device.messages)
+	}
+
+	if !c.handle(&packet{kind: packetPubrel, flags: 0x02, body: binary.BigEndian.AppendUint16(nil, 9)}) {
+		t.Fatal("handle() closed the connection on PUBREL")
+	}
+	expectAck(t, conn.replies(t), packetPubcomp, 9)
+
+	// once released the packet id may be reused for a new message
+	c.handle(publishPacketOf("devices/1/out", 2, 9, "second"))
+	expectAck(t, conn.replies(t), packetPubrec, 9)
+	if len(device.messages) != 2 || device.messages[1] != "second" {
+		t.Fatalf("relayed %q, want the new message after PUBREL", device.messages)
+	}
+}
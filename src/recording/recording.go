@@ -0,0 +1,143 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	DirectionMainIn  = "main_in"
+	DirectionMainOut = "main_out"
+	DirectionSubIn   = "sub_in"
+	DirectionSubOut  = "sub_out"
+)
+
+// Entry is one line of a recording file, OffsetMs is counted from the start of the recording.
+type Entry struct {
+	Time        time.Time `json:"time"`
+	OffsetMs    int64     `json:"offset_ms"`
+	Direction   string    `json:"direction"`
+	SubDeviceId uint64    `json:"sub_device_id,omitempty"`
+	Opcode      int       `json:"opcode"`
+	Data        []byte    `json:"data"`
+}
+
+// Limit stops a recording by itself, zero fields are unlimited.
+type Limit struct {
+	MaxBytes    int64
+	MaxDuration time.Duration
+}
+
+type Recorder struct {
+	file    *os.File
+	writer  *bufio.Writer
+	start   time.Time
+	limit   Limit
+	written int64
+	timer   *time.Timer
+	mu      sync.Mutex
+	closed  bool
+}
+
+func NewRecorder(path string, limit Limit) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recorder{
+		file:   file,
+		writer: bufio.NewWriter(file),
+		start:  time.Now(),
+		limit:  limit,
+	}
+	if limit.MaxDuration > 0 {
+		r.timer = time.AfterFunc(limit.MaxDuration, func() {
+			r.Close()
+		})
+	}
+	return r, nil
+}
+
+// Record drops the entry once the recording is closed, an entry that would go over MaxBytes closes it.
+func (r *Recorder) Record(direction string, subDeviceId uint64, opcode int, data []byte) error {
+	now := time.Now()
+	line, err := json.Marshal(&Entry{
+		Time:        now,
+		OffsetMs:    now.Sub(r.start).Milliseconds(),
+		Direction:   direction,
+		SubDeviceId: subDeviceId,
+		Opcode:      opcode,
+		Data:        data,
+	})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	if r.limit.MaxBytes > 0 && r.written+int64(len(line)) > r.limit.MaxBytes {
+		return r.close()
+	}
+	n, err := r.writer.Write(line)
+	r.written += int64(n)
+	return err
+}
+
+func (r *Recorder) Name() string {
+	return r.file.Name()
+}
+
+// Stopped reports whether the recording was closed, by Close or by reaching its limit.
+func (r *Recorder) Stopped() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closed
+}
+
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.close()
+}
+
+func (r *Recorder) close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	if err := r.writer.Flush(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+func ReadFile(path string) ([]*Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []*Entry
+	decoder := json.NewDecoder(file)
+	for decoder.More() {
+		var entry Entry
+		if err := decoder.Decode(&entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, nil
+}
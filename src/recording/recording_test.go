@@ -0,0 +1,59 @@
+package recording
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecorderStopsAtMaxBytes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bytes.jsonl")
+	r, err := NewRecorder(path, Limit{MaxBytes: 400})
+	if err != nil {
+		t.Fatalf("NewRecorder() = %v", err)
+	}
+	defer r.Close()
+
+	for i := 0; i < 20; i++ {
+		if err := r.Record(DirectionMainOut, 0, 1, []byte(`{"celsius":21.5}`)); err != nil {
+			t.Fatalf("Record() = %v", err)
+		}
+	}
+	if !r.Stopped() {
+		t.Fatal("the recording went on past MaxBytes")
+	}
+
+	entries, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() = %v", err)
+	}
+	if len(entries) == 0 || len(entries) == 20 {
+		t.Fatalf("recorded %d entries, want the file cut at the limit", len(entries))
+	}
+}
+
+func TestRecorderStopsAfterMaxDuration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "duration.jsonl")
+	r, err := NewRecorder(path, Limit{MaxDuration: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewRecorder() = %v", err)
+	}
+	r.Record(DirectionSubIn, 2, 1, []byte(`{}`))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !r.Stopped() {
+		if time.Now().After(deadline) {
+			t.Fatal("the recording did not stop after MaxDuration")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	r.Record(DirectionSubIn, 2, 1, []byte(`{}`))
+
+	entries, err := ReadFile(path)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("ReadFile() = %d entries, %v, want the entry before the stop only", len(entries), err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close() after the limit = %v", err)
+	}
+}
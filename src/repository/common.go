@@ -19,8 +19,8 @@ func SetTxContext(ctx context.Context) (context.Context, *gorm.DB) {
 func GetTxContext(ctx context.Context, defaultTx *gorm.DB) *gorm.DB {
 	tx, ok := ctx.Value(tk).(*gorm.DB)
 	if !ok {
-		return defaultTx
+		return defaultTx.WithContext(ctx)
 	}
-	return tx.Session(&gorm.Session{NewDB: true})
+	return tx.Session(&gorm.Session{NewDB: true}).WithContext(ctx)
 	// .Session(&gorm.Session{NewDB: true}) 避免查詢條件汙染
 }
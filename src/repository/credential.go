@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"device-communication/src/config"
+	"device-communication/src/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type CredentialRepository interface {
+	CreateCredential(ctx context.Context, userId uint64, mainDeviceId uint64, subDeviceId uint64, tokenHash string, tokenPrefix string) (*model.DeviceCredential, error)
+	GetCredentialsByUserId(ctx context.Context, userId uint64) ([]*model.DeviceCredential, error)
+	UseCredential(ctx context.Context, tokenHash string, now time.Time) (*model.DeviceCredential, bool, error)
+	RevokeCredentials(ctx context.Context, userId uint64, mainDeviceId uint64, subDeviceId uint64, now time.Time) (int64, error)
+	RevokeMainDeviceCredentials(ctx context.Context, userId uint64, mainDeviceId uint64, now time.Time) (int64, error)
+}
+
+type credentialRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+var credential CredentialRepository
+
+func init() {
+	credential = &credentialRepositoryImpl{
+		DB: config.GlobalConfig.DB,
+	}
+}
+
+func GetCredentialRepository() CredentialRepository {
+	return credential
+}
+
+func (c *credentialRepositoryImpl) CreateCredential(ctx context.Context, userId uint64, mainDeviceId uint64, subDeviceId uint64, tokenHash string, tokenPrefix string) (*model.DeviceCredential, error) {
+	tx := GetTxContext(ctx, c.DB)
+	cred := model.DeviceCredential{
+		UserId:       userId,
+		MainDeviceId: mainDeviceId,
+		SubDeviceId:  subDeviceId,
+		TokenHash:    tokenHash,
+		TokenPrefix:  tokenPrefix,
+	}
+
+	result := tx.Create(&cred)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &cred, nil
+}
+
+func (c *credentialRepositoryImpl) GetCredentialsByUserId(ctx context.Context, userId uint64) ([]*model.DeviceCredential, error) {
+	tx := GetTxContext(ctx, c.DB)
+	var creds []*model.DeviceCredential
+	result := tx.Where("user_id = ? AND revoke_time IS NULL", userId).Order("id").Find(&creds)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return creds, nil
+}
+
+// UseCredential looks up an active credential and stamps last_used_time in the same statement.
+func (c *credentialRepositoryImpl) UseCredential(ctx context.Context, tokenHash string, now time.Time) (*model.DeviceCredential, bool, error) {
+	tx := GetTxContext(ctx, c.DB)
+	var creds []*model.DeviceCredential
+	result := tx.Raw(`UPDATE device_credentials SET last_used_time = ?
+		WHERE token_hash = ? AND revoke_time IS NULL RETURNING *`, now, tokenHash).Scan(&creds)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if len(creds) == 0 {
+		return nil, false, nil
+	}
+	return creds[0], true, nil
+}
+
+func (c *credentialRepositoryImpl) RevokeCredentials(ctx context.Context, userId uint64, mainDeviceId uint64, subDeviceId uint64, now time.Time) (int64, error) {
+	tx := GetTxContext(ctx, c.DB)
+	result := tx.Model(&model.DeviceCredential{}).
+		Where("user_id = ? AND main_device_id = ? AND sub_device_id = ? AND revoke_time IS NULL", userId, mainDeviceId, subDeviceId).
+		Update("revoke_time", now)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+func (c *credentialRepositoryImpl) RevokeMainDeviceCredentials(ctx context.Context, userId uint64, mainDeviceId uint64, now time.Time) (int64, error) {
+	tx := GetTxContext(ctx, c.DB)
+	result := tx.Model(&model.DeviceCredential{}).
+		Where("user_id = ? AND main_device_id = ? AND revoke_time IS NULL", userId, mainDeviceId).
+		Update("revoke_time", now)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
@@ -3,13 +3,17 @@ package repository
 import (
 	"context"
 	"device-communication/src/config"
+	"device-communication/src/metrics"
 	"device-communication/src/model"
+	"errors"
+	"time"
 
 	"gorm.io/gorm"
 )
 
 type DeviceRepository interface {
 	GetAllDevicesByUserId(ctx context.Context, userId uint64) ([]*model.MainDevice, error)
+	GetMainDevice(ctx context.Context, userId uint64, mainDeviceId uint64) (*model.MainDevice, bool, error)
 	GetMainDeviceCount(ctx context.Context, userId uint64) (int64, error)
 	GetSubDeviceCount(ctx context.Context, userId uint64, mainDeviceId uint64) (int64, error)
 	CheckRepeatedDevice(ctx context.Context, platform string, version string, deviceId string) (bool, error)
@@ -26,6 +30,7 @@ type deviceRepositoryImpl struct {
 }
 
 func (d *deviceRepositoryImpl) GetAllDevicesByUserId(ctx context.Context, userId uint64) ([]*model.MainDevice, error) {
+	defer metrics.ObserveRepository("device", "GetAllDevicesByUserId", time.Now())
 	tx := GetTxContext(ctx, d.DB)
 	var devices []*model.MainDevice
 	result := tx.Preload("SubDevices", func(db *gorm.DB) *gorm.DB {
@@ -38,7 +43,22 @@ func (d *deviceRepositoryImpl) GetAllDevicesByUserId(ctx context.Context, userId
 	return devices, nil
 }
 
+func (d *deviceRepositoryImpl) GetMainDevice(ctx context.Context, userId uint64, mainDeviceId uint64) (*model.MainDevice, bool, error) {
+	defer metrics.ObserveRepository("device", "GetMainDevice", time.Now())
+	tx := GetTxContext(ctx, d.DB)
+	var device model.MainDevice
+	result := tx.Where("user_id = ? AND id = ?", userId, mainDeviceId).First(&device)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, result.Error
+	}
+	return &device, true, nil
+}
+
 func (d *deviceRepositoryImpl) GetMainDeviceCount(ctx context.Context, userId uint64) (int64, error) {
+	defer metrics.ObserveRepository("device", "GetMainDeviceCount", time.Now())
 	tx := GetTxContext(ctx, d.DB)
 	var count int64
 	err := tx.Model(&model.MainDevice{}).Where("user_id=?", userId).Count(&count).Error
@@ -49,6 +69,7 @@ func (d *deviceRepositoryImpl) GetMainDeviceCount(ctx context.Context, userId ui
 }
 
 func (d *deviceRepositoryImpl) GetSubDeviceCount(ctx context.Context, userId uint64, mainDeviceId uint64) (int64, error) {
+	defer metrics.ObserveRepository("device", "GetSubDeviceCount", time.Now())
 	tx := GetTxContext(ctx, d.DB)
 	var count int64
 	err := tx.Model(&model.SubDevice{}).
@@ -63,6 +84,7 @@ func (d *deviceRepositoryImpl) GetSubDeviceCount(ctx context.Context, userId uin
 }
 
 func (d *deviceRepositoryImpl) CheckRepeatedDevice(ctx context.Context, platform string, version string, deviceId string) (bool, error) {
+	defer metrics.ObserveRepository("device", "CheckRepeatedDevice", time.Now())
 	tx := GetTxContext(ctx, d.DB)
 	var count int64
 	err := tx.Model(&model.MainDevice{}).Where("platform=? and version=? and device_id=?", platform, version, deviceId).Count(&count).Error
@@ -80,6 +102,7 @@ func (d *deviceRepositoryImpl) CheckRepeatedDevice(ctx context.Context, platform
 }
 
 func (d *deviceRepositoryImpl) BindMainDevice(ctx context.Context, userId uint64, platform string, version string, deviceId string) (*model.MainDevice, error) {
+	defer metrics.ObserveRepository("device", "BindMainDevice", time.Now())
 	tx := GetTxContext(ctx, d.DB)
 	mainDevice := model.MainDevice{
 		UserId:   userId,
@@ -96,6 +119,7 @@ func (d *deviceRepositoryImpl) BindMainDevice(ctx context.Context, userId uint64
 }
 
 func (d *deviceRepositoryImpl) BindSubDevice(ctx context.Context, mainDeviceId uint64, platform string, version string, deviceId string) (*model.SubDevice, error) {
+	defer metrics.ObserveRepository("device", "BindSubDevice", time.Now())
 	tx := GetTxContext(ctx, d.DB)
 	subDevice := model.SubDevice{
 		MainDeviceId: mainDeviceId,
@@ -112,6 +136,7 @@ func (d *deviceRepositoryImpl) BindSubDevice(ctx context.Context, mainDeviceId u
 }
 
 func (d *deviceRepositoryImpl) CheckMainDeviceBinding(ctx context.Context, userId uint64, mainDeviceId uint64) (bool, error) {
+	defer metrics.ObserveRepository("device", "CheckMainDeviceBinding", time.Now())
 	tx := GetTxContext(ctx, d.DB)
 	var count int64
 	result := tx.Model(&model.MainDevice{}).Where("user_id = ? AND id = ?", userId, mainDeviceId).Count(&count)
@@ -122,6 +147,7 @@ func (d *deviceRepositoryImpl) CheckMainDeviceBinding(ctx context.Context, userI
 }
 
 func (d *deviceRepositoryImpl) CheckSubDeviceBinding(ctx context.Context, userId uint64, mainDeviceId uint64, subDeviceId uint64) (bool, error) {
+	defer metrics.ObserveRepository("device", "CheckSubDeviceBinding", time.Now())
 	tx := GetTxContext(ctx, d.DB)
 	var count int64
 	result := tx.Table("main_devices m").Joins("JOIN sub_devices s ON m.id = s.main_device_id").
@@ -133,8 +159,9 @@ func (d *deviceRepositoryImpl) CheckSubDeviceBinding(ctx context.Context, userId
 }
 
 func (d *deviceRepositoryImpl) UnbindMainDevice(ctx context.Context, userId uint64, mainDeviceId uint64) (bool, error) {
+	defer metrics.ObserveRepository("device", "UnbindMainDevice", time.Now())
 	tx := GetTxContext(ctx, d.DB)
-	result := tx.Where("main_device_id = ?", userId, mainDeviceId).Delete(&model.SubDevice{})
+	result := tx.Where("main_device_id = ?", mainDeviceId).Delete(&model.SubDevice{})
 	if result.Error != nil {
 		return false, result.Error
 	}
@@ -147,6 +174,7 @@ func (d *deviceRepositoryImpl) UnbindMainDevice(ctx context.Context, userId uint
 }
 
 func (d *deviceRepositoryImpl) UnbindSubDevice(ctx context.Context, mainDeviceId uint64, subDeviceId uint64) error {
+	defer metrics.ObserveRepository("device", "UnbindSubDevice", time.Now())
 	tx := GetTxContext(ctx, d.DB)
 	result := tx.Where("main_device_id = ? AND id = ?", mainDeviceId, subDeviceId).Delete(&model.SubDevice{})
 	if result.Error != nil {
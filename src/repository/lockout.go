@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"device-communication/src/config"
+	"device-communication/src/model"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// lockoutLevelTTL is how long earlier lockouts count towards a longer one.
+const lockoutLevelTTL = 24 * time.Hour
+
+// LockoutRepository keeps failed login counters and locks in redis, and the lockout history in postgres.
+type LockoutRepository interface {
+	GetLock(ctx context.Context, subject string) (time.Duration, error)
+	AddFailure(ctx context.Context, subject string, window time.Duration) (int64, error)
+	ClearFailures(ctx context.Context, subject string) error
+	Lock(ctx context.Context, subject string, base time.Duration, max time.Duration) (time.Duration, error)
+	Unlock(ctx context.Context, subject string) (bool, error)
+	CreateEvent(ctx context.Context, event *model.LockoutEvent) error
+	GetEvents(ctx context.Context, username string, limit int) ([]*model.LockoutEvent, error)
+}
+
+type lockoutRepositoryImpl struct {
+	DB    *gorm.DB
+	Redis *redis.Client
+}
+
+var lockout LockoutRepository
+
+func init() {
+	lockout = &lockoutRepositoryImpl{
+		DB:    config.GlobalConfig.DB,
+		Redis: config.GlobalConfig.Redis,
+	}
+}
+
+func GetLockoutRepository() LockoutRepository {
+	return lockout
+}
+
+func (l *lockoutRepositoryImpl) GetLock(ctx context.Context, subject string) (time.Duration, error) {
+	ttl, err := l.Redis.PTTL(ctx, "login_lock:"+subject).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+func (l *lockoutRepositoryImpl) AddFailure(ctx context.Context, subject string, window time.Duration) (int64, error) {
+	key := "login_failure:" + subject
+	count, err := l.Redis.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		if err := l.Redis.PExpire(ctx, key, window).Err(); err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
+
+func (l *lockoutRepositoryImpl) ClearFailures(ctx context.Context, subject string) error {
+	return l.Redis.Del(ctx, "login_failure:"+subject).Err()
+}
+
+// Lock doubles the lock of the previous lockout within lockoutLevelTTL.
+func (l *lockoutRepositoryImpl) Lock(ctx context.Context, subject string, base time.Duration, max time.Duration) (time.Duration, error) {
+	levelKey := "login_lock_level:" + subject
+	pipe := l.Redis.TxPipeline()
+	level := pipe.Incr(ctx, levelKey)
+	pipe.PExpire(ctx, levelKey, lockoutLevelTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	duration := base
+	for i := int64(1); i < level.Val() && duration < max; i++ {
+		duration *= 2
+	}
+	if duration > max {
+		duration = max
+	}
+
+	pipe = l.Redis.TxPipeline()
+	pipe.Set(ctx, "login_lock:"+subject, level.Val(), duration)
+	pipe.Del(ctx, "login_failure:"+subject)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return duration, nil
+}
+
+func (l *lockoutRepositoryImpl) Unlock(ctx context.Context, subject string) (bool, error) {
+	pipe := l.Redis.TxPipeline()
+	locked := pipe.Del(ctx, "login_lock:"+subject)
+	pipe.Del(ctx, "login_lock_level:"+subject, "login_failure:"+subject)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return locked.Val() > 0, nil
+}
+
+func (l *lockoutRepositoryImpl) CreateEvent(ctx context.Context, event *model.LockoutEvent) error {
+	tx := GetTxContext(ctx, l.DB)
+	return tx.Create(event).Error
+}
+
+func (l *lockoutRepositoryImpl) GetEvents(ctx context.Context, username string, limit int) ([]*model.LockoutEvent, error) {
+	tx := GetTxContext(ctx, l.DB)
+	var events []*model.LockoutEvent
+	query := tx.Model(&model.LockoutEvent{})
+	if username != "" {
+		query = query.Where("username = ?", username)
+	}
+
+	result := query.Order("id DESC").Limit(limit).Find(&events)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return events, nil
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"device-communication/src/config"
+	"device-communication/src/model"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PolicyRepository interface {
+	UpsertPolicy(ctx context.Context, policy *model.RoomPolicy) error
+	GetPolicy(ctx context.Context, mainDeviceId uint64) (*model.RoomPolicy, bool, error)
+}
+
+type policyRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+var policy PolicyRepository
+
+func init() {
+	policy = &policyRepositoryImpl{
+		DB: config.GlobalConfig.DB,
+	}
+}
+
+func GetPolicyRepository() PolicyRepository {
+	return policy
+}
+
+func (p *policyRepositoryImpl) UpsertPolicy(ctx context.Context, policy *model.RoomPolicy) error {
+	tx := GetTxContext(ctx, p.DB)
+	result := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "main_device_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"max_sub_device", "idle_timeout_second", "allowed_frame_types", "max_message_byte", "update_time"}),
+	}).Create(policy)
+	return result.Error
+}
+
+func (p *policyRepositoryImpl) GetPolicy(ctx context.Context, mainDeviceId uint64) (*model.RoomPolicy, bool, error) {
+	tx := GetTxContext(ctx, p.DB)
+	var policy model.RoomPolicy
+	result := tx.Where("main_device_id = ?", mainDeviceId).First(&policy)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, result.Error
+	}
+	return &policy, true, nil
+}
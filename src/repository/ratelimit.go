@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"device-communication/src/config"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type RateLimitRepository interface {
+	// Hit counts one request in a fixed window, it returns the count so far and when the window ends.
+	Hit(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error)
+}
+
+type rateLimitRepositoryImpl struct {
+	Redis *redis.Client
+}
+
+var rateLimit RateLimitRepository
+
+func init() {
+	rateLimit = &rateLimitRepositoryImpl{
+		Redis: config.GlobalConfig.Redis,
+	}
+}
+
+func GetRateLimitRepository() RateLimitRepository {
+	return rateLimit
+}
+
+func (r *rateLimitRepositoryImpl) Hit(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	key = "rate_limit:" + key
+	pipe := r.Redis.TxPipeline()
+	count := pipe.Incr(ctx, key)
+	ttl := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, 0, err
+	}
+
+	if ttl.Val() < 0 {
+		if err := r.Redis.PExpire(ctx, key, window).Err(); err != nil {
+			return 0, 0, err
+		}
+		return count.Val(), window, nil
+	}
+	return count.Val(), ttl.Val(), nil
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"device-communication/src/config"
+	"device-communication/src/model"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SchemaRepository interface {
+	UpsertSchema(ctx context.Context, userId uint64, platform string, version string, content string, policy string) (*model.MessageSchema, error)
+	GetSchemasByUserId(ctx context.Context, userId uint64) ([]*model.MessageSchema, error)
+	GetSchema(ctx context.Context, userId uint64, platform string, version string) (*model.MessageSchema, bool, error)
+	DeleteSchema(ctx context.Context, userId uint64, schemaId uint64) (bool, error)
+	CreateViolation(ctx context.Context, violation *model.SchemaViolation) error
+	GetViolations(ctx context.Context, userId uint64, mainDeviceId uint64, action string, limit int) ([]*model.SchemaViolation, error)
+}
+
+type schemaRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+var schema SchemaRepository
+
+func init() {
+	schema = &schemaRepositoryImpl{
+		DB: config.GlobalConfig.DB,
+	}
+}
+
+func GetSchemaRepository() SchemaRepository {
+	return schema
+}
+
+func (s *schemaRepositoryImpl) UpsertSchema(ctx context.Context, userId uint64, platform string, version string, content string, policy string) (*model.MessageSchema, error) {
+	tx := GetTxContext(ctx, s.DB)
+	messageSchema := model.MessageSchema{
+		UserId:   userId,
+		Platform: platform,
+		Version:  version,
+		Schema:   content,
+		Policy:   policy,
+	}
+
+	result := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "platform"}, {Name: "version"}},
+		DoUpdates: clause.AssignmentColumns([]string{"schema", "policy", "update_time"}),
+	}).Create(&messageSchema)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &messageSchema, nil
+}
+
+func (s *schemaRepositoryImpl) GetSchemasByUserId(ctx context.Context, userId uint64) ([]*model.MessageSchema, error) {
+	tx := GetTxContext(ctx, s.DB)
+	var schemas []*model.MessageSchema
+	result := tx.Where("user_id = ?", userId).Order("id").Find(&schemas)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return schemas, nil
+}
+
+func (s *schemaRepositoryImpl) GetSchema(ctx context.Context, userId uint64, platform string, version string) (*model.MessageSchema, bool, error) {
+	tx := GetTxContext(ctx, s.DB)
+	var messageSchema model.MessageSchema
+	result := tx.Where("user_id = ? AND platform = ? AND version = ?", userId, platform, version).First(&messageSchema)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, result.Error
+	}
+	return &messageSchema, true, nil
+}
+
+func (s *schemaRepositoryImpl) DeleteSchema(ctx context.Context, userId uint64, schemaId uint64) (bool, error) {
+	tx := GetTxContext(ctx, s.DB)
+	result := tx.Where("user_id = ? AND id = ?", userId, schemaId).Delete(&model.MessageSchema{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (s *schemaRepositoryImpl) CreateViolation(ctx context.Context, violation *model.SchemaViolation) error {
+	tx := GetTxContext(ctx, s.DB)
+	return tx.Create(violation).Error
+}
+
+func (s *schemaRepositoryImpl) GetViolations(ctx context.Context, userId uint64, mainDeviceId uint64, action string, limit int) ([]*model.SchemaViolation, error) {
+	tx := GetTxContext(ctx, s.DB)
+	var violations []*model.SchemaViolation
+	query := tx.Where("user_id = ?", userId)
+	if mainDeviceId != 0 {
+		query = query.Where("main_device_id = ?", mainDeviceId)
+	}
+	if action != "" {
+		query = query.Where("action = ?", action)
+	}
+
+	result := query.Order("id DESC").Limit(limit).Find(&violations)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return violations, nil
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"device-communication/src/config"
+	"device-communication/src/model"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// sessionKeyPrefix is the key prefix the redis session store saves sessions with.
+const sessionKeyPrefix = "session_"
+
+type LoginSessionRepository interface {
+	AddSession(ctx context.Context, userId uint64, session *model.LoginSession, ttl time.Duration) error
+	GetSessions(ctx context.Context, userId uint64) ([]*model.LoginSession, error)
+	RemoveSession(ctx context.Context, userId uint64, sessionId string) (bool, error)
+	RemoveAllSessions(ctx context.Context, userId uint64) error
+}
+
+type loginSessionRepositoryImpl struct {
+	Redis *redis.Client
+}
+
+var loginSession LoginSessionRepository
+
+func init() {
+	loginSession = &loginSessionRepositoryImpl{
+		Redis: config.GlobalConfig.Redis,
+	}
+}
+
+func GetLoginSessionRepository() LoginSessionRepository {
+	return loginSession
+}
+
+func userSessionsKey(userId uint64) string {
+	return fmt.Sprintf("user_sessions:%d", userId)
+}
+
+func (l *loginSessionRepositoryImpl) AddSession(ctx context.Context, userId uint64, session *model.LoginSession, ttl time.Duration) error {
+	value, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	key := userSessionsKey(userId)
+	pipe := l.Redis.TxPipeline()
+	pipe.HSet(ctx, key, session.SessionId, value)
+	pipe.Expire(ctx, key, ttl)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetSessions drops the sessions the store already expired.
+func (l *loginSessionRepositoryImpl) GetSessions(ctx context.Context, userId uint64) ([]*model.LoginSession, error) {
+	key := userSessionsKey(userId)
+	values, err := l.Redis.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*model.LoginSession, 0, len(values))
+	for sessionId, value := range values {
+		exist, err := l.Redis.Exists(ctx, sessionKeyPrefix+sessionId).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		var session model.LoginSession
+		if exist == 0 || json.Unmarshal([]byte(value), &session) != nil {
+			if err := l.Redis.HDel(ctx, key, sessionId).Err(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		sessions = append(sessions, &session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreateTime.After(sessions[j].CreateTime)
+	})
+	return sessions, nil
+}
+
+func (l *loginSessionRepositoryImpl) RemoveSession(ctx context.Context, userId uint64, sessionId string) (bool, error) {
+	pipe := l.Redis.TxPipeline()
+	removed := pipe.HDel(ctx, userSessionsKey(userId), sessionId)
+	pipe.Del(ctx, sessionKeyPrefix+sessionId)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return removed.Val() > 0, nil
+}
+
+// RemoveAllSessions drops the index of a user together with every session it still lists.
+func (l *loginSessionRepositoryImpl) RemoveAllSessions(ctx context.Context, userId uint64) error {
+	key := userSessionsKey(userId)
+	sessionIds, err := l.Redis.HKeys(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+
+	pipe := l.Redis.TxPipeline()
+	for _, sessionId := range sessionIds {
+		pipe.Del(ctx, sessionKeyPrefix+sessionId)
+	}
+	pipe.Del(ctx, key)
+	_, err = pipe.Exec(ctx)
+	return err
+}
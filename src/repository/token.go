@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"device-communication/src/config"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposeLoginChallenge    = "login_challenge"
+)
+
+// OneTimeTokenRepository keeps the hash of single-use tokens, a user has at most one token per purpose.
+type OneTimeTokenRepository interface {
+	SaveToken(ctx context.Context, purpose string, userId uint64, tokenHash string, ttl time.Duration) error
+	TakeToken(ctx context.Context, purpose string, tokenHash string) (uint64, bool, error)
+	DeleteTokens(ctx context.Context, userId uint64) error
+}
+
+type oneTimeTokenRepositoryImpl struct {
+	Redis *redis.Client
+}
+
+var oneTimeToken OneTimeTokenRepository
+
+func init() {
+	oneTimeToken = &oneTimeTokenRepositoryImpl{
+		Redis: config.GlobalConfig.Redis,
+	}
+}
+
+func GetOneTimeTokenRepository() OneTimeTokenRepository {
+	return oneTimeToken
+}
+
+func tokenKey(purpose string, tokenHash string) string {
+	return fmt.Sprintf("%s:token:%s", purpose, tokenHash)
+}
+
+func tokenOwnerKey(purpose string, userId uint64) string {
+	return fmt.Sprintf("%s:user:%d", purpose, userId)
+}
+
+func (o *oneTimeTokenRepositoryImpl) SaveToken(ctx context.Context, purpose string, userId uint64, tokenHash string, ttl time.Duration) error {
+	ownerKey := tokenOwnerKey(purpose, userId)
+	previous, err := o.Redis.Get(ctx, ownerKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+
+	pipe := o.Redis.TxPipeline()
+	if previous != "" {
+		pipe.Del(ctx, tokenKey(purpose, previous))
+	}
+	pipe.Set(ctx, tokenKey(purpose, tokenHash), userId, ttl)
+	pipe.Set(ctx, ownerKey, tokenHash, ttl)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (o *oneTimeTokenRepositoryImpl) TakeToken(ctx context.Context, purpose string, tokenHash string) (uint64, bool, error) {
+	key := tokenKey(purpose, tokenHash)
+	pipe := o.Redis.TxPipeline()
+	value := pipe.Get(ctx, key)
+	pipe.Del(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return 0, false, err
+	}
+	if value.Err() != nil {
+		if errors.Is(value.Err(), redis.Nil) {
+			return 0, false, nil
+		}
+		return 0, false, value.Err()
+	}
+
+	userId, err := strconv.ParseUint(value.Val(), 10, 64)
+	if err != nil {
+		return 0, false, err
+	}
+	if err := o.Redis.Del(ctx, tokenOwnerKey(purpose, userId)).Err(); err != nil {
+		return 0, false, err
+	}
+	return userId, true, nil
+}
+
+// DeleteTokens drops the unused tokens of every purpose, a purged user id must not be handed out by a later TakeToken.
+func (o *oneTimeTokenRepositoryImpl) DeleteTokens(ctx context.Context, userId uint64) error {
+	pipe := o.Redis.TxPipeline()
+	for _, purpose := range []string{TokenPurposePasswordReset, TokenPurposeEmailVerification, TokenPurposeLoginChallenge} {
+		ownerKey := tokenOwnerKey(purpose, userId)
+		tokenHash, err := o.Redis.Get(ctx, ownerKey).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		pipe.Del(ctx, tokenKey(purpose, tokenHash), ownerKey)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"device-communication/src/config"
+	"device-communication/src/model"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TotpRepository interface {
+	GetTotp(ctx context.Context, userId uint64) (*model.UserTotp, bool, error)
+	SavePendingTotp(ctx context.Context, userId uint64, secret string) error
+	EnableTotp(ctx context.Context, userId uint64) (bool, error)
+	UseStep(ctx context.Context, userId uint64, step int64) (bool, error)
+	DeleteTotp(ctx context.Context, userId uint64) error
+	ReplaceRecoveryCodes(ctx context.Context, userId uint64, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userId uint64, codeHash string, now time.Time) (bool, error)
+}
+
+type totpRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+var totp TotpRepository
+
+func init() {
+	totp = &totpRepositoryImpl{
+		DB: config.GlobalConfig.DB,
+	}
+}
+
+func GetTotpRepository() TotpRepository {
+	return totp
+}
+
+func (t *totpRepositoryImpl) GetTotp(ctx context.Context, userId uint64) (*model.UserTotp, bool, error) {
+	tx := GetTxContext(ctx, t.DB)
+	var totp model.UserTotp
+	result := tx.Where("user_id = ?", userId).First(&totp)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, result.Error
+	}
+	return &totp, true, nil
+}
+
+// SavePendingTotp replaces an unconfirmed secret, an enabled one is left alone.
+func (t *totpRepositoryImpl) SavePendingTotp(ctx context.Context, userId uint64, secret string) error {
+	tx := GetTxContext(ctx, t.DB)
+	result := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Eq{Column: "user_totps.enabled", Value: false}}},
+		DoUpdates: clause.AssignmentColumns([]string{"secret", "last_used_step", "update_time"}),
+	}).Create(&model.UserTotp{UserId: userId, Secret: secret})
+	return result.Error
+}
+
+func (t *totpRepositoryImpl) EnableTotp(ctx context.Context, userId uint64) (bool, error) {
+	tx := GetTxContext(ctx, t.DB)
+	result := tx.Model(&model.UserTotp{}).Where("user_id = ? AND enabled = ?", userId, false).Update("enabled", true)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// UseStep accepts every time step once so a code cannot be replayed.
+func (t *totpRepositoryImpl) UseStep(ctx context.Context, userId uint64, step int64) (bool, error) {
+	tx := GetTxContext(ctx, t.DB)
+	result := tx.Model(&model.UserTotp{}).Where("user_id = ? AND last_used_step < ?", userId, step).Update("last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (t *totpRepositoryImpl) DeleteTotp(ctx context.Context, userId uint64) error {
+	tx := GetTxContext(ctx, t.DB)
+	if err := tx.Where("user_id = ?", userId).Delete(&model.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userId).Delete(&model.UserTotp{}).Error
+}
+
+func (t *totpRepositoryImpl) ReplaceRecoveryCodes(ctx context.Context, userId uint64, codeHashes []string) error {
+	tx := GetTxContext(ctx, t.DB)
+	if err := tx.Where("user_id = ?", userId).Delete(&model.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+
+	codes := make([]*model.RecoveryCode, 0, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes = append(codes, &model.RecoveryCode{UserId: userId, CodeHash: hash})
+	}
+	return tx.Create(&codes).Error
+}
+
+func (t *totpRepositoryImpl) UseRecoveryCode(ctx context.Context, userId uint64, codeHash string, now time.Time) (bool, error) {
+	tx := GetTxContext(ctx, t.DB)
+	result := tx.Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_time IS NULL", userId, codeHash).
+		Update("used_time", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
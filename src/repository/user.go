@@ -5,6 +5,7 @@ import (
 	"device-communication/src/config"
 	"device-communication/src/model"
 	"errors"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -14,6 +15,15 @@ type UserRepository interface {
 	SelectUserByName(ctx context.Context, username string) (*model.User, bool, error)
 	UpdatePassword(ctx context.Context, ID uint64, newHashedPassword string) (ok bool, err error)
 	CheckUserExist(ctx context.Context, ID uint64) (exist bool, err error)
+	GetUserById(ctx context.Context, ID uint64) (*model.User, bool, error)
+	VerifyEmail(ctx context.Context, ID uint64) (ok bool, err error)
+	UpdateProfile(ctx context.Context, ID uint64, fields map[string]interface{}) (ok bool, err error)
+	CheckUsernameUsed(ctx context.Context, username string) (used bool, err error)
+	DeleteUser(ctx context.Context, ID uint64) (ok bool, err error)
+	GetDeletedUserByName(ctx context.Context, username string) (*model.User, bool, error)
+	RestoreUser(ctx context.Context, ID uint64, deletedAfter time.Time) (ok bool, err error)
+	GetPurgeableUserIds(ctx context.Context, deletedBefore time.Time, limit int) ([]uint64, error)
+	PurgeUser(ctx context.Context, ID uint64) error
 }
 
 type userRepositoryImpl struct {
@@ -42,7 +52,8 @@ func (a *userRepositoryImpl) UserRegister(ctx context.Context, username string,
 		Email:    email,
 	}
 
-	result := tx.Where("username=?", username).FirstOrCreate(&user)
+	// a deleted account keeps its username until it is purged
+	result := tx.Unscoped().Where("username=?", username).FirstOrCreate(&user)
 	if result.Error != nil {
 		return nil, false, result.Error
 	}
@@ -52,7 +63,7 @@ func (a *userRepositoryImpl) UserRegister(ctx context.Context, username string,
 func (a *userRepositoryImpl) SelectUserByName(ctx context.Context, username string) (*model.User, bool, error) {
 	tx := GetTxContext(ctx, a.DB)
 	var user = model.User{Username: username}
-	result := tx.Select("id", "username", "password").Where("username=?", username).First(&user)
+	result := tx.Select("id", "username", "password", "name", "email").Where("username=?", username).First(&user)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return nil, false, nil
@@ -85,3 +96,121 @@ func (a *userRepositoryImpl) CheckUserExist(ctx context.Context, ID uint64) (exi
 	}
 	return true, nil
 }
+
+func (a *userRepositoryImpl) GetUserById(ctx context.Context, ID uint64) (*model.User, bool, error) {
+	tx := GetTxContext(ctx, a.DB)
+	var user model.User
+	result := tx.Select("id", "username", "password", "name", "email", "email_verified", "create_time", "update_time").Where("id=?", ID).First(&user)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, result.Error
+	}
+	return &user, true, nil
+}
+
+func (a *userRepositoryImpl) VerifyEmail(ctx context.Context, ID uint64) (bool, error) {
+	tx := GetTxContext(ctx, a.DB)
+	result := tx.Model(&model.User{}).Where("id=?", ID).Updates(map[string]interface{}{"email_verified": true})
+	if result.Error != nil {
+		return false, result.Error
+	} else if result.RowsAffected == 0 {
+		return false, nil
+	}
+	return true, nil
+}
+
+func (a *userRepositoryImpl) UpdateProfile(ctx context.Context, ID uint64, fields map[string]interface{}) (bool, error) {
+	tx := GetTxContext(ctx, a.DB)
+	result := tx.Model(&model.User{}).Where("id=?", ID).Updates(fields)
+	if result.Error != nil {
+		return false, result.Error
+	} else if result.RowsAffected == 0 {
+		return false, nil
+	}
+	return true, nil
+}
+
+// CheckUsernameUsed also counts deleted accounts that are not purged yet.
+func (a *userRepositoryImpl) CheckUsernameUsed(ctx context.Context, username string) (bool, error) {
+	tx := GetTxContext(ctx, a.DB)
+	var count int64
+	result := tx.Unscoped().Model(&model.User{}).Where("username=?", username).Count(&count)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return count > 0, nil
+}
+
+func (a *userRepositoryImpl) DeleteUser(ctx context.Context, ID uint64) (bool, error) {
+	tx := GetTxContext(ctx, a.DB)
+	result := tx.Where("id=?", ID).Delete(&model.User{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (a *userRepositoryImpl) GetDeletedUserByName(ctx context.Context, username string) (*model.User, bool, error) {
+	tx := GetTxContext(ctx, a.DB)
+	var user model.User
+	result := tx.Unscoped().Select("id", "username", "password", "delete_time").
+		Where("username=? AND delete_time IS NOT NULL", username).First(&user)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, result.Error
+	}
+	return &user, true, nil
+}
+
+func (a *userRepositoryImpl) RestoreUser(ctx context.Context, ID uint64, deletedAfter time.Time) (bool, error) {
+	tx := GetTxContext(ctx, a.DB)
+	result := tx.Unscoped().Model(&model.User{}).Where("id=? AND delete_time > ?", ID, deletedAfter).
+		Updates(map[string]interface{}{"delete_time": nil})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (a *userRepositoryImpl) GetPurgeableUserIds(ctx context.Context, deletedBefore time.Time, limit int) ([]uint64, error) {
+	tx := GetTxContext(ctx, a.DB)
+	var ids []uint64
+	result := tx.Unscoped().Model(&model.User{}).Where("delete_time <= ?", deletedBefore).
+		Order("delete_time").Limit(limit).Pluck("id", &ids)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return ids, nil
+}
+
+// PurgeUser removes a deleted account with the rows that belong to it, totp and recovery codes cascade.
+// the lockout history is kept by username, it goes too so a later account with the same name starts clean.
+func (a *userRepositoryImpl) PurgeUser(ctx context.Context, ID uint64) error {
+	tx := GetTxContext(ctx, a.DB)
+	username := tx.Unscoped().Model(&model.User{}).Select("username").Where("id=? AND delete_time IS NOT NULL", ID)
+	if err := tx.Where("username IN (?)", username).Delete(&model.LockoutEvent{}).Error; err != nil {
+		return err
+	}
+	for _, value := range []interface{}{
+		&model.Webhook{},
+		&model.SchemaViolation{},
+		&model.MessageSchema{},
+		&model.DeviceCredential{},
+	} {
+		if err := tx.Where("user_id=?", ID).Delete(value).Error; err != nil {
+			return err
+		}
+	}
+	result := tx.Where("main_device_id IN (?)", tx.Model(&model.MainDevice{}).Select("id").Where("user_id=?", ID)).Delete(&model.SubDevice{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if err := tx.Where("user_id=?", ID).Delete(&model.MainDevice{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("id=? AND delete_time IS NOT NULL", ID).Delete(&model.User{}).Error
+}
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"device-communication/src/config"
+	"device-communication/src/model"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, userId uint64, url string, secret string, events string) (*model.Webhook, error)
+	GetWebhooksByUserId(ctx context.Context, userId uint64) ([]*model.Webhook, error)
+	GetWebhook(ctx context.Context, webhookId uint64) (*model.Webhook, bool, error)
+	GetWebhookCount(ctx context.Context, userId uint64) (int64, error)
+	DeleteWebhook(ctx context.Context, userId uint64, webhookId uint64) (bool, error)
+	CreateDelivery(ctx context.Context, webhookId uint64, event string, payload string, nextRetryAt time.Time) (*model.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery *model.WebhookDelivery) error
+	LeaseDueDeliveries(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]*model.WebhookDelivery, error)
+	GetDeliveriesByUserId(ctx context.Context, userId uint64, webhookId uint64, status string, limit int) ([]*model.WebhookDelivery, error)
+	ResetDelivery(ctx context.Context, userId uint64, deliveryId uint64, nextRetryAt time.Time) (bool, error)
+}
+
+type webhookRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+var webhook WebhookRepository
+
+func init() {
+	webhook = &webhookRepositoryImpl{
+		DB: config.GlobalConfig.DB,
+	}
+}
+
+func GetWebhookRepository() WebhookRepository {
+	return webhook
+}
+
+func (w *webhookRepositoryImpl) CreateWebhook(ctx context.Context, userId uint64, url string, secret string, events string) (*model.Webhook, error) {
+	tx := GetTxContext(ctx, w.DB)
+	hook := model.Webhook{
+		UserId: userId,
+		Url:    url,
+		Secret: secret,
+		Events: events,
+	}
+
+	result := tx.Create(&hook)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &hook, nil
+}
+
+func (w *webhookRepositoryImpl) GetWebhooksByUserId(ctx context.Context, userId uint64) ([]*model.Webhook, error) {
+	tx := GetTxContext(ctx, w.DB)
+	var hooks []*model.Webhook
+	result := tx.Where("user_id = ?", userId).Order("id").Find(&hooks)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return hooks, nil
+}
+
+func (w *webhookRepositoryImpl) GetWebhook(ctx context.Context, webhookId uint64) (*model.Webhook, bool, error) {
+	tx := GetTxContext(ctx, w.DB)
+	var hook model.Webhook
+	result := tx.Where("id = ?", webhookId).First(&hook)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, result.Error
+	}
+	return &hook, true, nil
+}
+
+func (w *webhookRepositoryImpl) GetWebhookCount(ctx context.Context, userId uint64) (int64, error) {
+	tx := GetTxContext(ctx, w.DB)
+	var count int64
+	err := tx.Model(&model.Webhook{}).Where("user_id = ?", userId).Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (w *webhookRepositoryImpl) DeleteWebhook(ctx context.Context, userId uint64, webhookId uint64) (bool, error) {
+	tx := GetTxContext(ctx, w.DB)
+	result := tx.Where("user_id = ? AND id = ?", userId, webhookId).Delete(&model.Webhook{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (w *webhookRepositoryImpl) CreateDelivery(ctx context.Context, webhookId uint64, event string, payload string, nextRetryAt time.Time) (*model.WebhookDelivery, error) {
+	tx := GetTxContext(ctx, w.DB)
+	delivery := model.WebhookDelivery{
+		WebhookId:   webhookId,
+		Event:       event,
+		Payload:     payload,
+		Status:      model.WebhookDeliveryStatusPending,
+		NextRetryAt: nextRetryAt,
+	}
+
+	result := tx.Create(&delivery)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &delivery, nil
+}
+
+func (w *webhookRepositoryImpl) UpdateDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	tx := GetTxContext(ctx, w.DB)
+	result := tx.Model(&model.WebhookDelivery{}).Where("id = ?", delivery.Id).Updates(map[string]interface{}{
+		"status":          delivery.Status,
+		"attempts":        delivery.Attempts,
+		"last_error":      delivery.LastError,
+		"next_retry_time": delivery.NextRetryAt,
+	})
+	return result.Error
+}
+
+func (w *webhookRepositoryImpl) LeaseDueDeliveries(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	tx := GetTxContext(ctx, w.DB)
+	var deliveries []*model.WebhookDelivery
+	result := tx.Raw(`UPDATE webhook_deliveries SET next_retry_time = ?, update_time = ?
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = ? AND next_retry_time <= ?
+			ORDER BY next_retry_time LIMIT ? FOR UPDATE SKIP LOCKED
+		) RETURNING *`,
+		leaseUntil, now, model.WebhookDeliveryStatusPending, now, limit).Scan(&deliveries)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return deliveries, nil
+}
+
+func (w *webhookRepositoryImpl) GetDeliveriesByUserId(ctx context.Context, userId uint64, webhookId uint64, status string, limit int) ([]*model.WebhookDelivery, error) {
+	tx := GetTxContext(ctx, w.DB)
+	var deliveries []*model.WebhookDelivery
+	query := tx.Model(&model.WebhookDelivery{}).
+		Joins("JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id").
+		Where("webhooks.user_id = ?", userId)
+	if webhookId != 0 {
+		query = query.Where("webhook_deliveries.webhook_id = ?", webhookId)
+	}
+	if status != "" {
+		query = query.Where("webhook_deliveries.status = ?", status)
+	}
+
+	result := query.Select("webhook_deliveries.*").Order("webhook_deliveries.id DESC").Limit(limit).Find(&deliveries)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return deliveries, nil
+}
+
+func (w *webhookRepositoryImpl) ResetDelivery(ctx context.Context, userId uint64, deliveryId uint64, nextRetryAt time.Time) (bool, error) {
+	tx := GetTxContext(ctx, w.DB)
+	result := tx.Model(&model.WebhookDelivery{}).
+		Where("id = ? AND webhook_id IN (?)", deliveryId, tx.Model(&model.Webhook{}).Select("id").Where("user_id = ?", userId)).
+		Updates(map[string]interface{}{
+			"status":          model.WebhookDeliveryStatusPending,
+			"attempts":        0,
+			"last_error":      "",
+			"next_retry_time": nextRetryAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
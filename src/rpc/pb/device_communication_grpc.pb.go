@@ -0,0 +1,306 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: device_communication.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	DeviceCommunication_Connect_FullMethodName          = "/devicecommunication.v1.DeviceCommunication/Connect"
+	DeviceCommunication_BindMainDevice_FullMethodName   = "/devicecommunication.v1.DeviceCommunication/BindMainDevice"
+	DeviceCommunication_UnbindMainDevice_FullMethodName = "/devicecommunication.v1.DeviceCommunication/UnbindMainDevice"
+	DeviceCommunication_BindSubDevice_FullMethodName    = "/devicecommunication.v1.DeviceCommunication/BindSubDevice"
+	DeviceCommunication_UnbindSubDevice_FullMethodName  = "/devicecommunication.v1.DeviceCommunication/UnbindSubDevice"
+	DeviceCommunication_GetDevices_FullMethodName       = "/devicecommunication.v1.DeviceCommunication/GetDevices"
+)
+
+// DeviceCommunicationClient is the client API for DeviceCommunication service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DeviceCommunicationClient interface {
+	Connect(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ConnectRequest, Frame], error)
+	BindMainDevice(ctx context.Context, in *BindMainDeviceRequest, opts ...grpc.CallOption) (*BindMainDeviceResponse, error)
+	UnbindMainDevice(ctx context.Context, in *UnbindMainDeviceRequest, opts ...grpc.CallOption) (*UnbindMainDeviceResponse, error)
+	BindSubDevice(ctx context.Context, in *BindSubDeviceRequest, opts ...grpc.CallOption) (*BindSubDeviceResponse, error)
+	UnbindSubDevice(ctx context.Context, in *UnbindSubDeviceRequest, opts ...grpc.CallOption) (*UnbindSubDeviceResponse, error)
+	GetDevices(ctx context.Context, in *GetDevicesRequest, opts ...grpc.CallOption) (*GetDevicesResponse, error)
+}
+
+type deviceCommunicationClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDeviceCommunicationClient(cc grpc.ClientConnInterface) DeviceCommunicationClient {
+	return &deviceCommunicationClient{cc}
+}
+
+func (c *deviceCommunicationClient) Connect(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ConnectRequest, Frame], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DeviceCommunication_ServiceDesc.Streams[0], DeviceCommunication_Connect_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ConnectRequest, Frame]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DeviceCommunication_ConnectClient = grpc.BidiStreamingClient[ConnectRequest, Frame]
+
+func (c *deviceCommunicationClient) BindMainDevice(ctx context.Context, in *BindMainDeviceRequest, opts ...grpc.CallOption) (*BindMainDeviceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BindMainDeviceResponse)
+	err := c.cc.Invoke(ctx, DeviceCommunication_BindMainDevice_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deviceCommunicationClient) UnbindMainDevice(ctx context.Context, in *UnbindMainDeviceRequest, opts ...grpc.CallOption) (*UnbindMainDeviceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UnbindMainDeviceResponse)
+	err := c.cc.Invoke(ctx, DeviceCommunication_UnbindMainDevice_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deviceCommunicationClient) BindSubDevice(ctx context.Context, in *BindSubDeviceRequest, opts ...grpc.CallOption) (*BindSubDeviceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BindSubDeviceResponse)
+	err := c.cc.Invoke(ctx, DeviceCommunication_BindSubDevice_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deviceCommunicationClient) UnbindSubDevice(ctx context.Context, in *UnbindSubDeviceRequest, opts ...grpc.CallOption) (*UnbindSubDeviceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UnbindSubDeviceResponse)
+	err := c.cc.Invoke(ctx, DeviceCommunication_UnbindSubDevice_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deviceCommunicationClient) GetDevices(ctx context.Context, in *GetDevicesRequest, opts ...grpc.CallOption) (*GetDevicesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetDevicesResponse)
+	err := c.cc.Invoke(ctx, DeviceCommunication_GetDevices_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeviceCommunicationServer is the server API for DeviceCommunication service.
+// All implementations must embed UnimplementedDeviceCommunicationServer
+// for forward compatibility.
+type DeviceCommunicationServer interface {
+	Connect(grpc.BidiStreamingServer[ConnectRequest, Frame]) error
+	BindMainDevice(context.Context, *BindMainDeviceRequest) (*BindMainDeviceResponse, error)
+	UnbindMainDevice(context.Context, *UnbindMainDeviceRequest) (*UnbindMainDeviceResponse, error)
+	BindSubDevice(context.Context, *BindSubDeviceRequest) (*BindSubDeviceResponse, error)
+	UnbindSubDevice(context.Context, *UnbindSubDeviceRequest) (*UnbindSubDeviceResponse, error)
+	GetDevices(context.Context, *GetDevicesRequest) (*GetDevicesResponse, error)
+	mustEmbedUnimplementedDeviceCommunicationServer()
+}
+
+// UnimplementedDeviceCommunicationServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDeviceCommunicationServer struct{}
+
+func (UnimplementedDeviceCommunicationServer) Connect(grpc.BidiStreamingServer[ConnectRequest, Frame]) error {
+	return status.Error(codes.Unimplemented, "method Connect not implemented")
+}
+func (UnimplementedDeviceCommunicationServer) BindMainDevice(context.Context, *BindMainDeviceRequest) (*BindMainDeviceResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method BindMainDevice not implemented")
+}
+func (UnimplementedDeviceCommunicationServer) UnbindMainDevice(context.Context, *UnbindMainDeviceRequest) (*UnbindMainDeviceResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method UnbindMainDevice not implemented")
+}
+func (UnimplementedDeviceCommunicationServer) BindSubDevice(context.Context, *BindSubDeviceRequest) (*BindSubDeviceResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method BindSubDevice not implemented")
+}
+func (UnimplementedDeviceCommunicationServer) UnbindSubDevice(context.Context, *UnbindSubDeviceRequest) (*UnbindSubDeviceResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method UnbindSubDevice not implemented")
+}
+func (UnimplementedDeviceCommunicationServer) GetDevices(context.Context, *GetDevicesRequest) (*GetDevicesResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetDevices not implemented")
+}
+func (UnimplementedDeviceCommunicationServer) mustEmbedUnimplementedDeviceCommunicationServer() {}
+func (UnimplementedDeviceCommunicationServer) testEmbeddedByValue()                             {}
+
+// UnsafeDeviceCommunicationServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DeviceCommunicationServer will
+// result in compilation errors.
+type UnsafeDeviceCommunicationServer interface {
+	mustEmbedUnimplementedDeviceCommunicationServer()
+}
+
+func RegisterDeviceCommunicationServer(s grpc.ServiceRegistrar, srv DeviceCommunicationServer) {
+	// If the following call panics, it indicates UnimplementedDeviceCommunicationServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&DeviceCommunication_ServiceDesc, srv)
+}
+
+func _DeviceCommunication_Connect_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(DeviceCommunicationServer).Connect(&grpc.GenericServerStream[ConnectRequest, Frame]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DeviceCommunication_ConnectServer = grpc.BidiStreamingServer[ConnectRequest, Frame]
+
+func _DeviceCommunication_BindMainDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BindMainDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceCommunicationServer).BindMainDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DeviceCommunication_BindMainDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceCommunicationServer).BindMainDevice(ctx, req.(*BindMainDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DeviceCommunication_UnbindMainDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnbindMainDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceCommunicationServer).UnbindMainDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DeviceCommunication_UnbindMainDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceCommunicationServer).UnbindMainDevice(ctx, req.(*UnbindMainDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DeviceCommunication_BindSubDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BindSubDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceCommunicationServer).BindSubDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DeviceCommunication_BindSubDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceCommunicationServer).BindSubDevice(ctx, req.(*BindSubDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DeviceCommunication_UnbindSubDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnbindSubDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceCommunicationServer).UnbindSubDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DeviceCommunication_UnbindSubDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceCommunicationServer).UnbindSubDevice(ctx, req.(*UnbindSubDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DeviceCommunication_GetDevices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDevicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceCommunicationServer).GetDevices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DeviceCommunication_GetDevices_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceCommunicationServer).GetDevices(ctx, req.(*GetDevicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// DeviceCommunication_ServiceDesc is the grpc.ServiceDesc for DeviceCommunication service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DeviceCommunication_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "devicecommunication.v1.DeviceCommunication",
+	HandlerType: (*DeviceCommunicationServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "BindMainDevice",
+			Handler:    _DeviceCommunication_BindMainDevice_Handler,
+		},
+		{
+			MethodName: "UnbindMainDevice",
+			Handler:    _DeviceCommunication_UnbindMainDevice_Handler,
+		},
+		{
+			MethodName: "BindSubDevice",
+			Handler:    _DeviceCommunication_BindSubDevice_Handler,
+		},
+		{
+			MethodName: "UnbindSubDevice",
+			Handler:    _DeviceCommunication_UnbindSubDevice_Handler,
+		},
+		{
+			MethodName: "GetDevices",
+			Handler:    _DeviceCommunication_GetDevices_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Connect",
+			Handler:       _DeviceCommunication_Connect_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "device_communication.proto",
+}
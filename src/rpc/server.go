@@ -0,0 +1,418 @@
+package rpc
+
+import (
+	"context"
+	"device-communication/src/dto"
+	"device-communication/src/dtoError"
+	logger "device-communication/src/log"
+	"device-communication/src/rpc/pb"
+	"device-communication/src/service"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type Server interface {
+	ListenAndServe(address string) error
+}
+
+type userIdKey struct{}
+
+type deviceIdentityKey struct{}
+
+type serverImpl struct {
+	pb.UnimplementedDeviceCommunicationServer
+	communication service.CommunicationSerivice
+	device        service.DeviceService
+	user          service.UserService
+	credential    service.DeviceCredentialService
+	logger        logger.Logger
+}
+
+var server Server
+
+func init() {
+	server = &serverImpl{
+		communication: service.GetCommunicationSerivice(),
+		device:        service.GetDeviceService(),
+		user:          service.GetUserService(),
+		credential:    service.GetDeviceCredentialService(),
+		logger:        logger.NewInfoLogger(),
+	}
+}
+
+func GetServer() Server {
+	return server
+}
+
+func (s *serverImpl) ListenAndServe(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	g := grpc.NewServer(
+		grpc.UnaryInterceptor(s.unaryAuthInterceptor),
+		grpc.StreamInterceptor(s.streamAuthInterceptor),
+	)
+	pb.RegisterDeviceCommunicationServer(g, s)
+	return g.Serve(listener)
+}
+
+func toStatus(err *dtoError.ServiceError) error {
+	var code codes.Code
+	switch err.StatusCode {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusConflict:
+		code = codes.AlreadyExists
+	case http.StatusTooManyRequests, http.StatusLocked:
+		code = codes.ResourceExhausted
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	case http.StatusGatewayTimeout:
+		code = codes.DeadlineExceeded
+	case http.StatusInternalServerError:
+		code = codes.Internal
+	default:
+		code = codes.Unknown
+	}
+	return status.Error(code, err.ExtrenalReason)
+}
+
+// authorization: Basic base64(username:password), the same account used to log in over http,
+// or Bearer <device credential> which only allows Connect as that device.
+// Basic runs on every call, so it goes through DeviceLoginService which caches the verdict.
+func (s *serverImpl) authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) > 0 && strings.HasPrefix(values[0], "Bearer ") {
+		identity, serviceErr := s.credential.AuthenticateDevice(ctx, strings.TrimPrefix(values[0], "Bearer "))
+		if serviceErr != nil {
+			return nil, toStatus(serviceErr)
+		}
+		ctx = context.WithValue(ctx, deviceIdentityKey{}, identity)
+		return context.WithValue(ctx, userIdKey{}, identity.UserId), nil
+	}
+	if len(values) == 0 || !strings.HasPrefix(values[0], "Basic ") {
+		return nil, status.Error(codes.Unauthenticated, "User not logged in")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(values[0], "Basic "))
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "User not logged in")
+	}
+	username, password, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "User not logged in")
+	}
+
+	req := dto.UserLoginRequest{Username: username, Password: password}
+	if p, ok := peer.FromContext(ctx); ok {
+		req.Ip, _, _ = net.SplitHostPort(p.Addr.String())
+	}
+	res, serviceErr := s.user.DeviceLoginService(ctx, &req)
+	if serviceErr != nil {
+		return nil, toStatus(serviceErr)
+	}
+	if res.Challenge != "" {
+		return nil, status.Error(codes.Unauthenticated, "two-factor authentication is enabled, use a device credential")
+	}
+	return context.WithValue(ctx, userIdKey{}, res.ID), nil
+}
+
+func getUserId(ctx context.Context) uint64 {
+	id, _ := ctx.Value(userIdKey{}).(uint64)
+	return id
+}
+
+func getDeviceIdentity(ctx context.Context) (*dto.DeviceIdentity, bool) {
+	identity, ok := ctx.Value(deviceIdentityKey{}).(*dto.DeviceIdentity)
+	return identity, ok
+}
+
+func (s *serverImpl) unaryAuthInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := getDeviceIdentity(ctx); ok {
+		return nil, status.Error(codes.PermissionDenied, "device credential can only connect")
+	}
+	return handler(ctx, req)
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (a *authenticatedStream) Context() context.Context {
+	return a.ctx
+}
+
+func (s *serverImpl) streamAuthInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+func (s *serverImpl) Connect(stream pb.DeviceCommunication_ConnectServer) error {
+	ctx := stream.Context()
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	attach := first.GetAttach()
+	if attach == nil {
+		return status.Error(codes.InvalidArgument, "first message must be attach")
+	}
+
+	if identity, ok := getDeviceIdentity(ctx); ok && (identity.MainDeviceId != attach.MainDeviceId || identity.SubDeviceId != attach.SubDeviceId) {
+		return toStatus(dtoError.GetServiceErrorWarpper().NewDeviceCredentialMismatchError())
+	}
+
+	// resume-token metadata carries the token from the header of the previous Connect
+	var resumeToken string
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("resume-token"); len(values) > 0 {
+		resumeToken = values[0]
+	}
+
+	conn := &streamConnection{stream: stream, closed: make(chan struct{})}
+	var session service.DeviceSession
+	var serviceErr *dtoError.ServiceError
+	switch attach.Role {
+	case pb.Role_ROLE_MAIN:
+		session, serviceErr = s.communication.AttachMainDevice(ctx, &dto.MainDeviceConnectionRequest{
+			UserId:       getUserId(ctx),
+			MainDeviceId: attach.MainDeviceId,
+			ResumeToken:  resumeToken,
+		}, conn)
+	case pb.Role_ROLE_SUB:
+		session, serviceErr = s.communication.AttachSubDevice(ctx, &dto.SubDeviceConnectionRequest{
+			UserId:       getUserId(ctx),
+			MainDeviceId: attach.MainDeviceId,
+			SubDeviceId:  attach.SubDeviceId,
+			ResumeToken:  resumeToken,
+		}, conn)
+	default:
+		return status.Error(codes.InvalidArgument, "unknown role")
+	}
+	if serviceErr != nil {
+		return toStatus(serviceErr)
+	}
+	if err := stream.SendHeader(metadata.Pairs("resume-token", session.ResumeToken())); err != nil {
+		session.Suspend()
+		return err
+	}
+	if err := conn.start(); err != nil {
+		session.Suspend()
+		return err
+	}
+
+	frames := make(chan *pb.Frame)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			frame := req.GetFrame()
+			if frame == nil {
+				continue
+			}
+			select {
+			case frames <- frame:
+			case <-conn.closed:
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case frame := <-frames:
+			messageType := websocket.TextMessage
+			if frame.Type == pb.FrameType_FRAME_TYPE_BINARY {
+				messageType = websocket.BinaryMessage
+			}
+			session.HandleMessage(messageType, frame.Data)
+		case err := <-recvErr:
+			if errors.Is(err, io.EOF) {
+				session.Close()
+			} else {
+				session.Suspend()
+			}
+			return nil
+		case <-conn.closed:
+			session.Suspend()
+			return status.Error(codes.Aborted, conn.reason)
+		}
+	}
+}
+
+type streamConnection struct {
+	stream    pb.DeviceCommunication_ConnectServer
+	mu        sync.Mutex
+	closed    chan struct{}
+	closeOnce sync.Once
+	reason    string
+	// frames written before the header with the resume token went out wait here
+	started bool
+	pending []*pb.Frame
+}
+
+func (c *streamConnection) WriteMessage(messageType int, data []byte) error {
+	var frameType pb.FrameType
+	switch messageType {
+	case websocket.TextMessage:
+		frameType = pb.FrameType_FRAME_TYPE_TEXT
+	case websocket.BinaryMessage:
+		frameType = pb.FrameType_FRAME_TYPE_BINARY
+	case websocket.CloseMessage:
+		if len(data) > 2 {
+			c.reason = string(data[2:])
+		}
+		return nil
+	default:
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.closed:
+		return status.Error(codes.Aborted, "connection closed")
+	default:
+	}
+	frame := &pb.Frame{Type: frameType, Data: data}
+	if !c.started {
+		c.pending = append(c.pending, frame)
+		return nil
+	}
+	return c.stream.Send(frame)
+}
+
+func (c *streamConnection) start() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.started = true
+	for _, frame := range c.pending {
+		if err := c.stream.Send(frame); err != nil {
+			return err
+		}
+	}
+	c.pending = nil
+	return nil
+}
+
+func (c *streamConnection) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+func (s *serverImpl) BindMainDevice(ctx context.Context, req *pb.BindMainDeviceRequest) (*pb.BindMainDeviceResponse, error) {
+	res, serviceErr := s.device.BindMainDevice(ctx, &dto.BindMainDeviceRequest{
+		UserId:   getUserId(ctx),
+		Platform: req.Platform,
+		Version:  req.Version,
+		DeviceId: req.DeviceId,
+	})
+	if serviceErr != nil {
+		return nil, toStatus(serviceErr)
+	}
+	return &pb.BindMainDeviceResponse{Ok: res.Ok, MainDeviceId: res.MainDeviceId}, nil
+}
+
+func (s *serverImpl) UnbindMainDevice(ctx context.Context, req *pb.UnbindMainDeviceRequest) (*pb.UnbindMainDeviceResponse, error) {
+	res, serviceErr := s.device.UnBindMainDevice(ctx, &dto.UnbindMainDeviceRequest{
+		UserId:       getUserId(ctx),
+		MainDeviceId: req.MainDeviceId,
+	})
+	if serviceErr != nil {
+		return nil, toStatus(serviceErr)
+	}
+	return &pb.UnbindMainDeviceResponse{Ok: res.Ok}, nil
+}
+
+func (s *serverImpl) BindSubDevice(ctx context.Context, req *pb.BindSubDeviceRequest) (*pb.BindSubDeviceResponse, error) {
+	res, serviceErr := s.device.BindSubDevice(ctx, &dto.BindSubDeviceRequest{
+		UserId:       getUserId(ctx),
+		MainDeviceId: req.MainDeviceId,
+		Platform:     req.Platform,
+		Version:      req.Version,
+		DeviceId:     req.DeviceId,
+	})
+	if serviceErr != nil {
+		return nil, toStatus(serviceErr)
+	}
+	return &pb.BindSubDeviceResponse{Ok: res.Ok, SubDeviceId: res.SubDeviceId}, nil
+}
+
+func (s *serverImpl) UnbindSubDevice(ctx context.Context, req *pb.UnbindSubDeviceRequest) (*pb.UnbindSubDeviceResponse, error) {
+	_, serviceErr := s.device.UnBindSubDevice(ctx, &dto.UnbindSubDeviceRequest{
+		UserId:       getUserId(ctx),
+		MainDeviceId: req.MainDeviceId,
+		SubDeviceId:  req.SubDeviceId,
+	})
+	if serviceErr != nil {
+		return nil, toStatus(serviceErr)
+	}
+	return &pb.UnbindSubDeviceResponse{}, nil
+}
+
+func (s *serverImpl) GetDevices(ctx context.Context, req *pb.GetDevicesRequest) (*pb.GetDevicesResponse, error) {
+	res, serviceErr := s.device.GetDevicesByUserId(ctx, &dto.GetDevicesByUserIdRequest{UserId: getUserId(ctx)})
+	if serviceErr != nil {
+		return nil, toStatus(serviceErr)
+	}
+
+	response := &pb.GetDevicesResponse{
+		MainDevices: make([]*pb.MainDevice, 0, len(res.MainDevices)),
+	}
+	for _, device := range res.MainDevices {
+		subDevices := make([]*pb.SubDevice, 0, len(device.SubDevices))
+		for _, subDevice := range device.SubDevices {
+			subDevices = append(subDevices, &pb.SubDevice{
+				Id:           subDevice.Id,
+				MainDeviceId: subDevice.MainDeviceId,
+				Platform:     subDevice.Platform,
+				Version:      subDevice.Version,
+				DeviceId:     subDevice.DeviceId,
+			})
+		}
+		response.MainDevices = append(response.MainDevices, &pb.MainDevice{
+			Id:         device.Id,
+			UserId:     device.UserId,
+			Platform:   device.Platform,
+			Version:    device.Version,
+			DeviceId:   device.DeviceId,
+			SubDevices: subDevices,
+		})
+	}
+	return response, nil
+}
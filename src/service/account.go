@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"device-communication/src/common"
+	"device-communication/src/config"
+	"device-communication/src/dto"
+	"device-communication/src/dtoError"
+	logger "device-communication/src/log"
+	"device-communication/src/repository"
+	"device-communication/src/telemetry"
+	"device-communication/src/webhook"
+	"time"
+)
+
+type AccountService interface {
+	DeleteAccount(ctx context.Context, req *dto.DeleteAccountRequest) (*dto.DeleteAccountResponse, *dtoError.ServiceError)
+	RestoreAccount(ctx context.Context, req *dto.RestoreAccountRequest) *dtoError.ServiceError
+}
+
+type accountServiceImpl struct {
+	userRepo       repository.UserRepository
+	deviceRepo     repository.DeviceRepository
+	credentialRepo repository.CredentialRepository
+	totpRepo       repository.TotpRepository
+	tokenRepo      repository.OneTimeTokenRepository
+	sessionRepo    repository.LoginSessionRepository
+	lockout        *loginLockout
+	dispatcher     webhook.Dispatcher
+	errWarpper     dtoError.ServiceErrorWarpper
+	GRACE          time.Duration
+	PURGE_INTERVAL time.Duration
+	PURGE_BATCH    int
+	logger         logger.Logger
+}
+
+var account AccountService
+
+func init() {
+	a := config.GlobalConfig.YamlConfig.AccountDeletion
+	impl := &accountServiceImpl{
+		userRepo:       repository.GetuserRepository(),
+		deviceRepo:     repository.GetDeviceRepository(),
+		credentialRepo: repository.GetCredentialRepository(),
+		totpRepo:       repository.GetTotpRepository(),
+		tokenRepo:      repository.GetOneTimeTokenRepository(),
+		sessionRepo:    repository.GetLoginSessionRepository(),
+		lockout:        newLoginLockout(),
+		dispatcher:     webhook.GetDispatcher(),
+		errWarpper:     dtoError.GetServiceErrorWarpper(),
+		GRACE:          time.Duration(a.Grace) * time.Second,
+		PURGE_INTERVAL: time.Duration(a.PurgeInterval) * time.Second,
+		PURGE_BATCH:    a.PurgeBatch,
+		logger:         logger.NewInfoLogger(),
+	}
+	account = impl
+	if impl.PURGE_INTERVAL > 0 {
+		go impl.purgeLoop()
+	}
+}
+
+func GetAccountService() AccountService {
+	return account
+}
+
+// DeleteAccount unbinds every device and signs the user out, the account itself is purged after the grace period.
+func (a *accountServiceImpl) DeleteAccount(ctx context.Context, req *dto.DeleteAccountRequest) (*dto.DeleteAccountResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "AccountService.DeleteAccount")
+	defer span.End()
+	data := map[string]any{"user_id": req.UserId, "ip": req.Ip}
+	userModel, ok, err := a.userRepo.GetUserById(ctx, req.UserId)
+	if err != nil {
+		a.logger.Error(common.GetUUID(ctx), "a.userRepo.GetUserById", data, err)
+		return nil, a.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		a.logger.Info(common.GetUUID(ctx), "a.userRepo.GetUserById", data, nil)
+		return nil, a.errWarpper.NewUserNotExist(req.UserId)
+	}
+
+	if serviceErr := a.lockout.check(ctx, userModel.Username, req.Ip); serviceErr != nil {
+		a.logger.Info(common.GetUUID(ctx), "a.lockout.check", data, serviceErr.InternalError)
+		return nil, serviceErr
+	}
+
+	password := newPasswordByHashed(userModel.Password)
+	if !password.Check(req.Password) {
+		a.logger.Info(common.GetUUID(ctx), "password.Check", data, nil)
+		a.lockout.fail(ctx, userModel.Username, req.Ip)
+		return nil, a.errWarpper.NewLoginFailedServiceError(nil)
+	}
+
+	totp, ok, err := a.totpRepo.GetTotp(ctx, req.UserId)
+	if err != nil {
+		a.logger.Error(common.GetUUID(ctx), "a.totpRepo.GetTotp", data, err)
+		return nil, a.errWarpper.NewDBServiceError(err)
+	} else if ok && totp.Enabled {
+		ok, err = verifySecondFactor(ctx, a.totpRepo, req.UserId, req.Code)
+		if err != nil {
+			a.logger.Error(common.GetUUID(ctx), "verifySecondFactor", data, err)
+			return nil, a.errWarpper.NewDBServiceError(err)
+		} else if !ok {
+			a.logger.Info(common.GetUUID(ctx), "verifySecondFactor", data, nil)
+			a.lockout.fail(ctx, userModel.Username, req.Ip)
+			return nil, a.errWarpper.NewTwoFactorCodeInvalidError()
+		}
+	}
+	a.lockout.succeed(ctx, userModel.Username)
+
+	txContext, tx := repository.SetTxContext(ctx)
+	mainDevices, err := a.deviceRepo.GetAllDevicesByUserId(txContext, req.UserId)
+	if err != nil {
+		a.logger.Error(common.GetUUID(ctx), "a.deviceRepo.GetAllDevicesByUserId", data, err)
+		tx.Rollback()
+		return nil, a.errWarpper.NewDBServiceError(err)
+	}
+
+	for _, mainDevice := range mainDevices {
+		if _, err := a.deviceRepo.UnbindMainDevice(txContext, req.UserId, mainDevice.Id); err != nil {
+			a.logger.Error(common.GetUUID(ctx), "a.deviceRepo.UnbindMainDevice", data, err)
+			tx.Rollback()
+			return nil, a.errWarpper.NewDBServiceError(err)
+		}
+		_, err := a.credentialRepo.RevokeMainDeviceCredentials(txContext, req.UserId, mainDevice.Id, time.Now())
+		if err != nil {
+			a.logger.Error(common.GetUUID(ctx), "a.credentialRepo.RevokeMainDeviceCredentials", data, err)
+			tx.Rollback()
+			return nil, a.errWarpper.NewDBServiceError(err)
+		}
+	}
+
+	ok, err = a.userRepo.DeleteUser(txContext, req.UserId)
+	if err != nil {
+		a.logger.Error(common.GetUUID(ctx), "a.userRepo.DeleteUser", data, err)
+		tx.Rollback()
+		return nil, a.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		a.logger.Info(common.GetUUID(ctx), "a.userRepo.DeleteUser", data, nil)
+		tx.Rollback()
+		return nil, a.errWarpper.NewUserNotExist(req.UserId)
+	}
+
+	err = tx.Commit().Error
+	if err != nil {
+		a.logger.Error(common.GetUUID(ctx), "tx.Commit", data, err)
+		return nil, a.errWarpper.NewDBCommitServiceError(err)
+	}
+
+	// revoking can not be rolled back, so the sessions go only once the deletion is committed.
+	// if redis fails here the other sessions stay until server.session.age_second, the deletion still stands
+	_, serviceErr := GetLoginSessionService().RevokeAllSessions(ctx, &dto.RevokeAllLoginSessionsRequest{UserId: req.UserId})
+	if serviceErr != nil {
+		a.logger.Error(common.GetUUID(ctx), "RevokeAllSessions", data, serviceErr.InternalError)
+	}
+	for _, mainDevice := range mainDevices {
+		GetCommunicationSerivice().DisconnectDevice(ctx, req.UserId, mainDevice.Id, 0, "account deleted")
+		a.dispatcher.Publish(req.UserId, webhook.EventMainDeviceUnbind, map[string]any{"main_device_id": mainDevice.Id})
+	}
+
+	a.logger.Info(common.GetUUID(ctx), "DeleteAccount.end", data, nil)
+	return &dto.DeleteAccountResponse{PurgeTime: time.Now().Add(a.GRACE)}, nil
+}
+
+// RestoreAccount takes back a deletion within the grace period, the devices stay unbound.
+func (a *accountServiceImpl) RestoreAccount(ctx context.Context, req *dto.RestoreAccountRequest) *dtoError.ServiceError {
+	ctx, span := telemetry.Start(ctx, "AccountService.RestoreAccount")
+	defer span.End()
+	data := map[string]any{"username": req.Username, "ip": req.Ip}
+	if serviceErr := a.lockout.check(ctx, req.Username, req.Ip); serviceErr != nil {
+		a.logger.Info(common.GetUUID(ctx), "a.lockout.check", data, serviceErr.InternalError)
+		return serviceErr
+	}
+
+	userModel, ok, err := a.userRepo.GetDeletedUserByName(ctx, req.Username)
+	if err != nil {
+		a.logger.Error(common.GetUUID(ctx), "a.userRepo.GetDeletedUserByName", data, err)
+		return a.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		a.logger.Info(common.GetUUID(ctx), "a.userRepo.GetDeletedUserByName", data, nil)
+		a.lockout.fail(ctx, req.Username, req.Ip)
+		return a.errWarpper.NewLoginFailedServiceError(nil)
+	}
+
+	password := newPasswordByHashed(userModel.Password)
+	if !password.Check(req.Password) {
+		a.logger.Info(common.GetUUID(ctx), "password.Check", data, nil)
+		a.lockout.fail(ctx, req.Username, req.Ip)
+		return a.errWarpper.NewLoginFailedServiceError(nil)
+	}
+
+	ok, err = a.userRepo.RestoreUser(ctx, userModel.Id, time.Now().Add(-a.GRACE))
+	if err != nil {
+		a.logger.Error(common.GetUUID(ctx), "a.userRepo.RestoreUser", data, err)
+		return a.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		// the grace period is over, the account is waiting to be purged
+		a.logger.Info(common.GetUUID(ctx), "a.userRepo.RestoreUser", data, nil)
+		return a.errWarpper.NewLoginFailedServiceError(nil)
+	}
+	a.lockout.succeed(ctx, req.Username)
+
+	a.logger.Info(common.GetUUID(ctx), "RestoreAccount.end", data, nil)
+	return nil
+}
+
+func (a *accountServiceImpl) purgeLoop() {
+	ticker := time.NewTicker(a.PURGE_INTERVAL)
+	defer ticker.Stop()
+	for range ticker.C {
+		a.purge(context.Background())
+	}
+}
+
+// purge hard deletes the accounts whose grace period is over. the redis keys of an account are cleaned once its rows
+// are gone, a failure there is only logged: tokens and the session index expire on their own and the failure counters
+// of the lockout are kept by username and expire within a day.
+func (a *accountServiceImpl) purge(ctx context.Context) {
+	ctx, span := telemetry.Start(ctx, "AccountService.purge")
+	defer span.End()
+	ids, err := a.userRepo.GetPurgeableUserIds(ctx, time.Now().Add(-a.GRACE), a.PURGE_BATCH)
+	if err != nil {
+		a.logger.Error("", "a.userRepo.GetPurgeableUserIds", nil, err)
+		return
+	}
+
+	for _, id := range ids {
+		txContext, tx := repository.SetTxContext(ctx)
+		if err := a.userRepo.PurgeUser(txContext, id); err != nil {
+			a.logger.Error("", "a.userRepo.PurgeUser", id, err)
+			tx.Rollback()
+			continue
+		}
+		if err := tx.Commit().Error; err != nil {
+			a.logger.Error("", "tx.Commit", id, err)
+			continue
+		}
+		if err := a.tokenRepo.DeleteTokens(ctx, id); err != nil {
+			a.logger.Error("", "a.tokenRepo.DeleteTokens", id, err)
+		}
+		if err := a.sessionRepo.RemoveAllSessions(ctx, id); err != nil {
+			a.logger.Error("", "a.sessionRepo.RemoveAllSessions", id, err)
+		}
+		a.logger.Info("", "purge.user", id, nil)
+	}
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"device-communication/src/config"
+	"device-communication/src/dto"
+	"device-communication/src/dtoError"
+	logger "device-communication/src/log"
+	"device-communication/src/model"
+	"device-communication/src/repository"
+	"net/http"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestDeleteAccountCountsWrongPassword(t *testing.T) {
+	_, userRepo, totpRepo, lockoutRepo := newTestUserService(t)
+	a := &accountServiceImpl{
+		userRepo:   userRepo,
+		totpRepo:   totpRepo,
+		lockout:    newTestLockout(lockoutRepo),
+		errWarpper: dtoError.GetServiceErrorWarpper(),
+		logger:     logger.NewInfoLogger(),
+	}
+
+	req := &dto.DeleteAccountRequest{UserId: 1, Password: "guess", Ip: "10.0.0.4"}
+	for i := 0; i < 3; i++ {
+		_, serviceErr := a.DeleteAccount(context.Background(), req)
+		if serviceErr == nil || serviceErr.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("DeleteAccount() = %v, want a login failure", serviceErr)
+		}
+	}
+	if got := lockoutRepo.accountFailures("owner"); got != 3 {
+		t.Fatalf("account failures = %d, want every wrong password counted", got)
+	}
+
+	lockoutRepo.locks[ipLockSubject("10.0.0.4")] = time.Minute
+	req.Password = "current-password"
+	if _, serviceErr := a.DeleteAccount(context.Background(), req); serviceErr == nil || serviceErr.RetryAfter == 0 {
+		t.Fatalf("DeleteAccount() = %v, want the lockout", serviceErr)
+	}
+}
+
+// nopConnPool backs transactions that commit nothing, the repositories of these tests never reach it.
+type nopConnPool struct {
+	gorm.ConnPool
+}
+
+func (n *nopConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	return n, nil
+}
+
+func (n *nopConnPool) Commit() error   { return nil }
+func (n *nopConnPool) Rollback() error { return nil }
+
+func useNopTransactions(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: &nopConnPool{}}), &gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("gorm.Open() = %v", err)
+	}
+	previous := config.GlobalConfig.DB
+	config.GlobalConfig.DB = db
+	t.Cleanup(func() { config.GlobalConfig.DB = previous })
+}
+
+// fakeAccountUserRepository soft deletes like the users table, deleteTimes holds the users that are deleted.
+type fakeAccountUserRepository struct {
+	*fakeUserRepository
+	deleteTimes map[uint64]time.Time
+	purged      []uint64
+}
+
+func (f *fakeAccountUserRepository) GetUserById(ctx context.Context, ID uint64) (*model.User, bool, error) {
+	if _, ok := f.deleteTimes[ID]; ok {
+		return nil, false, nil
+	}
+	return f.fakeUserRepository.GetUserById(ctx, ID)
+}
+
+func (f *fakeAccountUserRepository) DeleteUser(ctx context.Context, ID uint64) (bool, error) {
+	if _, ok := f.deleteTimes[ID]; ok {
+		return false, nil
+	}
+	f.deleteTimes[ID] = time.Now()
+	return true, nil
+}
+
+func (f *fakeAccountUserRepository) GetDeletedUserByName(ctx context.Context, username string) (*model.User, bool, error) {
+	userModel, ok := f.users[username]
+	if !ok {
+		return nil, false, nil
+	}
+	_, deleted := f.deleteTimes[userModel.Id]
+	return userModel, deleted, nil
+}
+
+func (f *fakeAccountUserRepository) RestoreUser(ctx context.Context, ID uint64, deletedAfter time.Time) (bool, error) {
+	deleteTime, ok := f.deleteTimes[ID]
+	if !ok || !deleteTime.After(deletedAfter) {
+		return false, nil
+	}
+	delete(f.deleteTimes, ID)
+	return true, nil
+}
+
+func (f *fakeAccountUserRepository) GetPurgeableUserIds(ctx context.Context, deletedBefore time.Time, limit int) ([]uint64, error) {
+	var ids []uint64
+	for id, deleteTime := range f.deleteTimes {
+		if !deleteTime.After(deletedBefore) && len(ids) < limit {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (f *fakeAccountUserRepository) PurgeUser(ctx context.Context, ID uint64) error {
+	for username, userModel := range f.users {
+		if userModel.Id == ID {
+			delete(f.users, username)
+		}
+	}
+	delete(f.deleteTimes, ID)
+	f.purged = append(f.purged, ID)
+	return nil
+}
+
+type fakeDeviceRepository struct {
+	repository.DeviceRepository
+}
+
+func (f *fakeDeviceRepository) GetAllDevicesByUserId(ctx context.Context, userId uint64) ([]*model.MainDevice, error) {
+	return nil, nil
+}
+
+type fakeSessionRepository struct {
+	repository.LoginSessionRepository
+	sessions map[uint64][]string
+}
+
+func (f *fakeSessionRepository) GetSessions(ctx context.Context, userId uint64) ([]*model.LoginSession, error) {
+	sessions := make([]*model.LoginSession, 0, len(f.sessions[userId]))
+	for _, sessionId := range f.sessions[userId] {
+		sessions = append(sessions, &model.LoginSession{SessionId: sessionId})
+	}
+	return sessions, nil
+}
+
+func (f *fakeSessionRepository) RemoveSession(ctx context.Context, userId uint64, sessionId string) (bool, error) {
+	for i, id := range f.sessions[userId] {
+		if id == sessionId {
+			f.sessions[userId] = append(f.sessions[userId][:i], f.sessions[userId][i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeSessionRepository) RemoveAllSessions(ctx context.Context, userId uint64) error {
+	delete(f.sessions, userId)
+	return nil
+}
+
+func TestPurgeAfterGracePeriod(t *testing.T) {
+	useNopTransactions(t)
+	_, userRepo, totpRepo, lockoutRepo := newTestUserService(t)
+	accountUserRepo := &fakeAccountUserRepository{fakeUserRepository: userRepo, deleteTimes: map[uint64]time.Time{}}
+	tokenRepo := &fakeTokenRepository{}
+	sessionRepo := &fakeSessionRepository{sessions: map[uint64][]string{1: {"browser", "phone"}}}
+	a := &accountServiceImpl{
+		userRepo:    accountUserRepo,
+		deviceRepo:  &fakeDeviceRepository{},
+		totpRepo:    totpRepo,
+		tokenRepo:   tokenRepo,
+		sessionRepo: sessionRepo,
+		lockout:     newTestLockout(lockoutRepo),
+		dispatcher:  nopDispatcher{},
+		errWarpper:  dtoError.GetServiceErrorWarpper(),
+		GRACE:       time.Hour,
+		PURGE_BATCH: 10,
+		logger:      logger.NewInfoLogger(),
+	}
+	previousSessions := loginSession
+	loginSession = &loginSessionServiceImpl{sessionRepo: sessionRepo, errWarpper: a.errWarpper, logger: a.logger}
+	t.Cleanup(func() { loginSession = previousSessions })
+
+	ctx := context.Background()
+	if _, serviceErr := a.DeleteAccount(ctx, &dto.DeleteAccountRequest{UserId: 1, Password: "current-password"}); serviceErr != nil {
+		t.Fatalf("DeleteAccount() = %v", serviceErr)
+	}
+	if got := sessionRepo.sessions[1]; len(got) != 0 {
+		t.Fatalf("sessions = %q after the deletion, want none", got)
+	}
+
+	// within the grace period the account stays
+	a.purge(ctx)
+	if len(accountUserRepo.purged) != 0 {
+		t.Fatalf("purged %v within the grace period", accountUserRepo.purged)
+	}
+
+	accountUserRepo.deleteTimes[1] = time.Now().Add(-2 * a.GRACE)
+	restore := &dto.RestoreAccountRequest{Username: "owner", Password: "current-password"}
+	if serviceErr := a.RestoreAccount(ctx, restore); serviceErr == nil {
+		t.Fatal("RestoreAccount() restored an account after the grace period")
+	}
+
+	sessionRepo.sessions[1] = []string{"left-behind"}
+	a.purge(ctx)
+	if len(accountUserRepo.purged) != 1 || accountUserRepo.purged[0] != 1 {
+		t.Fatalf("purged %v, want user 1", accountUserRepo.purged)
+	}
+	if _, ok := accountUserRepo.users["owner"]; ok {
+		t.Fatal("the purged account is still there")
+	}
+	if len(tokenRepo.deleted) != 1 || tokenRepo.deleted[0] != 1 {
+		t.Fatalf("tokens deleted for %v, want user 1", tokenRepo.deleted)
+	}
+	if _, ok := sessionRepo.sessions[1]; ok {
+		t.Fatal("the session index of the purged account is still there")
+	}
+}
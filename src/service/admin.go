@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"device-communication/src/common"
+	"device-communication/src/dto"
+	"device-communication/src/dtoError"
+	logger "device-communication/src/log"
+	"device-communication/src/model"
+	"device-communication/src/repository"
+	"device-communication/src/telemetry"
+)
+
+type AdminService interface {
+	UnlockAccount(ctx context.Context, req *dto.UnlockAccountRequest) (*dto.UnlockAccountResponse, *dtoError.ServiceError)
+	GetLockoutEvents(ctx context.Context, req *dto.GetLockoutEventsRequest) (*dto.GetLockoutEventsResponse, *dtoError.ServiceError)
+}
+
+type adminServiceImpl struct {
+	lockoutRepo      repository.LockoutRepository
+	errWarpper       dtoError.ServiceErrorWarpper
+	MAX_EVENT_NUMBER int
+	logger           logger.Logger
+}
+
+var admin AdminService
+
+func init() {
+	admin = &adminServiceImpl{
+		lockoutRepo:      repository.GetLockoutRepository(),
+		errWarpper:       dtoError.GetServiceErrorWarpper(),
+		MAX_EVENT_NUMBER: 100,
+		logger:           logger.NewInfoLogger(),
+	}
+}
+
+func GetAdminService() AdminService {
+	return admin
+}
+
+// UnlockAccount also forgets earlier lockouts, so the next one starts from the base duration again.
+func (a *adminServiceImpl) UnlockAccount(ctx context.Context, req *dto.UnlockAccountRequest) (*dto.UnlockAccountResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "AdminService.UnlockAccount")
+	defer span.End()
+	subjects := []string{accountLockSubject(req.Username)}
+	if req.Ip != "" {
+		subjects = append(subjects, ipLockSubject(req.Ip))
+	}
+	for _, subject := range subjects {
+		if _, err := a.lockoutRepo.Unlock(ctx, subject); err != nil {
+			a.logger.Error(common.GetUUID(ctx), "a.lockoutRepo.Unlock", req, err)
+			return nil, a.errWarpper.NewDBServiceError(err)
+		}
+	}
+
+	err := a.lockoutRepo.CreateEvent(ctx, &model.LockoutEvent{
+		Username: req.Username,
+		Ip:       req.Ip,
+		Event:    model.LockoutEventUnlocked,
+		Operator: req.Operator,
+	})
+	if err != nil {
+		a.logger.Error(common.GetUUID(ctx), "a.lockoutRepo.CreateEvent", req, err)
+		return nil, a.errWarpper.NewDBServiceError(err)
+	}
+
+	a.logger.Info(common.GetUUID(ctx), "UnlockAccount.end", req, nil)
+	return &dto.UnlockAccountResponse{Ok: true}, nil
+}
+
+func (a *adminServiceImpl) GetLockoutEvents(ctx context.Context, req *dto.GetLockoutEventsRequest) (*dto.GetLockoutEventsResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "AdminService.GetLockoutEvents")
+	defer span.End()
+	events, err := a.lockoutRepo.GetEvents(ctx, req.Username, a.MAX_EVENT_NUMBER)
+	if err != nil {
+		a.logger.Error(common.GetUUID(ctx), "a.lockoutRepo.GetEvents", req, err)
+		return nil, a.errWarpper.NewDBServiceError(err)
+	}
+
+	response := &dto.GetLockoutEventsResponse{
+		Events: make([]*dto.LockoutEvent, 0, len(events)),
+	}
+	for _, event := range events {
+		response.Events = append(response.Events, &dto.LockoutEvent{
+			Id:          event.Id,
+			Username:    event.Username,
+			Ip:          event.Ip,
+			Event:       event.Event,
+			LockedUntil: event.LockedUntil,
+			Operator:    event.Operator,
+			CreateTime:  event.CreatedAt,
+		})
+	}
+	return response, nil
+}
@@ -1,128 +1,554 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"device-communication/src/common"
+	"device-communication/src/config"
 	"device-communication/src/dto"
 	"device-communication/src/dtoError"
+	logger "device-communication/src/log"
+	"device-communication/src/metrics"
+	"device-communication/src/model"
+	"device-communication/src/recording"
 	"device-communication/src/repository"
+	"device-communication/src/telemetry"
+	"device-communication/src/webhook"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
 type CommunicationSerivice interface {
 	MainDeviceConnection(ctx context.Context, req *dto.MainDeviceConnectionRequest, w http.ResponseWriter, r *http.Request) *dtoError.ServiceError
 	SubDeviceConnection(ctx context.Context, req *dto.SubDeviceConnectionRequest, w http.ResponseWriter, r *http.Request) *dtoError.ServiceError
+	SendCommandToMainDevice(ctx context.Context, req *dto.MainDeviceCommandRequest) (*dto.MainDeviceCommandResponse, *dtoError.ServiceError)
+	AttachMainDevice(ctx context.Context, req *dto.MainDeviceConnectionRequest, conn DeviceConnection) (DeviceSession, *dtoError.ServiceError)
+	AttachSubDevice(ctx context.Context, req *dto.SubDeviceConnectionRequest, conn DeviceConnection) (DeviceSession, *dtoError.ServiceError)
+	StartRecording(ctx context.Context, req *dto.StartRecordingRequest) (*dto.StartRecordingResponse, *dtoError.ServiceError)
+	StopRecording(ctx context.Context, req *dto.StopRecordingRequest) (*dto.StopRecordingResponse, *dtoError.ServiceError)
+	GetRecordings(ctx context.Context, req *dto.GetRecordingsRequest) (*dto.GetRecordingsResponse, *dtoError.ServiceError)
+	GetRecordingFile(ctx context.Context, req *dto.GetRecordingFileRequest) (string, *dtoError.ServiceError)
+	DisconnectDevice(ctx context.Context, userId uint64, mainDeviceId uint64, subDeviceId uint64, reason string)
+	DisconnectLoginSession(ctx context.Context, sessionId string, reason string)
+}
+
+// DeviceConnection is satisfied by *websocket.Conn, other transports adapt to it to share rooms.
+type DeviceConnection interface {
+	WriteMessage(messageType int, data []byte) error
+	Close() error
+}
+
+// preparedMessageWriter is implemented by *websocket.Conn, a prepared message is framed once for every recipient.
+type preparedMessageWriter interface {
+	WritePreparedMessage(pm *websocket.PreparedMessage) error
+}
+
+// broadcast is one message fanned out to many connections.
+type broadcast struct {
+	messageType int
+	data        []byte
+	prepared    *websocket.PreparedMessage
+	err         error
+}
+
+func newBroadcast(messageType int, data []byte) *broadcast {
+	return &broadcast{messageType: messageType, data: data}
+}
+
+// preparedMessage frames the message on the first websocket it is written to, other transports never pay for it.
+func (b *broadcast) preparedMessage() (*websocket.PreparedMessage, error) {
+	if b.prepared == nil && b.err == nil {
+		b.prepared, b.err = websocket.NewPreparedMessage(b.messageType, b.data)
+	}
+	return b.prepared, b.err
+}
+
+type broadcastWriter interface {
+	WriteBroadcast(b *broadcast) error
+}
+
+// writeBroadcast uses the shared frame when the connection can take it, other transports get the raw data.
+func writeBroadcast(conn DeviceConnection, b *broadcast) error {
+	switch w := conn.(type) {
+	case broadcastWriter:
+		return w.WriteBroadcast(b)
+	case preparedMessageWriter:
+		prepared, err := b.preparedMessage()
+		if err != nil {
+			return err
+		}
+		return w.WritePreparedMessage(prepared)
+	default:
+		return conn.WriteMessage(b.messageType, b.data)
+	}
+}
+
+// DeviceSession receives every inbound message of an attached device until Close.
+// Suspend is called instead of Close when the connection dropped, the device may then resume
+// the session with ResumeToken within the grace window.
+type DeviceSession interface {
+	HandleMessage(messageType int, message []byte)
+	ResumeToken() string
+	Suspend()
+	Kick(reason string)
+	Close()
 }
 
 type communicationSeriviceImpl struct {
-	deviceRepo             repository.DeviceRepository
-	errWarpper             dtoError.ServiceErrorWarpper
-	socket                 websocket.Upgrader
-	rooms                  webSocketRoomArray
-	mainDeviceIdleDuration time.Duration
+	userRepo            repository.UserRepository
+	deviceRepo          repository.DeviceRepository
+	schemaRepo          repository.SchemaRepository
+	policyRepo          repository.PolicyRepository
+	errWarpper          dtoError.ServiceErrorWarpper
+	dispatcher          webhook.Dispatcher
+	socket              websocket.Upgrader
+	rooms               webSocketRoomArray
+	resumes             resumeRegistry
+	logins              loginConnections
+	recordingDirectory  string
+	recordingLimit      recording.Limit
+	allowUnverifiedRoom bool
+	logger              logger.Logger
 }
 
+var (
+	errRoomClosed  = errors.New("room closed")
+	errCallTimeout = errors.New("call timeout")
+)
+
 type webSocketRoom struct {
-	MainConnection *websocket.Conn
-	SubConnections map[uint64]*websocket.Conn
+	MainConnection DeviceConnection
+	SubConnections map[uint64]DeviceConnection
+	subscriptions  map[uint64]map[string]bool
 	mu             sync.Mutex
+	mainWriteMu    sync.Mutex
+	pending        map[string]chan *dto.DeviceFrame
+	pendingMu      sync.Mutex
+	recorder       *recording.Recorder
+	recorderMu     sync.Mutex
+	policy         *roomPolicy
+	closed         chan struct{}
+}
+
+func parseDeviceFrame(message []byte) (*dto.DeviceFrame, bool) {
+	message = bytes.TrimSpace(message)
+	if len(message) == 0 || message[0] != '{' {
+		return nil, false
+	}
+
+	var frame dto.DeviceFrame
+	if err := json.Unmarshal(message, &frame); err != nil || frame.Type == "" {
+		return nil, false
+	}
+	return &frame, true
+}
+
+// messageTopic reads the topic of a message without a type, an untyped JSON object is still delivered by its topic.
+func messageTopic(message []byte) string {
+	message = bytes.TrimSpace(message)
+	if len(message) == 0 || message[0] != '{' {
+		return ""
+	}
+
+	var tagged struct {
+		Topic string `json:"topic"`
+	}
+	if err := json.Unmarshal(message, &tagged); err != nil {
+		return ""
+	}
+	return tagged.Topic
+}
+
+// topic segments are separated by '/', '*' matches one segment and '#' (last segment only) matches the rest.
+func isValidTopic(topic string, filter bool) bool {
+	if topic == "" {
+		return false
+	}
+	segments := strings.Split(topic, "/")
+	for i, segment := range segments {
+		if segment == "" {
+			return false
+		}
+		if !filter && strings.ContainsAny(segment, "*#") {
+			return false
+		}
+		if filter && strings.ContainsAny(segment, "*#") && segment != "*" && segment != "#" {
+			return false
+		}
+		if segment == "#" && i != len(segments)-1 {
+			return false
+		}
+	}
+	return true
+}
+
+func matchTopic(filter string, topic string) bool {
+	filterSegments := strings.Split(filter, "/")
+	topicSegments := strings.Split(topic, "/")
+	for i, segment := range filterSegments {
+		if segment == "#" {
+			return true
+		}
+		if i >= len(topicSegments) || (segment != "*" && segment != topicSegments[i]) {
+			return false
+		}
+	}
+	return len(filterSegments) == len(topicSegments)
+}
+
+const (
+	roomShardBits   = 6
+	roomShardNumber = 1 << roomShardBits
+)
+
+type roomKey struct {
+	userId       uint64
+	mainDeviceId uint64
+}
+
+// rooms are spread over shards so joins and lookups of different rooms do not wait on one lock,
+// a shard lock only guards its map, everything inside a room is guarded by the room's own lock.
+type roomShard struct {
+	rooms map[roomKey]*webSocketRoom
+	mu    sync.RWMutex
 }
 
 type webSocketRoomArray struct {
-	rooms                 map[string]*webSocketRoom
-	MAX_ROOM_NUMBER       int64
-	MAX_SUB_DEVICE_NUMBER int64
-	mu                    sync.RWMutex
+	shards                   []roomShard
+	MAX_ROOM_NUMBER          int64
+	MAX_SUBSCRIPTION_NUMBER  int
+	MAX_INFLIGHT_CALL_NUMBER int64
+	DEFAULT_CALL_TIMEOUT     time.Duration
+	MAX_CALL_TIMEOUT         time.Duration
+}
+
+func newRoomShards() []roomShard {
+	shards := make([]roomShard, roomShardNumber)
+	for i := range shards {
+		shards[i].rooms = make(map[roomKey]*webSocketRoom)
+	}
+	return shards
+}
+
+// a sub device that never subscribed receives every message, an untagged message goes to every sub device.
+// unsubscribing keeps the empty entry, so a sub device that dropped all its topics receives tagged messages no more.
+func (w *webSocketRoom) subscribed(subDeviceId uint64, topic string) bool {
+	filters, ok := w.subscriptions[subDeviceId]
+	if topic == "" || !ok {
+		return true
+	}
+	for filter := range filters {
+		if matchTopic(filter, topic) {
+			return true
+		}
+	}
+	return false
 }
 
-func (w *webSocketRoom) SendMessage(message []byte) {
+func (w *webSocketRoom) SendMessage(topic string, message []byte) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	for key, conn := range w.SubConnections {
-		err := conn.WriteMessage(websocket.TextMessage, message)
+	matched := make([]uint64, 0, len(w.SubConnections))
+	for key := range w.SubConnections {
+		if w.subscribed(key, topic) {
+			matched = append(matched, key)
+		}
+	}
+	if len(matched) == 0 {
+		return
+	}
+
+	b := newBroadcast(websocket.TextMessage, message)
+	for _, key := range matched {
+		conn := w.SubConnections[key]
+		err := writeBroadcast(conn, b)
 		if err != nil {
+			metrics.Dropped(metrics.DropWriteFailed)
 			conn.Close()
 			delete(w.SubConnections, key)
+			delete(w.subscriptions, key)
+			continue
 		}
+		metrics.Relayed(metrics.DirectionMainToSub, len(message))
+		w.record(recording.DirectionSubOut, key, websocket.TextMessage, message)
 	}
 }
 
-func (w *webSocketRoomArray) GetRoomKey(userId uint64, mainDeviceId uint64) string {
-	return fmt.Sprintf("%d::%d", userId, mainDeviceId)
+func (w *webSocketRoom) WriteToSub(subDeviceId uint64, messageType int, data []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	conn, ok := w.SubConnections[subDeviceId]
+	if !ok {
+		return errRoomClosed
+	}
+	w.record(recording.DirectionSubOut, subDeviceId, messageType, data)
+	return conn.WriteMessage(messageType, data)
 }
 
-func (w *webSocketRoomArray) GetOrCreateRoom(userId uint64, mainDeviceId uint64, mainConnection *websocket.Conn) (*webSocketRoom, bool) {
-	key := w.GetRoomKey(userId, mainDeviceId)
+func (w *webSocketRoom) Subscribe(subDeviceId uint64, filter string, max int) ([]string, string) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
+	filters, ok := w.subscriptions[subDeviceId]
+	if !ok {
+		filters = make(map[string]bool)
+		w.subscriptions[subDeviceId] = filters
+	}
+	if !filters[filter] && len(filters) >= max {
+		return nil, fmt.Sprintf("number of subscriptions should <= %d", max)
+	}
+	filters[filter] = true
+	return w.topics(subDeviceId), ""
+}
+
+func (w *webSocketRoom) Unsubscribe(subDeviceId uint64, filter string) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.subscriptions[subDeviceId], filter)
+	return w.topics(subDeviceId)
+}
+
+func (w *webSocketRoom) topics(subDeviceId uint64) []string {
+	topics := make([]string, 0, len(w.subscriptions[subDeviceId]))
+	for filter := range w.subscriptions[subDeviceId] {
+		topics = append(topics, filter)
+	}
+	sort.Strings(topics)
+	return topics
+}
 
-	room, exists := w.rooms[key]
+func (w *webSocketRoom) subDevices() []*dto.ConnectedSubDevice {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	subs := make([]*dto.ConnectedSubDevice, 0, len(w.SubConnections))
+	for subDeviceId := range w.SubConnections {
+		subs = append(subs, &dto.ConnectedSubDevice{SubDeviceId: subDeviceId, Topics: w.topics(subDeviceId)})
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].SubDeviceId < subs[j].SubDeviceId
+	})
+	return subs
+}
+
+func (w *webSocketRoom) hasSub(subDeviceId uint64) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, ok := w.SubConnections[subDeviceId]
+	return ok
+}
+
+func (w *webSocketRoom) WriteToMain(messageType int, data []byte) error {
+	w.mainWriteMu.Lock()
+	defer w.mainWriteMu.Unlock()
+	w.record(recording.DirectionMainOut, 0, messageType, data)
+	return w.MainConnection.WriteMessage(messageType, data)
+}
+
+func (w *webSocketRoom) record(direction string, subDeviceId uint64, messageType int, data []byte) {
+	w.recorderMu.Lock()
+	recorder := w.recorder
+	w.recorderMu.Unlock()
+	if recorder != nil {
+		recorder.Record(direction, subDeviceId, messageType, data)
+	}
+}
+
+func (w *webSocketRoom) StartRecording(path string, limit recording.Limit) (*recording.Recorder, error) {
+	w.recorderMu.Lock()
+	defer w.recorderMu.Unlock()
+	// a recording that reached its limit is done, a new one may start
+	if w.recorder != nil && !w.recorder.Stopped() {
+		return nil, nil
+	}
+
+	recorder, err := recording.NewRecorder(path, limit)
+	if err != nil {
+		return nil, err
+	}
+	w.recorder = recorder
+	return recorder, nil
+}
+
+func (w *webSocketRoom) StopRecording() (*recording.Recorder, error) {
+	w.recorderMu.Lock()
+	recorder := w.recorder
+	w.recorder = nil
+	w.recorderMu.Unlock()
+	if recorder == nil {
+		return nil, nil
+	}
+	return recorder, recorder.Close()
+}
+
+func (w *webSocketRoom) Call(ctx context.Context, frame *dto.DeviceFrame, timeout time.Duration) (*dto.DeviceFrame, error) {
+	message, err := json.Marshal(frame)
+	if err != nil {
+		return nil, err
+	}
+
+	reply := make(chan *dto.DeviceFrame, 1)
+	w.pendingMu.Lock()
+	w.pending[frame.Id] = reply
+	w.pendingMu.Unlock()
+	defer func() {
+		w.pendingMu.Lock()
+		delete(w.pending, frame.Id)
+		w.pendingMu.Unlock()
+	}()
+
+	if err := w.WriteToMain(websocket.TextMessage, message); err != nil {
+		return nil, errRoomClosed
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case res := <-reply:
+		return res, nil
+	case <-timer.C:
+		return nil, errCallTimeout
+	case <-w.closed:
+		return nil, errRoomClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (w *webSocketRoom) Resolve(frame *dto.DeviceFrame) bool {
+	w.pendingMu.Lock()
+	reply, ok := w.pending[frame.Id]
+	w.pendingMu.Unlock()
+	if !ok {
+		return false
+	}
+
+	select {
+	case reply <- frame:
+	default:
+	}
+	return true
+}
+
+func (w *webSocketRoomArray) GetRoomKey(userId uint64, mainDeviceId uint64) roomKey {
+	return roomKey{userId: userId, mainDeviceId: mainDeviceId}
+}
+
+func (w *webSocketRoomArray) getShard(key roomKey) *roomShard {
+	// fibonacci hashing, consecutive device ids of one user land on different shards
+	h := (key.userId*31 + key.mainDeviceId) * 0x9E3779B97F4A7C15
+	return &w.shards[h>>(64-roomShardBits)]
+}
+
+func (w *webSocketRoomArray) GetOrCreateRoom(userId uint64, mainDeviceId uint64, mainConnection DeviceConnection, policy *roomPolicy) (*webSocketRoom, bool) {
+	key := w.GetRoomKey(userId, mainDeviceId)
+	shard := w.getShard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	room, exists := shard.rooms[key]
 	if exists {
 		return room, true
 	}
 
+	metrics.Rooms.Inc()
 	newRoom := &webSocketRoom{
 		MainConnection: mainConnection,
-		SubConnections: make(map[uint64]*websocket.Conn),
+		SubConnections: make(map[uint64]DeviceConnection),
+		subscriptions:  make(map[uint64]map[string]bool),
+		pending:        make(map[string]chan *dto.DeviceFrame),
+		policy:         policy,
+		closed:         make(chan struct{}),
 	}
-	w.rooms[key] = newRoom
+	shard.rooms[key] = newRoom
 	return newRoom, false
 }
 
-func (w *webSocketRoomArray) JoinRoom(userId uint64, mainDeviceId uint64, subDeviceId uint64, subConnection *websocket.Conn) string {
+func (w *webSocketRoomArray) GetRoom(userId uint64, mainDeviceId uint64) (*webSocketRoom, bool) {
 	key := w.GetRoomKey(userId, mainDeviceId)
-	w.mu.Lock()
-	defer w.mu.Unlock()
-	room, ok := w.rooms[key]
+	shard := w.getShard(key)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	room, ok := shard.rooms[key]
+	return room, ok
+}
+
+func (w *webSocketRoomArray) JoinRoom(userId uint64, mainDeviceId uint64, subDeviceId uint64, subConnection DeviceConnection) (*webSocketRoom, string) {
+	room, ok := w.GetRoom(userId, mainDeviceId)
 	if !ok {
-		return "room not exist"
+		return nil, "room not exist"
 	}
 
 	room.mu.Lock()
 	defer room.mu.Unlock()
-	if len(room.SubConnections) >= int(w.MAX_SUB_DEVICE_NUMBER) {
-		return fmt.Sprintf("number of sub_device should <= %d", w.MAX_SUB_DEVICE_NUMBER)
+	// the room may have been removed between the lookup and taking its lock
+	if isClosed(room.closed) {
+		return nil, "room not exist"
+	}
+	if int64(len(room.SubConnections)) >= room.policy.maxSubDevice {
+		return nil, fmt.Sprintf("number of sub_device should <= %d", room.policy.maxSubDevice)
 	}
 
 	_, ok = room.SubConnections[subDeviceId]
 	if ok {
-		return "this subdevice has already joined"
+		return nil, "this subdevice has already joined"
 	}
 
 	room.SubConnections[subDeviceId] = subConnection
-	return ""
+	return room, ""
 }
 
 func (w *webSocketRoomArray) LeaveRoom(userId, mainDeviceId, subDeviceId uint64) {
-	key := w.GetRoomKey(userId, mainDeviceId)
-	w.mu.Lock()
-	defer w.mu.Unlock()
-	if room, ok := w.rooms[key]; ok {
+	if room, ok := w.GetRoom(userId, mainDeviceId); ok {
 		room.mu.Lock()
 		defer room.mu.Unlock()
 		delete(room.SubConnections, subDeviceId)
+		delete(room.subscriptions, subDeviceId)
 	}
 }
 
+// KickSub closes the connection of a sub device, its read loop then leaves the room.
+func (w *webSocketRoom) KickSub(subDeviceId uint64, reason string) bool {
+	w.mu.Lock()
+	conn, ok := w.SubConnections[subDeviceId]
+	w.mu.Unlock()
+	if !ok {
+		return false
+	}
+	_ = conn.WriteMessage(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason))
+	_ = conn.Close()
+	return true
+}
+
 func (w *webSocketRoomArray) RemoveRoom(userId uint64, mainDeviceId uint64) {
 	key := w.GetRoomKey(userId, mainDeviceId)
-	w.mu.Lock()
-	room, ok := w.rooms[key]
+	shard := w.getShard(key)
+	shard.mu.Lock()
+	room, ok := shard.rooms[key]
 	if ok {
-		delete(w.rooms, key)
+		delete(shard.rooms, key)
 	}
-	w.mu.Unlock()
+	shard.mu.Unlock()
 	if !ok {
 		return
 	}
 
+	metrics.Rooms.Dec()
+	close(room.closed)
 	room.mu.Lock()
 	if room.MainConnection != nil {
-		_ = room.MainConnection.WriteMessage(websocket.CloseMessage,
+		_ = room.WriteToMain(websocket.CloseMessage,
 			websocket.FormatCloseMessage(websocket.CloseNormalClosure, "room closed by server"))
 		_ = room.MainConnection.Close()
 	}
@@ -132,97 +558,770 @@ func (w *webSocketRoomArray) RemoveRoom(userId uint64, mainDeviceId uint64) {
 		_ = conn.Close()
 		delete(room.SubConnections, subId)
 	}
+	room.subscriptions = make(map[uint64]map[string]bool)
 	room.mu.Unlock()
+	room.StopRecording()
 }
 
 func (c *communicationSeriviceImpl) MainDeviceConnection(
-	ctx context.Context, req *dto.MainDeviceConnectionRequest, writer http.ResponseWriter, httpRequest *http.Request) *dtoError.ServiceError {
-	ok, err := c.deviceRepo.CheckMainDeviceBinding(ctx, req.UserId, req.MainDeviceId)
-	if err != nil {
-		return c.errWarpper.NewDBServiceError(err)
-	} else if !ok {
-		return c.errWarpper.NewMainDeviceNotBindingError()
+	ctx context.Context, req *dto.MainDeviceConnectionRequest, writer http.ResponseWriter, httpRequest *http.Request) (serviceErr *dtoError.ServiceError) {
+	defer c.observeRefused(metrics.RoleMain, &serviceErr)
+	var validator *messageValidator
+	var policy *roomPolicy
+	resuming := c.resumes.lookup(req.ResumeToken, req.UserId, req.MainDeviceId, 0)
+	if !resuming {
+		validator, policy, serviceErr = c.prepareMainDevice(ctx, req)
+		if serviceErr != nil {
+			return serviceErr
+		}
 	}
 
-	conn, err := c.socket.Upgrade(writer, httpRequest, nil)
+	token := newResumeToken()
+	conn, err := c.socket.Upgrade(writer, httpRequest, http.Header{ResumeTokenHeader: {token}})
 	if err != nil {
 		return c.errWarpper.NewWebsocketUpgradeFailedError(err)
 	}
 	defer conn.Close()
 
-	room, exists := c.rooms.GetOrCreateRoom(req.UserId, req.MainDeviceId, conn)
-	if exists {
+	var session DeviceSession
+	var errMessage string
+	if resuming {
+		session, errMessage = c.resumeDevice(req.ResumeToken, req.UserId, req.MainDeviceId, 0, conn, token)
+	} else {
+		session, errMessage = c.openMainDevice(req.UserId, req.MainDeviceId, conn, validator, policy, token)
+	}
+	if errMessage != "" {
+		metrics.UpgradeFailures.WithLabelValues(metrics.RoleMain, c.errWarpper.NewRoomCreateFailedError(errMessage).Type).Inc()
 		conn.WriteMessage(websocket.CloseMessage,
-			websocket.FormatCloseMessage(websocket.CloseNormalClosure, "This main device already has a websocket connection"))
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, errMessage))
 		return nil
 	}
-	defer c.rooms.RemoveRoom(req.UserId, req.MainDeviceId)
-	conn.SetReadDeadline(time.Now().Add(c.mainDeviceIdleDuration))
+	if req.LoginSessionId != "" {
+		c.logins.add(req.LoginSessionId, conn, session)
+		defer c.logins.remove(req.LoginSessionId, conn)
+	}
+	policy = c.policyOf(req.UserId, req.MainDeviceId)
+	conn.SetReadLimit(policy.maxMessageByte)
+	conn.SetReadDeadline(time.Now().Add(policy.idleTimeout))
 
 	for {
-		msgType, msg, err := room.MainConnection.ReadMessage()
+		msgType, msg, err := conn.ReadMessage()
 		if err != nil {
+			endSession(session, err)
 			return nil
 		}
 
-		conn.SetReadDeadline(time.Now().Add(c.mainDeviceIdleDuration))
-		switch msgType {
-		case websocket.TextMessage:
-			room.SendMessage(msg)
-		case websocket.CloseMessage:
-			return nil
-		}
+		conn.SetReadDeadline(time.Now().Add(policy.idleTimeout))
+		session.HandleMessage(msgType, msg)
 	}
 }
 
-func (c *communicationSeriviceImpl) SubDeviceConnection(ctx context.Context, req *dto.SubDeviceConnectionRequest, writer http.ResponseWriter, httpRequest *http.Request) *dtoError.ServiceError {
-	ok, err := c.deviceRepo.CheckSubDeviceBinding(ctx, req.UserId, req.MainDeviceId, req.SubDeviceId)
-	if err != nil {
-		return c.errWarpper.NewDBServiceError(err)
-	} else if !ok {
-		return c.errWarpper.NewSubDeviceNotBindingError()
+// endSession keeps the session for a resume unless the device closed the connection on purpose.
+func endSession(session DeviceSession, err error) {
+	if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+		session.Close()
+		return
+	}
+	session.Suspend()
+}
+
+func (c *communicationSeriviceImpl) SubDeviceConnection(ctx context.Context, req *dto.SubDeviceConnectionRequest, writer http.ResponseWriter, httpRequest *http.Request) (serviceErr *dtoError.ServiceError) {
+	defer c.observeRefused(metrics.RoleSub, &serviceErr)
+	resuming := c.resumes.lookup(req.ResumeToken, req.UserId, req.MainDeviceId, req.SubDeviceId)
+	if !resuming {
+		if serviceErr := c.prepareSubDevice(ctx, req); serviceErr != nil {
+			return serviceErr
+		}
 	}
 
-	conn, err := c.socket.Upgrade(writer, httpRequest, nil)
+	token := newResumeToken()
+	conn, err := c.socket.Upgrade(writer, httpRequest, http.Header{ResumeTokenHeader: {token}})
 	if err != nil {
 		return c.errWarpper.NewWebsocketUpgradeFailedError(err)
 	}
 	defer conn.Close()
 
-	errMessage := c.rooms.JoinRoom(req.UserId, req.MainDeviceId, req.SubDeviceId, conn)
-	defer c.rooms.LeaveRoom(req.UserId, req.MainDeviceId, req.SubDeviceId)
+	var session DeviceSession
+	var errMessage string
+	if resuming {
+		session, errMessage = c.resumeDevice(req.ResumeToken, req.UserId, req.MainDeviceId, req.SubDeviceId, conn, token)
+	} else {
+		session, errMessage = c.joinSubDevice(req.UserId, req.MainDeviceId, req.SubDeviceId, conn, token)
+	}
 	if errMessage != "" {
+		metrics.UpgradeFailures.WithLabelValues(metrics.RoleSub, c.errWarpper.NewRoomCreateFailedError(errMessage).Type).Inc()
 		conn.WriteMessage(websocket.CloseMessage,
 			websocket.FormatCloseMessage(websocket.CloseNormalClosure, errMessage))
 		return nil
 	}
+	if req.LoginSessionId != "" {
+		c.logins.add(req.LoginSessionId, conn, session)
+		defer c.logins.remove(req.LoginSessionId, conn)
+	}
+	conn.SetReadLimit(c.policyOf(req.UserId, req.MainDeviceId).maxMessageByte)
 
 	for {
-		_, _, err := conn.ReadMessage()
+		msgType, msg, err := conn.ReadMessage()
 		if err != nil {
+			endSession(session, err)
 			return nil
 		}
+		session.HandleMessage(msgType, msg)
+	}
+}
+
+func (c *communicationSeriviceImpl) AttachMainDevice(ctx context.Context, req *dto.MainDeviceConnectionRequest, conn DeviceConnection) (_ DeviceSession, serviceErr *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "CommunicationService.AttachMainDevice")
+	defer span.End()
+	defer c.observeRefused(metrics.RoleMain, &serviceErr)
+	token := newResumeToken()
+	if session, errMessage := c.resumeDevice(req.ResumeToken, req.UserId, req.MainDeviceId, 0, conn, token); errMessage == "" {
+		return session, nil
+	}
+
+	validator, policy, serviceErr := c.prepareMainDevice(ctx, req)
+	if serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	session, errMessage := c.openMainDevice(req.UserId, req.MainDeviceId, conn, validator, policy, token)
+	if errMessage != "" {
+		c.logger.Info(common.GetUUID(ctx), "c.openMainDevice", req, nil)
+		return nil, c.errWarpper.NewRoomCreateFailedError(errMessage)
+	}
+	return session, nil
+}
+
+func (c *communicationSeriviceImpl) AttachSubDevice(ctx context.Context, req *dto.SubDeviceConnectionRequest, conn DeviceConnection) (_ DeviceSession, serviceErr *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "CommunicationService.AttachSubDevice")
+	defer span.End()
+	defer c.observeRefused(metrics.RoleSub, &serviceErr)
+	token := newResumeToken()
+	if session, errMessage := c.resumeDevice(req.ResumeToken, req.UserId, req.MainDeviceId, req.SubDeviceId, conn, token); errMessage == "" {
+		return session, nil
+	}
+
+	if serviceErr := c.prepareSubDevice(ctx, req); serviceErr != nil {
+		return nil, serviceErr
+	}
+
+	session, errMessage := c.joinSubDevice(req.UserId, req.MainDeviceId, req.SubDeviceId, conn, token)
+	if errMessage != "" {
+		c.logger.Info(common.GetUUID(ctx), "c.joinSubDevice", req, nil)
+		return nil, c.errWarpper.NewRoomCreateFailedError(errMessage)
+	}
+	return session, nil
+}
+
+func (c *communicationSeriviceImpl) observeRefused(role string, serviceErr **dtoError.ServiceError) {
+	if *serviceErr != nil {
+		metrics.UpgradeFailures.WithLabelValues(role, (*serviceErr).Type).Inc()
+	}
+}
+
+func (c *communicationSeriviceImpl) prepareSubDevice(ctx context.Context, req *dto.SubDeviceConnectionRequest) *dtoError.ServiceError {
+	if serviceErr := checkEmailVerified(ctx, c.userRepo, req.UserId, c.allowUnverifiedRoom); serviceErr != nil {
+		c.logger.Info(common.GetUUID(ctx), "checkEmailVerified", req, serviceErr.InternalError)
+		return serviceErr
+	}
+
+	ok, err := c.deviceRepo.CheckSubDeviceBinding(ctx, req.UserId, req.MainDeviceId, req.SubDeviceId)
+	if err != nil {
+		c.logger.Error(common.GetUUID(ctx), "c.deviceRepo.CheckSubDeviceBinding", req, err)
+		return c.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		c.logger.Info(common.GetUUID(ctx), "c.deviceRepo.CheckSubDeviceBinding", req, nil)
+		return c.errWarpper.NewSubDeviceNotBindingError()
+	}
+	return nil
+}
+
+func (c *communicationSeriviceImpl) prepareMainDevice(ctx context.Context, req *dto.MainDeviceConnectionRequest) (*messageValidator, *roomPolicy, *dtoError.ServiceError) {
+	if serviceErr := checkEmailVerified(ctx, c.userRepo, req.UserId, c.allowUnverifiedRoom); serviceErr != nil {
+		c.logger.Info(common.GetUUID(ctx), "checkEmailVerified", req, serviceErr.InternalError)
+		return nil, nil, serviceErr
+	}
+
+	device, ok, err := c.deviceRepo.GetMainDevice(ctx, req.UserId, req.MainDeviceId)
+	if err != nil {
+		c.logger.Error(common.GetUUID(ctx), "c.deviceRepo.GetMainDevice", req, err)
+		return nil, nil, c.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		c.logger.Info(common.GetUUID(ctx), "c.deviceRepo.GetMainDevice", req, nil)
+		return nil, nil, c.errWarpper.NewMainDeviceNotBindingError()
+	}
+
+	policy, _, err := c.policyRepo.GetPolicy(ctx, device.Id)
+	if err != nil {
+		c.logger.Error(common.GetUUID(ctx), "c.policyRepo.GetPolicy", req, err)
+		return nil, nil, c.errWarpper.NewDBServiceError(err)
+	}
+
+	messageSchema, ok, err := c.schemaRepo.GetSchema(ctx, device.UserId, device.Platform, device.Version)
+	if err != nil {
+		c.logger.Error(common.GetUUID(ctx), "c.schemaRepo.GetSchema", req, err)
+		return nil, nil, c.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		return nil, newRoomPolicy(policy), nil
+	}
+
+	validator, err := newMessageValidator(messageSchema)
+	if err != nil {
+		c.logger.Error(common.GetUUID(ctx), "newMessageValidator", req, err)
+		return nil, nil, c.errWarpper.NewSchemaInvalidError(err)
+	}
+	return validator, newRoomPolicy(policy), nil
+}
+
+// policyOf returns the policy of an open room, the default one once the room is gone.
+func (c *communicationSeriviceImpl) policyOf(userId uint64, mainDeviceId uint64) *roomPolicy {
+	if room, ok := c.rooms.GetRoom(userId, mainDeviceId); ok {
+		return room.policy
+	}
+	return defaultRoomPolicy()
+}
+
+func (c *communicationSeriviceImpl) openMainDevice(userId uint64, mainDeviceId uint64, conn DeviceConnection, validator *messageValidator, policy *roomPolicy, token string) (DeviceSession, string) {
+	slot := c.resumes.newSlot(conn, token)
+	room, exists := c.rooms.GetOrCreateRoom(userId, mainDeviceId, slot, policy)
+	if exists {
+		return nil, "This main device already has a websocket connection"
+	}
+
+	metrics.Connections.WithLabelValues(metrics.RoleMain).Inc()
+	c.dispatcher.Publish(userId, webhook.EventMainDeviceConnect, map[string]any{"main_device_id": mainDeviceId})
+	session := &mainDeviceSession{
+		communication: c,
+		room:          room,
+		slot:          slot,
+		validator:     validator,
+		userId:        userId,
+		mainDeviceId:  mainDeviceId,
+	}
+	c.resumes.add(token, session)
+	return c.attach(session, conn, token), ""
+}
+
+func (c *communicationSeriviceImpl) joinSubDevice(userId uint64, mainDeviceId uint64, subDeviceId uint64, conn DeviceConnection, token string) (DeviceSession, string) {
+	slot := c.resumes.newSlot(conn, token)
+	room, errMessage := c.rooms.JoinRoom(userId, mainDeviceId, subDeviceId, slot)
+	if errMessage != "" {
+		return nil, errMessage
+	}
+
+	metrics.Connections.WithLabelValues(metrics.RoleSub).Inc()
+	c.dispatcher.Publish(userId, webhook.EventSubDeviceConnect, map[string]any{"main_device_id": mainDeviceId, "sub_device_id": subDeviceId})
+	ctx, cancel := context.WithCancel(context.Background())
+	session := &subDeviceSession{
+		communication: c,
+		room:          room,
+		slot:          slot,
+		ctx:           ctx,
+		cancel:        cancel,
+		userId:        userId,
+		mainDeviceId:  mainDeviceId,
+		subDeviceId:   subDeviceId,
+	}
+	c.resumes.add(token, session)
+	return c.attach(session, conn, token), ""
+}
+
+type mainDeviceSession struct {
+	communication *communicationSeriviceImpl
+	room          *webSocketRoom
+	slot          *sessionSlot
+	validator     *messageValidator
+	userId        uint64
+	mainDeviceId  uint64
+	once          sync.Once
+}
+
+func (s *mainDeviceSession) HandleMessage(messageType int, message []byte) {
+	s.room.record(recording.DirectionMainIn, 0, messageType, message)
+	if messageType != websocket.TextMessage {
+		metrics.Dropped(metrics.DropUnsupportedType)
+		return
+	}
+	if int64(len(message)) > s.room.policy.maxMessageByte {
+		metrics.Dropped(metrics.DropMessageTooLarge)
+		s.replyError("", fmt.Sprintf("message should <= %d bytes", s.room.policy.maxMessageByte))
+		return
+	}
+
+	frame, ok := parseDeviceFrame(message)
+	if ok && !s.room.policy.allowFrame(frame.Type) {
+		metrics.Dropped(metrics.DropFrameTypeNotAllowed)
+		s.replyError(frame.Id, fmt.Sprintf("frame type %q is not allowed", frame.Type))
+		return
+	}
+	if ok && frame.Type == dto.DeviceFrameTypeControl {
+		s.handleControl(frame)
+		return
+	}
+	if ok && (frame.Type == dto.DeviceFrameTypeResponse || frame.Type == dto.DeviceFrameTypeError) && s.room.Resolve(frame) {
+		return
+	}
+	if s.validator != nil {
+		if err := s.validator.Validate(message); err != nil && !s.handleViolation(message, err) {
+			return
+		}
+	}
+
+	var topic, id string
+	if ok {
+		topic, id = frame.Topic, frame.Id
+	} else {
+		topic = messageTopic(message)
+	}
+	if topic != "" && !isValidTopic(topic, false) {
+		metrics.Dropped(metrics.DropInvalidTopic)
+		s.replyError(id, fmt.Sprintf("invalid topic: %q", topic))
+		return
+	}
+	s.room.SendMessage(topic, message)
+	s.communication.dispatcher.Publish(s.userId, webhook.EventMainDeviceMessage, map[string]any{
+		"main_device_id": s.mainDeviceId,
+		"topic":          topic,
+		"message":        string(message),
+	})
+}
+
+func (s *mainDeviceSession) replyError(id string, reason string) {
+	reply, _ := json.Marshal(&dto.DeviceFrame{Type: dto.DeviceFrameTypeError, Id: id, Error: reason})
+	s.room.WriteToMain(websocket.TextMessage, reply)
+}
+
+func (s *mainDeviceSession) handleViolation(message []byte, err error) bool {
+	c := s.communication
+	data := map[string]any{"main_device_id": s.mainDeviceId, "schema_id": s.validator.schemaId, "policy": s.validator.policy}
+	if s.validator.policy == model.SchemaPolicyReject {
+		metrics.Dropped(metrics.DropSchemaRejected)
+		c.logger.Info("", "s.validator.Validate", data, err)
+		frame, _ := json.Marshal(&dto.DeviceFrame{
+			Type:  dto.DeviceFrameTypeError,
+			Error: fmt.Sprintf("message rejected: %s", err.Error()),
+		})
+		s.room.WriteToMain(websocket.TextMessage, frame)
+		return false
+	}
+
+	c.logger.Warning("", "s.validator.Validate", data, err)
+	dbErr := c.schemaRepo.CreateViolation(context.Background(), &model.SchemaViolation{
+		SchemaId:     s.validator.schemaId,
+		UserId:       s.userId,
+		MainDeviceId: s.mainDeviceId,
+		Action:       s.validator.policy,
+		Message:      string(message),
+		Reason:       err.Error(),
+	})
+	if dbErr != nil {
+		c.logger.Error("", "c.schemaRepo.CreateViolation", data, dbErr)
+	}
+	if s.validator.policy == model.SchemaPolicyQuarantine {
+		metrics.Dropped(metrics.DropSchemaQuarantined)
+		return false
+	}
+	return true
+}
+
+func (s *mainDeviceSession) getSlot() *sessionSlot {
+	return s.slot
+}
+
+func (s *mainDeviceSession) owner() (uint64, uint64, uint64) {
+	return s.userId, s.mainDeviceId, 0
+}
+
+func (s *mainDeviceSession) roomClosed() bool {
+	return isClosed(s.room.closed)
+}
+
+func (s *mainDeviceSession) role() string {
+	return metrics.RoleMain
+}
+
+func (s *mainDeviceSession) Close() {
+	s.once.Do(func() {
+		s.slot.end()
+		s.communication.resumes.remove(s.slot.getToken())
+		s.communication.rooms.RemoveRoom(s.userId, s.mainDeviceId)
+		metrics.Connections.WithLabelValues(metrics.RoleMain).Dec()
+		s.communication.dispatcher.Publish(s.userId, webhook.EventMainDeviceDisconnect, map[string]any{"main_device_id": s.mainDeviceId})
+	})
+}
+
+type subDeviceSession struct {
+	communication *communicationSeriviceImpl
+	room          *webSocketRoom
+	slot          *sessionSlot
+	ctx           context.Context
+	cancel        context.CancelFunc
+	inflight      atomic.Int64
+	userId        uint64
+	mainDeviceId  uint64
+	subDeviceId   uint64
+	once          sync.Once
+}
+
+func (s *subDeviceSession) HandleMessage(messageType int, message []byte) {
+	s.room.record(recording.DirectionSubIn, s.subDeviceId, messageType, message)
+	if messageType != websocket.TextMessage {
+		return
+	}
+	if int64(len(message)) > s.room.policy.maxMessageByte {
+		metrics.Dropped(metrics.DropMessageTooLarge)
+		s.reply(&dto.DeviceFrame{Type: dto.DeviceFrameTypeError, Error: fmt.Sprintf("message should <= %d bytes", s.room.policy.maxMessageByte)})
+		return
+	}
+
+	frame, ok := parseDeviceFrame(message)
+	if !ok {
+		return
+	}
+	if !s.room.policy.allowFrame(frame.Type) {
+		metrics.Dropped(metrics.DropFrameTypeNotAllowed)
+		s.reply(&dto.DeviceFrame{Type: dto.DeviceFrameTypeError, Id: frame.Id, Error: fmt.Sprintf("frame type %q is not allowed", frame.Type)})
+		return
+	}
+	switch frame.Type {
+	case dto.DeviceFrameTypeSubscribe, dto.DeviceFrameTypeUnsubscribe:
+		s.handleSubscription(frame)
+	case dto.DeviceFrameTypeRequest:
+		s.handleCall(frame, len(message))
+	}
+}
+
+func (s *subDeviceSession) handleCall(frame *dto.DeviceFrame, size int) {
+	rooms := &s.communication.rooms
+	if frame.Id == "" || frame.Method == "" {
+		s.reply(&dto.DeviceFrame{Type: dto.DeviceFrameTypeError, Id: frame.Id, Error: "request should have id and method"})
+		return
+	}
+	if s.inflight.Add(1) > rooms.MAX_INFLIGHT_CALL_NUMBER {
+		s.inflight.Add(-1)
+		metrics.Dropped(metrics.DropInflightLimit)
+		s.reply(&dto.DeviceFrame{Type: dto.DeviceFrameTypeError, Id: frame.Id,
+			Error: fmt.Sprintf("number of in-flight calls should <= %d", rooms.MAX_INFLIGHT_CALL_NUMBER)})
+		return
+	}
+
+	timeout := rooms.DEFAULT_CALL_TIMEOUT
+	if frame.TimeoutMs > 0 {
+		timeout = time.Duration(frame.TimeoutMs) * time.Millisecond
+	}
+	if timeout > rooms.MAX_CALL_TIMEOUT {
+		timeout = rooms.MAX_CALL_TIMEOUT
+	}
+
+	// the main device only sees the server side id, so calls from different sub devices never collide
+	call := &dto.DeviceFrame{
+		Type:   dto.DeviceFrameTypeRequest,
+		Id:     uuid.New().String(),
+		Method: frame.Method,
+		Params: frame.Params,
+	}
+	metrics.Relayed(metrics.DirectionSubToMain, size)
+	go func() {
+		defer s.inflight.Add(-1)
+		reply, err := s.room.Call(s.ctx, call, timeout)
+		switch {
+		case errors.Is(err, errCallTimeout):
+			s.reply(&dto.DeviceFrame{Type: dto.DeviceFrameTypeError, Id: frame.Id, Error: fmt.Sprintf("call timeout after %s", timeout)})
+		case errors.Is(err, context.Canceled):
+		case err != nil:
+			s.reply(&dto.DeviceFrame{Type: dto.DeviceFrameTypeError, Id: frame.Id, Error: "main device offline"})
+		case reply.Error != "":
+			s.reply(&dto.DeviceFrame{Type: dto.DeviceFrameTypeError, Id: frame.Id, Error: reply.Error})
+		default:
+			s.reply(&dto.DeviceFrame{Type: dto.DeviceFrameTypeResponse, Id: frame.Id, Result: reply.Result})
+		}
+	}()
+}
+
+func (s *subDeviceSession) handleSubscription(frame *dto.DeviceFrame) {
+	if !isValidTopic(frame.Topic, true) {
+		s.reply(&dto.DeviceFrame{Type: dto.DeviceFrameTypeError, Id: frame.Id, Error: fmt.Sprintf("invalid topic: %q", frame.Topic)})
+		return
+	}
+
+	var topics []string
+	if frame.Type == dto.DeviceFrameTypeSubscribe {
+		var errMessage string
+		topics, errMessage = s.room.Subscribe(s.subDeviceId, frame.Topic, s.communication.rooms.MAX_SUBSCRIPTION_NUMBER)
+		if errMessage != "" {
+			s.reply(&dto.DeviceFrame{Type: dto.DeviceFrameTypeError, Id: frame.Id, Error: errMessage})
+			return
+		}
+	} else {
+		topics = s.room.Unsubscribe(s.subDeviceId, frame.Topic)
+	}
+
+	result, _ := json.Marshal(&dto.SubscriptionResult{Topics: topics})
+	s.reply(&dto.DeviceFrame{Type: dto.DeviceFrameTypeResponse, Id: frame.Id, Result: result})
+}
+
+func (s *subDeviceSession) reply(frame *dto.DeviceFrame) {
+	message, err := json.Marshal(frame)
+	if err != nil {
+		return
+	}
+	s.room.WriteToSub(s.subDeviceId, websocket.TextMessage, message)
+}
+
+func (s *subDeviceSession) getSlot() *sessionSlot {
+	return s.slot
+}
+
+func (s *subDeviceSession) owner() (uint64, uint64, uint64) {
+	return s.userId, s.mainDeviceId, s.subDeviceId
+}
+
+func (s *subDeviceSession) roomClosed() bool {
+	return isClosed(s.room.closed)
+}
+
+func (s *subDeviceSession) role() string {
+	return metrics.RoleSub
+}
+
+func (s *subDeviceSession) Close() {
+	s.once.Do(func() {
+		s.slot.end()
+		s.communication.resumes.remove(s.slot.getToken())
+		s.cancel()
+		s.communication.rooms.LeaveRoom(s.userId, s.mainDeviceId, s.subDeviceId)
+		metrics.Connections.WithLabelValues(metrics.RoleSub).Dec()
+		s.communication.dispatcher.Publish(s.userId, webhook.EventSubDeviceDisconnect, map[string]any{"main_device_id": s.mainDeviceId, "sub_device_id": s.subDeviceId})
+	})
+}
+
+func (c *communicationSeriviceImpl) SendCommandToMainDevice(ctx context.Context, req *dto.MainDeviceCommandRequest) (*dto.MainDeviceCommandResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "CommunicationService.SendCommandToMainDevice")
+	defer span.End()
+	ok, err := c.deviceRepo.CheckMainDeviceBinding(ctx, req.UserId, req.MainDeviceId)
+	if err != nil {
+		c.logger.Error(common.GetUUID(ctx), "c.deviceRepo.CheckMainDeviceBinding", req, err)
+		return nil, c.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		c.logger.Info(common.GetUUID(ctx), "c.deviceRepo.CheckMainDeviceBinding", req, nil)
+		return nil, c.errWarpper.NewMainDeviceNotBindingError()
+	}
+
+	room, ok := c.rooms.GetRoom(req.UserId, req.MainDeviceId)
+	if !ok {
+		c.logger.Info(common.GetUUID(ctx), "c.rooms.GetRoom", req, nil)
+		return nil, c.errWarpper.NewMainDeviceOfflineError()
+	}
+
+	frame := &dto.DeviceFrame{
+		Type:   dto.DeviceFrameTypeRequest,
+		Id:     uuid.New().String(),
+		Method: req.Method,
+		Params: req.Params,
+	}
+	timeout := time.Duration(req.TimeoutMs) * time.Millisecond
+	reply, err := room.Call(ctx, frame, timeout)
+	if errors.Is(err, errCallTimeout) {
+		c.logger.Info(common.GetUUID(ctx), "room.Call", req, err)
+		return nil, c.errWarpper.NewDeviceCommandTimeoutError(timeout)
+	} else if err != nil {
+		c.logger.Info(common.GetUUID(ctx), "room.Call", req, err)
+		return nil, c.errWarpper.NewMainDeviceOfflineError()
+	} else if reply.Error != "" {
+		c.logger.Info(common.GetUUID(ctx), "room.Call", req, nil)
+		return nil, c.errWarpper.NewDeviceCommandFailedError(reply.Error)
+	}
+
+	c.logger.Info(common.GetUUID(ctx), "SendCommandToMainDevice.end", req, nil)
+	return &dto.MainDeviceCommandResponse{
+		Id:     frame.Id,
+		Result: reply.Result,
+	}, nil
+}
+
+func (c *communicationSeriviceImpl) StartRecording(ctx context.Context, req *dto.StartRecordingRequest) (*dto.StartRecordingResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "CommunicationService.StartRecording")
+	defer span.End()
+	room, ok := c.rooms.GetRoom(req.UserId, req.MainDeviceId)
+	if !ok {
+		c.logger.Info(common.GetUUID(ctx), "c.rooms.GetRoom", req, nil)
+		return nil, c.errWarpper.NewMainDeviceOfflineError()
+	}
+
+	if err := os.MkdirAll(c.recordingDirectory, 0o755); err != nil {
+		c.logger.Error(common.GetUUID(ctx), "os.MkdirAll", req, err)
+		return nil, c.errWarpper.NewRecordingFailedError(err)
+	}
+
+	// the random suffix keeps two recordings started within the same second apart
+	name := fmt.Sprintf("%d_%d_%s_%s.jsonl", req.UserId, req.MainDeviceId, time.Now().Format("20060102150405"), uuid.New().String()[:8])
+	recorder, err := room.StartRecording(filepath.Join(c.recordingDirectory, name), c.recordingLimit)
+	if err != nil {
+		c.logger.Error(common.GetUUID(ctx), "room.StartRecording", req, err)
+		return nil, c.errWarpper.NewRecordingFailedError(err)
+	} else if recorder == nil {
+		c.logger.Info(common.GetUUID(ctx), "room.StartRecording", req, nil)
+		return nil, c.errWarpper.NewRecordingAlreadyStartedError()
+	}
+
+	c.logger.Info(common.GetUUID(ctx), "StartRecording.end", req, nil)
+	return &dto.StartRecordingResponse{Name: name}, nil
+}
+
+func (c *communicationSeriviceImpl) StopRecording(ctx context.Context, req *dto.StopRecordingRequest) (*dto.StopRecordingResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "CommunicationService.StopRecording")
+	defer span.End()
+	room, ok := c.rooms.GetRoom(req.UserId, req.MainDeviceId)
+	if !ok {
+		c.logger.Info(common.GetUUID(ctx), "c.rooms.GetRoom", req, nil)
+		return nil, c.errWarpper.NewRecordingNotStartedError()
+	}
+
+	recorder, err := room.StopRecording()
+	if recorder == nil {
+		c.logger.Info(common.GetUUID(ctx), "room.StopRecording", req, nil)
+		return nil, c.errWarpper.NewRecordingNotStartedError()
+	} else if err != nil {
+		c.logger.Error(common.GetUUID(ctx), "room.StopRecording", req, err)
+		return nil, c.errWarpper.NewRecordingFailedError(err)
+	}
+
+	c.logger.Info(common.GetUUID(ctx), "StopRecording.end", req, nil)
+	return &dto.StopRecordingResponse{Name: filepath.Base(recorder.Name())}, nil
+}
+
+func (c *communicationSeriviceImpl) GetRecordings(ctx context.Context, req *dto.GetRecordingsRequest) (*dto.GetRecordingsResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "CommunicationService.GetRecordings")
+	defer span.End()
+	pattern := fmt.Sprintf("%d_*.jsonl", req.UserId)
+	if req.MainDeviceId != 0 {
+		pattern = fmt.Sprintf("%d_%d_*.jsonl", req.UserId, req.MainDeviceId)
+	}
+	paths, err := filepath.Glob(filepath.Join(c.recordingDirectory, pattern))
+	if err != nil {
+		c.logger.Error(common.GetUUID(ctx), "filepath.Glob", req, err)
+		return nil, c.errWarpper.NewRecordingFailedError(err)
+	}
+
+	res := &dto.GetRecordingsResponse{Recordings: make([]*dto.Recording, 0, len(paths))}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		var userId, mainDeviceId uint64
+		fmt.Sscanf(info.Name(), "%d_%d_", &userId, &mainDeviceId)
+		res.Recordings = append(res.Recordings, &dto.Recording{
+			Name:         info.Name(),
+			MainDeviceId: mainDeviceId,
+			Size:         info.Size(),
+			UpdateTime:   info.ModTime(),
+		})
+	}
+	return res, nil
+}
+
+func (c *communicationSeriviceImpl) GetRecordingFile(ctx context.Context, req *dto.GetRecordingFileRequest) (string, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "CommunicationService.GetRecordingFile")
+	defer span.End()
+	name := filepath.Base(req.Name)
+	prefix := fmt.Sprintf("%d_", req.UserId)
+	if req.MainDeviceId != 0 {
+		prefix = fmt.Sprintf("%d_%d_", req.UserId, req.MainDeviceId)
+	}
+	if name != req.Name || !strings.HasPrefix(name, prefix) {
+		c.logger.Info(common.GetUUID(ctx), "GetRecordingFile", req, nil)
+		return "", c.errWarpper.NewRecordingNotFoundError()
+	}
+
+	path := filepath.Join(c.recordingDirectory, name)
+	if _, err := os.Stat(path); err != nil {
+		c.logger.Info(common.GetUUID(ctx), "os.Stat", req, err)
+		return "", c.errWarpper.NewRecordingNotFoundError()
+	}
+	return path, nil
+}
+
+func (c *communicationSeriviceImpl) DisconnectDevice(ctx context.Context, userId uint64, mainDeviceId uint64, subDeviceId uint64, reason string) {
+	ctx, span := telemetry.Start(ctx, "CommunicationService.DisconnectDevice")
+	defer span.End()
+	if subDeviceId == 0 {
+		c.rooms.RemoveRoom(userId, mainDeviceId)
+		c.logger.Info(common.GetUUID(ctx), "DisconnectDevice.main", reason, nil)
+		return
+	}
+
+	c.resumes.revoke(userId, mainDeviceId, subDeviceId)
+	room, ok := c.rooms.GetRoom(userId, mainDeviceId)
+	if ok && room.KickSub(subDeviceId, reason) {
+		c.logger.Info(common.GetUUID(ctx), "DisconnectDevice.sub", reason, nil)
+	}
+}
+
+// DisconnectLoginSession kicks the websockets opened with a login session, they cannot resume.
+func (c *communicationSeriviceImpl) DisconnectLoginSession(ctx context.Context, sessionId string, reason string) {
+	ctx, span := telemetry.Start(ctx, "CommunicationService.DisconnectLoginSession")
+	defer span.End()
+	connections := c.logins.take(sessionId)
+	for _, connection := range connections {
+		connection.session.Kick(reason)
+	}
+	if len(connections) > 0 {
+		c.logger.Info(common.GetUUID(ctx), "DisconnectLoginSession.end", len(connections), nil)
 	}
 }
 
 var communication CommunicationSerivice
 
 func init() {
+	communication = NewCommunicationSerivice(
+		repository.GetuserRepository(),
+		repository.GetDeviceRepository(),
+		repository.GetSchemaRepository(),
+		repository.GetPolicyRepository(),
+		webhook.GetDispatcher(),
+	)
+}
+
+// NewCommunicationSerivice builds a relay with its own rooms on the given repositories, the server uses GetCommunicationSerivice.
+func NewCommunicationSerivice(userRepo repository.UserRepository, deviceRepo repository.DeviceRepository, schemaRepo repository.SchemaRepository,
+	policyRepo repository.PolicyRepository, dispatcher webhook.Dispatcher) CommunicationSerivice {
 	upgrader := websocket.Upgrader{
 		CheckOrigin: func(r *http.Request) bool {
 			return true
 		},
 	}
-	communication = &communicationSeriviceImpl{
+	return &communicationSeriviceImpl{
 		errWarpper: dtoError.GetServiceErrorWarpper(),
-		deviceRepo: repository.GetDeviceRepository(),
+		dispatcher: dispatcher,
+		userRepo:   userRepo,
+		deviceRepo: deviceRepo,
+		schemaRepo: schemaRepo,
+		policyRepo: policyRepo,
 		socket:     upgrader,
 		rooms: webSocketRoomArray{
-			rooms:                 make(map[string]*webSocketRoom),
-			MAX_ROOM_NUMBER:       100,
-			MAX_SUB_DEVICE_NUMBER: 1,
+			shards:                   newRoomShards(),
+			MAX_ROOM_NUMBER:          100,
+			MAX_SUBSCRIPTION_NUMBER:  32,
+			MAX_INFLIGHT_CALL_NUMBER: 16,
+			DEFAULT_CALL_TIMEOUT:     10 * time.Second,
+			MAX_CALL_TIMEOUT:         60 * time.Second,
+		},
+		resumes: resumeRegistry{
+			sessions:          make(map[string]resumableSession),
+			GRACE_PERIOD:      30 * time.Second,
+			MAX_BUFFER_NUMBER: 256,
+		},
+		logins: loginConnections{
+			connections: make(map[string][]loginConnection),
+		},
+		recordingDirectory: config.GlobalConfig.YamlConfig.Recording.Directory,
+		recordingLimit: recording.Limit{
+			MaxBytes:    config.GlobalConfig.YamlConfig.Recording.MaxBytes,
+			MaxDuration: time.Duration(config.GlobalConfig.YamlConfig.Recording.MaxDuration) * time.Second,
 		},
-		mainDeviceIdleDuration: 2 * time.Hour,
+		allowUnverifiedRoom: config.GlobalConfig.YamlConfig.EmailVerification.AllowUnverifiedRoom,
+		logger:              logger.NewInfoLogger(),
 	}
 }
 
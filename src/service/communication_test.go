@@ -0,0 +1,523 @@
+package service
+
+import (
+	"device-communication/src/dto"
+	logger "device-communication/src/log"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type nopConnection struct {
+	closed atomic.Bool
+}
+
+func (n *nopConnection) WriteMessage(messageType int, data []byte) error {
+	return nil
+}
+
+func (n *nopConnection) Close() error {
+	n.closed.Store(true)
+	return nil
+}
+
+func newTestRoomArray() *webSocketRoomArray {
+	return &webSocketRoomArray{shards: newRoomShards()}
+}
+
+func newTestRoomPolicy() *roomPolicy {
+	return &roomPolicy{maxSubDevice: 1 << 20}
+}
+
+// lockedRoomArray is the registry before sharding: one map behind one lock, held through a whole join.
+type lockedRoomArray struct {
+	rooms map[string]*webSocketRoom
+	mu    sync.RWMutex
+}
+
+func (l *lockedRoomArray) key(userId uint64, mainDeviceId uint64) string {
+	return fmt.Sprintf("%d::%d", userId, mainDeviceId)
+}
+
+func (l *lockedRoomArray) GetOrCreateRoom(userId uint64, mainDeviceId uint64, mainConnection DeviceConnection) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rooms[l.key(userId, mainDeviceId)] = &webSocketRoom{
+		MainConnection: mainConnection,
+		SubConnections: make(map[uint64]DeviceConnection),
+		subscriptions:  make(map[uint64]map[string]bool),
+	}
+}
+
+func (l *lockedRoomArray) GetRoom(userId uint64, mainDeviceId uint64) (*webSocketRoom, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	room, ok := l.rooms[l.key(userId, mainDeviceId)]
+	return room, ok
+}
+
+func (l *lockedRoomArray) JoinRoom(userId uint64, mainDeviceId uint64, subDeviceId uint64, subConnection DeviceConnection) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	room, ok := l.rooms[l.key(userId, mainDeviceId)]
+	if !ok {
+		return
+	}
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	room.SubConnections[subDeviceId] = subConnection
+}
+
+func (l *lockedRoomArray) LeaveRoom(userId uint64, mainDeviceId uint64, subDeviceId uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if room, ok := l.rooms[l.key(userId, mainDeviceId)]; ok {
+		room.mu.Lock()
+		defer room.mu.Unlock()
+		delete(room.SubConnections, subDeviceId)
+		delete(room.subscriptions, subDeviceId)
+	}
+}
+
+const benchmarkRoomNumber = 1024
+
+func BenchmarkJoinLeave(b *testing.B) {
+	b.Run("locked", func(b *testing.B) {
+		rooms := &lockedRoomArray{rooms: make(map[string]*webSocketRoom)}
+		for i := uint64(0); i < benchmarkRoomNumber; i++ {
+			rooms.GetOrCreateRoom(i, i+1, &nopConnection{})
+		}
+		var nextSub atomic.Uint64
+		b.RunParallel(func(pb *testing.PB) {
+			subDeviceId := nextSub.Add(1)
+			random := rand.New(rand.NewSource(int64(subDeviceId)))
+			conn := &nopConnection{}
+			for pb.Next() {
+				i := uint64(random.Intn(benchmarkRoomNumber))
+				rooms.JoinRoom(i, i+1, subDeviceId, conn)
+				rooms.LeaveRoom(i, i+1, subDeviceId)
+			}
+		})
+	})
+
+	b.Run("sharded", func(b *testing.B) {
+		rooms := newTestRoomArray()
+		for i := uint64(0); i < benchmarkRoomNumber; i++ {
+			rooms.GetOrCreateRoom(i, i+1, &nopConnection{}, newTestRoomPolicy())
+		}
+		var nextSub atomic.Uint64
+		b.RunParallel(func(pb *testing.PB) {
+			subDeviceId := nextSub.Add(1)
+			random := rand.New(rand.NewSource(int64(subDeviceId)))
+			conn := &nopConnection{}
+			for pb.Next() {
+				i := uint64(random.Intn(benchmarkRoomNumber))
+				rooms.JoinRoom(i, i+1, subDeviceId, conn)
+				rooms.LeaveRoom(i, i+1, subDeviceId)
+			}
+		})
+	})
+}
+
+func BenchmarkGetRoom(b *testing.B) {
+	b.Run("locked", func(b *testing.B) {
+		rooms := &lockedRoomArray{rooms: make(map[string]*webSocketRoom)}
+		for i := uint64(0); i < benchmarkRoomNumber; i++ {
+			rooms.GetOrCreateRoom(i, i+1, &nopConnection{})
+		}
+		var seed atomic.Int64
+		b.RunParallel(func(pb *testing.PB) {
+			random := rand.New(rand.NewSource(seed.Add(1)))
+			for pb.Next() {
+				i := uint64(random.Intn(benchmarkRoomNumber))
+				rooms.GetRoom(i, i+1)
+			}
+		})
+	})
+
+	b.Run("sharded", func(b *testing.B) {
+		rooms := newTestRoomArray()
+		for i := uint64(0); i < benchmarkRoomNumber; i++ {
+			rooms.GetOrCreateRoom(i, i+1, &nopConnection{}, newTestRoomPolicy())
+		}
+		var seed atomic.Int64
+		b.RunParallel(func(pb *testing.PB) {
+			random := rand.New(rand.NewSource(seed.Add(1)))
+			for pb.Next() {
+				i := uint64(random.Intn(benchmarkRoomNumber))
+				rooms.GetRoom(i, i+1)
+			}
+		})
+	})
+}
+
+func TestRoomShardSpread(t *testing.T) {
+	rooms := newTestRoomArray()
+	counts := make(map[*roomShard]int)
+	// one user with many main devices, and many users with their first main device
+	for i := uint64(1); i <= 64*100; i++ {
+		counts[rooms.getShard(rooms.GetRoomKey(1, i))]++
+		counts[rooms.getShard(rooms.GetRoomKey(i, 1000+i))]++
+	}
+	if len(counts) != roomShardNumber {
+		t.Fatalf("keys landed on %d shards, want %d", len(counts), roomShardNumber)
+	}
+	for _, count := range counts {
+		if count < 100 || count > 300 {
+			t.Fatalf("a shard holds %d of %d keys, want about 200", count, 2*64*100)
+		}
+	}
+}
+
+// JoinRoom looks the room up before taking its lock, RemoveRoom may close the room in between.
+func TestJoinRoomClosedWhileWaiting(t *testing.T) {
+	rooms := newTestRoomArray()
+	room, _ := rooms.GetOrCreateRoom(1, 2, &nopConnection{}, newTestRoomPolicy())
+
+	room.mu.Lock()
+	joined := make(chan string)
+	go func() {
+		_, errMessage := rooms.JoinRoom(1, 2, 3, &nopConnection{})
+		joined <- errMessage
+	}()
+	// let the join find the room and wait on its lock
+	time.Sleep(20 * time.Millisecond)
+
+	removed := make(chan struct{})
+	go func() {
+		rooms.RemoveRoom(1, 2)
+		close(removed)
+	}()
+	for !isClosed(room.closed) {
+		time.Sleep(time.Millisecond)
+	}
+	room.mu.Unlock()
+
+	if errMessage := <-joined; errMessage == "" {
+		t.Fatal("JoinRoom succeeded on a room that was closed while it waited")
+	}
+	<-removed
+	if len(room.SubConnections) != 0 {
+		t.Fatalf("removed room holds %d sub devices", len(room.SubConnections))
+	}
+}
+
+// a join racing with RemoveRoom must either fail or be closed by it, never stay in a removed room.
+func TestRoomArrayConcurrentJoinLeaveRemove(t *testing.T) {
+	const (
+		roomNumber  = 16
+		subNumber   = 8
+		rounds      = 200
+		userId      = uint64(7)
+		firstMainId = uint64(100)
+	)
+	rooms := newTestRoomArray()
+	var created sync.Map
+
+	var wg sync.WaitGroup
+	for mainDeviceId := firstMainId; mainDeviceId < firstMainId+roomNumber; mainDeviceId++ {
+		wg.Add(1)
+		go func(mainDeviceId uint64) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				room, _ := rooms.GetOrCreateRoom(userId, mainDeviceId, &nopConnection{}, newTestRoomPolicy())
+				created.Store(room, true)
+				rooms.RemoveRoom(userId, mainDeviceId)
+			}
+		}(mainDeviceId)
+
+		for subDeviceId := uint64(1); subDeviceId <= subNumber; subDeviceId++ {
+			wg.Add(1)
+			go func(mainDeviceId uint64, subDeviceId uint64) {
+				defer wg.Done()
+				for i := 0; i < rounds; i++ {
+					conn := &nopConnection{}
+					room, errMessage := rooms.JoinRoom(userId, mainDeviceId, subDeviceId, conn)
+					if errMessage != "" {
+						continue
+					}
+					room.SendMessage("", []byte("ping"))
+					if i%2 == 0 {
+						rooms.LeaveRoom(userId, mainDeviceId, subDeviceId)
+					}
+				}
+			}(mainDeviceId, subDeviceId)
+		}
+	}
+	wg.Wait()
+
+	created.Range(func(key, value any) bool {
+		room := key.(*webSocketRoom)
+		room.mu.Lock()
+		defer room.mu.Unlock()
+		if !isClosed(room.closed) {
+			t.Errorf("room %p was never closed", room)
+		}
+		if len(room.SubConnections) != 0 {
+			t.Errorf("removed room %p still holds %d sub devices", room, len(room.SubConnections))
+		}
+		return true
+	})
+	for mainDeviceId := firstMainId; mainDeviceId < firstMainId+roomNumber; mainDeviceId++ {
+		if _, ok := rooms.GetRoom(userId, mainDeviceId); ok {
+			t.Errorf("room of main device %d is still registered", mainDeviceId)
+		}
+	}
+}
+
+// plainConnection hides WritePreparedMessage, writeBroadcast then frames the message for every connection
+// and the benchmark measures WriteMessage alone.
+type plainConnection struct {
+	conn *websocket.Conn
+}
+
+func (p *plainConnection) WriteMessage(messageType int, data []byte) error {
+	return p.conn.WriteMessage(messageType, data)
+}
+
+func (p *plainConnection) Close() error {
+	return p.conn.Close()
+}
+
+// newSubConnections opens n websockets on a local server, the client ends read into received until closed.
+func newSubConnections(tb testing.TB, n int, compression bool, received func([]byte)) ([]*websocket.Conn, func()) {
+	upgrader := websocket.Upgrader{EnableCompression: compression}
+	accepted := make(chan *websocket.Conn, n)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn.EnableWriteCompression(compression)
+		accepted <- conn
+	}))
+
+	dialer := websocket.Dialer{EnableCompression: compression}
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	clients := make([]*websocket.Conn, 0, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		client, _, err := dialer.Dial(url, nil)
+		if err != nil {
+			tb.Fatalf("dial: %v", err)
+		}
+		clients = append(clients, client)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				_, message, err := client.ReadMessage()
+				if err != nil {
+					return
+				}
+				if received != nil {
+					received(message)
+				}
+			}
+		}()
+	}
+
+	conns := make([]*websocket.Conn, 0, n)
+	for i := 0; i < n; i++ {
+		conns = append(conns, <-accepted)
+	}
+	return conns, func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+		for _, client := range clients {
+			client.Close()
+		}
+		wg.Wait()
+		server.Close()
+	}
+}
+
+func newTestRoom(conns []*websocket.Conn, prepared bool) *webSocketRoom {
+	room := &webSocketRoom{
+		MainConnection: &nopConnection{},
+		SubConnections: make(map[uint64]DeviceConnection),
+		subscriptions:  make(map[uint64]map[string]bool),
+		closed:         make(chan struct{}),
+	}
+	for i, conn := range conns {
+		if prepared {
+			room.SubConnections[uint64(i+1)] = conn
+		} else {
+			room.SubConnections[uint64(i+1)] = &plainConnection{conn: conn}
+		}
+	}
+	return room
+}
+
+var benchmarkMessage = []byte(`{"type":"message","topic":"sensors/temperature","data":` +
+	`{"celsius":21.5,"humidity":40,"readings":[21.4,21.5,21.5,21.6,21.5,21.4,21.5,21.5],` +
+	`"device":"thermostat-living-room","firmware":"1.4.2","battery":87}}`)
+
+func BenchmarkSendMessage(b *testing.B) {
+	for _, subs := range []int{1, 10, 1000} {
+		for _, compression := range []bool{false, true} {
+			conns, closeAll := newSubConnections(b, subs, compression, nil)
+			for _, prepared := range []bool{false, true} {
+				writer := "WriteMessage"
+				if prepared {
+					writer = "WritePreparedMessage"
+				}
+				name := fmt.Sprintf("subs=%d/compression=%v/%s", subs, compression, writer)
+				b.Run(name, func(b *testing.B) {
+					room := newTestRoom(conns, prepared)
+					b.ReportAllocs()
+					b.ResetTimer()
+					for i := 0; i < b.N; i++ {
+						room.SendMessage("", benchmarkMessage)
+					}
+				})
+			}
+			closeAll()
+		}
+	}
+}
+
+func TestSendMessageDeliversToEverySub(t *testing.T) {
+	for _, compression := range []bool{false, true} {
+		for _, prepared := range []bool{false, true} {
+			var count atomic.Int32
+			done := make(chan struct{}, 8)
+			conns, closeAll := newSubConnections(t, 4, compression, func(message []byte) {
+				if string(message) != string(benchmarkMessage) {
+					t.Errorf("received %q", message)
+				}
+				count.Add(1)
+				done <- struct{}{}
+			})
+			room := newTestRoom(conns, prepared)
+			room.SendMessage("", benchmarkMessage)
+			for i := 0; i < len(conns); i++ {
+				select {
+				case <-done:
+				case <-time.After(5 * time.Second):
+					t.Fatalf("compression=%v prepared=%v: %d of %d subs received", compression, prepared, count.Load(), len(conns))
+				}
+			}
+			closeAll()
+		}
+	}
+}
+
+// messageConnection keeps the text frames written to it.
+type messageConnection struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (m *messageConnection) WriteMessage(messageType int, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, string(data))
+	return nil
+}
+
+func (m *messageConnection) Close() error {
+	return nil
+}
+
+func (m *messageConnection) received() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.messages...)
+}
+
+type nopDispatcher struct{}
+
+func (nopDispatcher) Publish(userId uint64, event string, data any) {}
+func (nopDispatcher) Invalidate(userId uint64)                      {}
+
+// newTestMainSession returns the session of a main device with one sub device per connection in subs.
+func newTestMainSession(policy *roomPolicy, subs ...*messageConnection) (*mainDeviceSession, *messageConnection) {
+	main := &messageConnection{}
+	room := &webSocketRoom{
+		MainConnection: main,
+		SubConnections: make(map[uint64]DeviceConnection),
+		subscriptions:  make(map[uint64]map[string]bool),
+		pending:        make(map[string]chan *dto.DeviceFrame),
+		policy:         policy,
+		closed:         make(chan struct{}),
+	}
+	for i, sub := range subs {
+		room.SubConnections[uint64(i+1)] = sub
+	}
+	communication := &communicationSeriviceImpl{dispatcher: nopDispatcher{}, logger: logger.NewInfoLogger()}
+	return &mainDeviceSession{communication: communication, room: room, userId: 1, mainDeviceId: 2}, main
+}
+
+func TestMainDeviceRelaysCustomFrameType(t *testing.T) {
+	defaultPolicy := &roomPolicy{maxSubDevice: 1 << 20, allowedFrameTypes: frameTypeSet(allDeviceFrameTypes), maxMessageByte: 1 << 16}
+	sub := &messageConnection{}
+	session, main := newTestMainSession(defaultPolicy, sub)
+	session.HandleMessage(websocket.TextMessage, benchmarkMessage)
+	if got := sub.received(); len(got) != 1 || got[0] != string(benchmarkMessage) {
+		t.Fatalf("sub received %q, want the custom typed frame", got)
+	}
+	if got := main.received(); len(got) != 0 {
+		t.Fatalf("main received %q, want no error frame", got)
+	}
+
+	// an empty allow-list still polices the reserved types only
+	sub = &messageConnection{}
+	session, main = newTestMainSession(&roomPolicy{maxSubDevice: 1 << 20, allowedFrameTypes: map[string]bool{}, maxMessageByte: 1 << 16}, sub)
+	session.HandleMessage(websocket.TextMessage, benchmarkMessage)
+	session.HandleMessage(websocket.TextMessage, []byte(`{"type":"request","id":"1","method":"reboot"}`))
+	if got := sub.received(); len(got) != 1 || got[0] != string(benchmarkMessage) {
+		t.Fatalf("sub received %q, want only the custom typed frame", got)
+	}
+	if got := main.received(); len(got) != 1 || !strings.Contains(got[0], `frame type \"request\" is not allowed`) {
+		t.Fatalf("main received %q, want the request rejected", got)
+	}
+}
+
+func TestMainDeviceUntypedTopicFrame(t *testing.T) {
+	alerts, sensors, everything := &messageConnection{}, &messageConnection{}, &messageConnection{}
+	session, _ := newTestMainSession(&roomPolicy{maxSubDevice: 1 << 20, maxMessageByte: 1 << 16}, alerts, sensors, everything)
+	session.room.Subscribe(1, "alerts/#", 8)
+	session.room.Subscribe(2, "sensors/#", 8)
+
+	message := `{"topic":"alerts/fire","data":{"room":"kitchen"}}`
+	session.HandleMessage(websocket.TextMessage, []byte(message))
+	if got := alerts.received(); len(got) != 1 || got[0] != message {
+		t.Fatalf("alerts subscriber received %q, want the message", got)
+	}
+	if got := sensors.received(); len(got) != 0 {
+		t.Fatalf("sensors subscriber received %q, want nothing", got)
+	}
+	if got := everything.received(); len(got) != 1 {
+		t.Fatalf("sub without subscriptions received %q, want the message", got)
+	}
+}
+
+func TestBroadcastPreparedOnlyForWebsocket(t *testing.T) {
+	b := newBroadcast(websocket.TextMessage, benchmarkMessage)
+	sub := &messageConnection{}
+	if err := writeBroadcast(sub, b); err != nil {
+		t.Fatalf("writeBroadcast() = %v", err)
+	}
+	if b.prepared != nil {
+		t.Fatal("writeBroadcast() prepared a frame for a connection without WritePreparedMessage")
+	}
+
+	conns, closeAll := newSubConnections(t, 1, false, nil)
+	defer closeAll()
+	if err := writeBroadcast(conns[0], b); err != nil {
+		t.Fatalf("writeBroadcast() = %v", err)
+	}
+	if b.prepared == nil {
+		t.Fatal("writeBroadcast() did not prepare the frame for a websocket")
+	}
+}
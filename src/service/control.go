@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"device-communication/src/dto"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+)
+
+// handleControl answers a control frame of the main device in the same envelope, with the same id.
+func (s *mainDeviceSession) handleControl(frame *dto.DeviceFrame) {
+	var result any
+	var errMessage string
+	switch frame.Method {
+	case dto.ControlMethodListSubs:
+		result = &dto.ListSubsResult{Subs: s.room.subDevices()}
+	case dto.ControlMethodKickSub:
+		result, errMessage = s.kickSub(frame.Params)
+	case dto.ControlMethodGetPolicy:
+		result = s.room.policy.toDto(s.mainDeviceId)
+	case dto.ControlMethodCloseRoom:
+		s.replyControl(frame.Id, &dto.ControlResult{Ok: true})
+		s.communication.rooms.RemoveRoom(s.userId, s.mainDeviceId)
+		return
+	default:
+		errMessage = fmt.Sprintf("unknown control method: %q", frame.Method)
+	}
+
+	if errMessage != "" {
+		s.replyError(frame.Id, errMessage)
+		return
+	}
+	s.replyControl(frame.Id, result)
+}
+
+func (s *mainDeviceSession) kickSub(params json.RawMessage) (any, string) {
+	var kick dto.KickSubParams
+	if err := json.Unmarshal(params, &kick); err != nil || kick.SubDeviceId == 0 {
+		return nil, "params should have sub_device_id"
+	}
+	if !s.room.hasSub(kick.SubDeviceId) {
+		return nil, fmt.Sprintf("sub device %d is not connected", kick.SubDeviceId)
+	}
+
+	reason := kick.Reason
+	if reason == "" {
+		reason = "kicked by main device"
+	}
+	s.communication.DisconnectDevice(context.Background(), s.userId, s.mainDeviceId, kick.SubDeviceId, reason)
+	return &dto.ControlResult{Ok: true}, ""
+}
+
+func (s *mainDeviceSession) replyControl(id string, result any) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		s.replyError(id, err.Error())
+		return
+	}
+	reply, _ := json.Marshal(&dto.DeviceFrame{Type: dto.DeviceFrameTypeResponse, Id: id, Result: data})
+	s.room.WriteToMain(websocket.TextMessage, reply)
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"device-communication/src/common"
+	"device-communication/src/dto"
+	"device-communication/src/dtoError"
+	logger "device-communication/src/log"
+	"device-communication/src/repository"
+	"device-communication/src/telemetry"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+const DeviceTokenPrefix = "dc_"
+
+type DeviceCredentialService interface {
+	RotateCredential(ctx context.Context, req *dto.RotateDeviceCredentialRequest) (*dto.RotateDeviceCredentialResponse, *dtoError.ServiceError)
+	RevokeCredential(ctx context.Context, req *dto.RevokeDeviceCredentialRequest) (*dto.RevokeDeviceCredentialResponse, *dtoError.ServiceError)
+	GetCredentials(ctx context.Context, req *dto.GetDeviceCredentialsRequest) (*dto.GetDeviceCredentialsResponse, *dtoError.ServiceError)
+	AuthenticateDevice(ctx context.Context, token string) (*dto.DeviceIdentity, *dtoError.ServiceError)
+}
+
+type deviceCredentialServiceImpl struct {
+	credentialRepo repository.CredentialRepository
+	deviceRepo     repository.DeviceRepository
+	errWarpper     dtoError.ServiceErrorWarpper
+	logger         logger.Logger
+}
+
+var deviceCredential DeviceCredentialService
+
+func init() {
+	deviceCredential = NewDeviceCredentialService(repository.GetCredentialRepository(), repository.GetDeviceRepository())
+}
+
+// NewDeviceCredentialService builds the service on the given repositories, the server uses GetDeviceCredentialService.
+func NewDeviceCredentialService(credentialRepo repository.CredentialRepository, deviceRepo repository.DeviceRepository) DeviceCredentialService {
+	return &deviceCredentialServiceImpl{
+		credentialRepo: credentialRepo,
+		deviceRepo:     deviceRepo,
+		errWarpper:     dtoError.GetServiceErrorWarpper(),
+		logger:         logger.NewInfoLogger(),
+	}
+}
+
+func GetDeviceCredentialService() DeviceCredentialService {
+	return deviceCredential
+}
+
+func IsDeviceToken(token string) bool {
+	return strings.HasPrefix(token, DeviceTokenPrefix)
+}
+
+func hashDeviceToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newDeviceToken returns the plain token, which is shown only once, its hash and a short prefix to tell tokens apart.
+func newDeviceToken() (string, string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+	token := DeviceTokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return token, hashDeviceToken(token), token[:len(DeviceTokenPrefix)+6], nil
+}
+
+// issueCredential must run inside the caller's transaction so a failed bind never leaves a token behind.
+func issueCredential(txContext context.Context, credentialRepo repository.CredentialRepository, userId uint64, mainDeviceId uint64, subDeviceId uint64) (uint64, string, error) {
+	token, hash, prefix, err := newDeviceToken()
+	if err != nil {
+		return 0, "", err
+	}
+	cred, err := credentialRepo.CreateCredential(txContext, userId, mainDeviceId, subDeviceId, hash, prefix)
+	if err != nil {
+		return 0, "", err
+	}
+	return cred.Id, token, nil
+}
+
+func (d *deviceCredentialServiceImpl) checkBinding(ctx context.Context, userId uint64, mainDeviceId uint64, subDeviceId uint64) *dtoError.ServiceError {
+	if subDeviceId == 0 {
+		ok, err := d.deviceRepo.CheckMainDeviceBinding(ctx, userId, mainDeviceId)
+		if err != nil {
+			return d.errWarpper.NewDBServiceError(err)
+		} else if !ok {
+			return d.errWarpper.NewMainDeviceNotBindingError()
+		}
+		return nil
+	}
+
+	ok, err := d.deviceRepo.CheckSubDeviceBinding(ctx, userId, mainDeviceId, subDeviceId)
+	if err != nil {
+		return d.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		return d.errWarpper.NewSubDeviceNotBindingError()
+	}
+	return nil
+}
+
+func (d *deviceCredentialServiceImpl) RotateCredential(ctx context.Context, req *dto.RotateDeviceCredentialRequest) (*dto.RotateDeviceCredentialResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "DeviceCredentialService.RotateCredential")
+	defer span.End()
+	if serviceErr := d.checkBinding(ctx, req.UserId, req.MainDeviceId, req.SubDeviceId); serviceErr != nil {
+		d.logger.Info(common.GetUUID(ctx), "d.checkBinding", req, serviceErr.InternalError)
+		return nil, serviceErr
+	}
+
+	txContext, tx := repository.SetTxContext(ctx)
+	_, err := d.credentialRepo.RevokeCredentials(txContext, req.UserId, req.MainDeviceId, req.SubDeviceId, time.Now())
+	if err != nil {
+		d.logger.Error(common.GetUUID(ctx), "d.credentialRepo.RevokeCredentials", req, err)
+		tx.Rollback()
+		return nil, d.errWarpper.NewDBServiceError(err)
+	}
+
+	id, token, err := issueCredential(txContext, d.credentialRepo, req.UserId, req.MainDeviceId, req.SubDeviceId)
+	if err != nil {
+		d.logger.Error(common.GetUUID(ctx), "issueCredential", req, err)
+		tx.Rollback()
+		return nil, d.errWarpper.NewDBServiceError(err)
+	}
+
+	err = tx.Commit().Error
+	if err != nil {
+		d.logger.Error(common.GetUUID(ctx), "tx.Commit", req, err)
+		return nil, d.errWarpper.NewDBCommitServiceError(err)
+	}
+
+	GetCommunicationSerivice().DisconnectDevice(ctx, req.UserId, req.MainDeviceId, req.SubDeviceId, "device credential rotated")
+	d.logger.Info(common.GetUUID(ctx), "RotateCredential.end", req, nil)
+	return &dto.RotateDeviceCredentialResponse{
+		CredentialId: id,
+		Token:        token,
+	}, nil
+}
+
+func (d *deviceCredentialServiceImpl) RevokeCredential(ctx context.Context, req *dto.RevokeDeviceCredentialRequest) (*dto.RevokeDeviceCredentialResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "DeviceCredentialService.RevokeCredential")
+	defer span.End()
+	count, err := d.credentialRepo.RevokeCredentials(ctx, req.UserId, req.MainDeviceId, req.SubDeviceId, time.Now())
+	if err != nil {
+		d.logger.Error(common.GetUUID(ctx), "d.credentialRepo.RevokeCredentials", req, err)
+		return nil, d.errWarpper.NewDBServiceError(err)
+	} else if count == 0 {
+		d.logger.Info(common.GetUUID(ctx), "d.credentialRepo.RevokeCredentials", req, nil)
+		return nil, d.errWarpper.NewDeviceCredentialNotFoundError()
+	}
+
+	GetCommunicationSerivice().DisconnectDevice(ctx, req.UserId, req.MainDeviceId, req.SubDeviceId, "device credential revoked")
+	d.logger.Info(common.GetUUID(ctx), "RevokeCredential.end", req, nil)
+	return &dto.RevokeDeviceCredentialResponse{Ok: true}, nil
+}
+
+func (d *deviceCredentialServiceImpl) GetCredentials(ctx context.Context, req *dto.GetDeviceCredentialsRequest) (*dto.GetDeviceCredentialsResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "DeviceCredentialService.GetCredentials")
+	defer span.End()
+	creds, err := d.credentialRepo.GetCredentialsByUserId(ctx, req.UserId)
+	if err != nil {
+		d.logger.Error(common.GetUUID(ctx), "d.credentialRepo.GetCredentialsByUserId", req, err)
+		return nil, d.errWarpper.NewDBServiceError(err)
+	}
+
+	response := &dto.GetDeviceCredentialsResponse{
+		Credentials: make([]*dto.DeviceCredential, 0, len(creds)),
+	}
+	for _, cred := range creds {
+		response.Credentials = append(response.Credentials, &dto.DeviceCredential{
+			Id:           cred.Id,
+			MainDeviceId: cred.MainDeviceId,
+			SubDeviceId:  cred.SubDeviceId,
+			TokenPrefix:  cred.TokenPrefix,
+			LastUsedTime: cred.LastUsedAt,
+			CreateTime:   cred.CreatedAt,
+		})
+	}
+	return response, nil
+}
+
+func (d *deviceCredentialServiceImpl) AuthenticateDevice(ctx context.Context, token string) (*dto.DeviceIdentity, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "DeviceCredentialService.AuthenticateDevice")
+	defer span.End()
+	if !IsDeviceToken(token) {
+		return nil, d.errWarpper.NewDeviceCredentialInvalidError()
+	}
+
+	cred, ok, err := d.credentialRepo.UseCredential(ctx, hashDeviceToken(token), time.Now())
+	if err != nil {
+		d.logger.Error(common.GetUUID(ctx), "d.credentialRepo.UseCredential", nil, err)
+		return nil, d.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		d.logger.Info(common.GetUUID(ctx), "d.credentialRepo.UseCredential", nil, nil)
+		return nil, d.errWarpper.NewDeviceCredentialInvalidError()
+	}
+
+	return &dto.DeviceIdentity{
+		UserId:       cred.UserId,
+		MainDeviceId: cred.MainDeviceId,
+		SubDeviceId:  cred.SubDeviceId,
+	}, nil
+}
@@ -2,10 +2,19 @@ package service
 
 import (
 	"context"
+	"device-communication/src/common"
+	"device-communication/src/config"
 	"device-communication/src/dto"
 	"device-communication/src/dtoError"
 	logger "device-communication/src/log"
+	"device-communication/src/metrics"
+	"device-communication/src/model"
 	"device-communication/src/repository"
+	"device-communication/src/telemetry"
+	"device-communication/src/webhook"
+	"fmt"
+	"strings"
+	"time"
 )
 
 type DeviceService interface {
@@ -14,14 +23,22 @@ type DeviceService interface {
 	BindSubDevice(ctx context.Context, req *dto.BindSubDeviceRequest) (*dto.BindSubDeviceResponse, *dtoError.ServiceError)
 	UnBindSubDevice(ctx context.Context, req *dto.UnbindSubDeviceRequest) (*dto.UnbindSubDeviceResponse, *dtoError.ServiceError)
 	GetDevicesByUserId(ctx context.Context, req *dto.GetDevicesByUserIdRequest) (*dto.GetDevicesByUserIdResponse, *dtoError.ServiceError)
+	GetRoomPolicy(ctx context.Context, req *dto.GetRoomPolicyRequest) (*dto.GetRoomPolicyResponse, *dtoError.ServiceError)
+	UpdateRoomPolicy(ctx context.Context, req *dto.UpdateRoomPolicyRequest) (*dto.UpdateRoomPolicyResponse, *dtoError.ServiceError)
 }
 
 type deviceServiceImpl struct {
 	userRepo              repository.UserRepository
 	deviceRepo            repository.DeviceRepository
+	credentialRepo        repository.CredentialRepository
+	policyRepo            repository.PolicyRepository
 	errWarpper            dtoError.ServiceErrorWarpper
+	dispatcher            webhook.Dispatcher
 	MAX_MAIN_DEVICE_COUNT int64
 	MAX_SUB_DEVICE_COUNT  int64
+	MAX_IDLE_SECOND       int64
+	MAX_MESSAGE_BYTE      int64
+	ALLOW_UNVERIFIED_BIND bool
 	logger                logger.Logger
 }
 
@@ -31,128 +48,230 @@ func init() {
 	device = &deviceServiceImpl{
 		userRepo:              repository.GetuserRepository(),
 		deviceRepo:            repository.GetDeviceRepository(),
+		credentialRepo:        repository.GetCredentialRepository(),
+		policyRepo:            repository.GetPolicyRepository(),
 		errWarpper:            dtoError.GetServiceErrorWarpper(),
-		MAX_MAIN_DEVICE_COUNT: 1,
-		MAX_SUB_DEVICE_COUNT:  1,
+		dispatcher:            webhook.GetDispatcher(),
+		MAX_MAIN_DEVICE_COUNT: config.GlobalConfig.YamlConfig.Limit.MaxMainDevice,
+		MAX_SUB_DEVICE_COUNT:  config.GlobalConfig.YamlConfig.Limit.MaxSubDevice,
+		MAX_IDLE_SECOND:       config.GlobalConfig.YamlConfig.Limit.MaxPolicyIdle,
+		MAX_MESSAGE_BYTE:      config.GlobalConfig.YamlConfig.Limit.MaxPolicyMessageByte,
+		ALLOW_UNVERIFIED_BIND: config.GlobalConfig.YamlConfig.EmailVerification.AllowUnverifiedBind,
 		logger:                logger.NewInfoLogger(),
 	}
 }
 
-func (d *deviceServiceImpl) BindMainDevice(ctx context.Context, req *dto.BindMainDeviceRequest) (*dto.BindMainDeviceResponse, *dtoError.ServiceError) {
+func (d *deviceServiceImpl) BindMainDevice(ctx context.Context, req *dto.BindMainDeviceRequest) (res *dto.BindMainDeviceResponse, serviceErr *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "DeviceService.BindMainDevice")
+	defer span.End()
+	defer func() {
+		metrics.DeviceOperation("bind", metrics.RoleMain, serviceErr == nil)
+	}()
+	if serviceErr := checkEmailVerified(ctx, d.userRepo, req.UserId, d.ALLOW_UNVERIFIED_BIND); serviceErr != nil {
+		d.logger.Info(common.GetUUID(ctx), "checkEmailVerified", req, serviceErr.InternalError)
+		return nil, serviceErr
+	}
+
 	txContext, tx := repository.SetTxContext(ctx)
 	ok, err := d.deviceRepo.CheckRepeatedDevice(txContext, req.Platform, req.Version, req.DeviceId)
 	if err != nil {
-		d.logger.Error("", "d.deviceRepo.CheckRepeatedDevice", req, err)
+		d.logger.Error(common.GetUUID(ctx), "d.deviceRepo.CheckRepeatedDevice", req, err)
 		tx.Rollback()
 		return nil, d.errWarpper.NewDBServiceError(err)
 	} else if !ok {
-		d.logger.Info("", "d.deviceRepo.CheckRepeatedDevice", req, nil)
+		d.logger.Info(common.GetUUID(ctx), "d.deviceRepo.CheckRepeatedDevice", req, nil)
 		tx.Rollback()
 		return nil, d.errWarpper.NewRepeatDeviceError()
 	}
 
 	count, err := d.deviceRepo.GetMainDeviceCount(txContext, req.UserId)
 	if err != nil {
-		d.logger.Error("", "d.deviceRepo.GetMainDeviceCount", req, err)
+		d.logger.Error(common.GetUUID(ctx), "d.deviceRepo.GetMainDeviceCount", req, err)
 		tx.Rollback()
 		return nil, d.errWarpper.NewDBServiceError(err)
 	} else if count > d.MAX_MAIN_DEVICE_COUNT {
-		d.logger.Info("", "d.deviceRepo.GetMainDeviceCount", req, nil)
+		d.logger.Info(common.GetUUID(ctx), "d.deviceRepo.GetMainDeviceCount", req, nil)
 		tx.Rollback()
 		return nil, d.errWarpper.NewMainDeviceTooManyError(d.MAX_MAIN_DEVICE_COUNT)
 	}
 
 	device, err := d.deviceRepo.BindMainDevice(txContext, req.UserId, req.Platform, req.Version, req.DeviceId)
+	if err != nil {
+		d.logger.Error(common.GetUUID(ctx), "d.deviceRepo.BindMainDevice", req, err)
+		tx.Rollback()
+		return nil, d.errWarpper.NewDBServiceError(err)
+	}
+
+	_, token, err := issueCredential(txContext, d.credentialRepo, req.UserId, device.Id, 0)
+	if err != nil {
+		d.logger.Error(common.GetUUID(ctx), "issueCredential", req, err)
+		tx.Rollback()
+		return nil, d.errWarpper.NewDBServiceError(err)
+	}
+
 	err = tx.Commit().Error
 	if err != nil {
-		d.logger.Error("", "tx.Commit", req, err)
+		d.logger.Error(common.GetUUID(ctx), "tx.Commit", req, err)
 		return nil, d.errWarpper.NewDBCommitServiceError(err)
 	}
 
-	d.logger.Info("", "BindMainDevice.end", req, nil)
+	d.dispatcher.Publish(req.UserId, webhook.EventMainDeviceBind, map[string]any{
+		"main_device_id": device.Id,
+		"platform":       device.Platform,
+		"version":        device.Version,
+		"device_id":      device.DeviceId,
+	})
+	d.logger.Info(common.GetUUID(ctx), "BindMainDevice.end", req, nil)
 	return &dto.BindMainDeviceResponse{
 		MainDeviceId: device.Id,
 		Ok:           true,
+		Token:        token,
 	}, nil
 }
 
-func (d *deviceServiceImpl) BindSubDevice(ctx context.Context, req *dto.BindSubDeviceRequest) (*dto.BindSubDeviceResponse, *dtoError.ServiceError) {
+func (d *deviceServiceImpl) BindSubDevice(ctx context.Context, req *dto.BindSubDeviceRequest) (res *dto.BindSubDeviceResponse, serviceErr *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "DeviceService.BindSubDevice")
+	defer span.End()
+	defer func() {
+		metrics.DeviceOperation("bind", metrics.RoleSub, serviceErr == nil)
+	}()
+	if serviceErr := checkEmailVerified(ctx, d.userRepo, req.UserId, d.ALLOW_UNVERIFIED_BIND); serviceErr != nil {
+		d.logger.Info(common.GetUUID(ctx), "checkEmailVerified", req, serviceErr.InternalError)
+		return nil, serviceErr
+	}
+
 	txContext, tx := repository.SetTxContext(ctx)
 	ok, err := d.deviceRepo.CheckRepeatedDevice(txContext, req.Platform, req.Version, req.DeviceId)
 	if err != nil {
-		d.logger.Error("", "d.deviceRepo.CheckRepeatedDevice", req, err)
+		d.logger.Error(common.GetUUID(ctx), "d.deviceRepo.CheckRepeatedDevice", req, err)
 		tx.Rollback()
 		return nil, d.errWarpper.NewDBServiceError(err)
 	} else if !ok {
-		d.logger.Info("", "d.deviceRepo.CheckRepeatedDevice", req, nil)
+		d.logger.Info(common.GetUUID(ctx), "d.deviceRepo.CheckRepeatedDevice", req, nil)
 		tx.Rollback()
 		return nil, d.errWarpper.NewRepeatDeviceError()
 	}
 
 	count, err := d.deviceRepo.GetSubDeviceCount(txContext, req.UserId, req.MainDeviceId)
 	if err != nil {
-		d.logger.Error("", "d.deviceRepo.GetSubDeviceCount", req, err)
+		d.logger.Error(common.GetUUID(ctx), "d.deviceRepo.GetSubDeviceCount", req, err)
 		tx.Rollback()
 		return nil, d.errWarpper.NewDBServiceError(err)
 	} else if count >= d.MAX_SUB_DEVICE_COUNT {
-		d.logger.Info("", "d.deviceRepo.GetSubDeviceCount", req, nil)
+		d.logger.Info(common.GetUUID(ctx), "d.deviceRepo.GetSubDeviceCount", req, nil)
 		tx.Rollback()
 		return nil, d.errWarpper.NewSubDeviceTooManyError(d.MAX_SUB_DEVICE_COUNT)
 	}
 
 	device, err := d.deviceRepo.BindSubDevice(txContext, req.MainDeviceId, req.Platform, req.Version, req.DeviceId)
+	if err != nil {
+		d.logger.Error(common.GetUUID(ctx), "d.deviceRepo.BindSubDevice", req, err)
+		tx.Rollback()
+		return nil, d.errWarpper.NewDBServiceError(err)
+	}
+
+	_, token, err := issueCredential(txContext, d.credentialRepo, req.UserId, req.MainDeviceId, device.Id)
+	if err != nil {
+		d.logger.Error(common.GetUUID(ctx), "issueCredential", req, err)
+		tx.Rollback()
+		return nil, d.errWarpper.NewDBServiceError(err)
+	}
+
 	err = tx.Commit().Error
 	if err != nil {
-		d.logger.Error("", "d.deviceRepo.BindSubDevice", req, err)
+		d.logger.Error(common.GetUUID(ctx), "d.deviceRepo.BindSubDevice", req, err)
 		return nil, d.errWarpper.NewDBCommitServiceError(err)
 	}
 
-	d.logger.Info("", "BindSubDevice.end", req, nil)
+	d.dispatcher.Publish(req.UserId, webhook.EventSubDeviceBind, map[string]any{
+		"main_device_id": device.MainDeviceId,
+		"sub_device_id":  device.Id,
+		"platform":       device.Platform,
+		"version":        device.Version,
+		"device_id":      device.DeviceId,
+	})
+	d.logger.Info(common.GetUUID(ctx), "BindSubDevice.end", req, nil)
 	return &dto.BindSubDeviceResponse{
 		SubDeviceId: device.Id,
 		Ok:          true,
+		Token:       token,
 	}, nil
 }
 
-func (d *deviceServiceImpl) UnBindMainDevice(ctx context.Context, req *dto.UnbindMainDeviceRequest) (*dto.UnbindMainDeviceResponse, *dtoError.ServiceError) {
+func (d *deviceServiceImpl) UnBindMainDevice(ctx context.Context, req *dto.UnbindMainDeviceRequest) (res *dto.UnbindMainDeviceResponse, serviceErr *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "DeviceService.UnBindMainDevice")
+	defer span.End()
+	defer func() {
+		metrics.DeviceOperation("unbind", metrics.RoleMain, serviceErr == nil)
+	}()
 	txContext, tx := repository.SetTxContext(ctx)
 	binding, err := d.deviceRepo.CheckMainDeviceBinding(txContext, req.UserId, req.MainDeviceId)
 	if err != nil {
-		d.logger.Error("", "d.deviceRepo.BindSubDevice", req, err)
+		d.logger.Error(common.GetUUID(ctx), "d.deviceRepo.CheckMainDeviceBinding", req, err)
 		tx.Rollback()
 		return nil, d.errWarpper.NewDBServiceError(err)
 	} else if !binding {
-		d.logger.Info("", "d.deviceRepo.BindSubDevice", req, nil)
+		d.logger.Info(common.GetUUID(ctx), "d.deviceRepo.CheckMainDeviceBinding", req, nil)
 		tx.Rollback()
 		return nil, d.errWarpper.NewMainDeviceNotBindingError()
 	}
 
-	ok, err := d.deviceRepo.UnbindMainDevice(ctx, req.UserId, req.MainDeviceId)
+	ok, err := d.deviceRepo.UnbindMainDevice(txContext, req.UserId, req.MainDeviceId)
+	if err != nil {
+		d.logger.Error(common.GetUUID(ctx), "d.deviceRepo.UnbindMainDevice", req, err)
+		tx.Rollback()
+		return nil, d.errWarpper.NewDBServiceError(err)
+	}
+
+	_, err = d.credentialRepo.RevokeMainDeviceCredentials(txContext, req.UserId, req.MainDeviceId, time.Now())
 	if err != nil {
-		d.logger.Error("", "d.deviceRepo.UnbindMainDevice", req, err)
+		d.logger.Error(common.GetUUID(ctx), "d.credentialRepo.RevokeMainDeviceCredentials", req, err)
+		tx.Rollback()
 		return nil, d.errWarpper.NewDBServiceError(err)
 	}
 
-	d.logger.Info("", "UnBindMainDevice.end", req, nil)
+	err = tx.Commit().Error
+	if err != nil {
+		return nil, d.errWarpper.NewDBCommitServiceError(err)
+	}
+	GetCommunicationSerivice().DisconnectDevice(ctx, req.UserId, req.MainDeviceId, 0, "main device unbound")
+
+	if ok {
+		d.dispatcher.Publish(req.UserId, webhook.EventMainDeviceUnbind, map[string]any{"main_device_id": req.MainDeviceId})
+	}
+	d.logger.Info(common.GetUUID(ctx), "UnBindMainDevice.end", req, nil)
 	return &dto.UnbindMainDeviceResponse{Ok: ok}, nil
 }
 
-func (d *deviceServiceImpl) UnBindSubDevice(ctx context.Context, req *dto.UnbindSubDeviceRequest) (*dto.UnbindSubDeviceResponse, *dtoError.ServiceError) {
+func (d *deviceServiceImpl) UnBindSubDevice(ctx context.Context, req *dto.UnbindSubDeviceRequest) (res *dto.UnbindSubDeviceResponse, serviceErr *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "DeviceService.UnBindSubDevice")
+	defer span.End()
+	defer func() {
+		metrics.DeviceOperation("unbind", metrics.RoleSub, serviceErr == nil)
+	}()
 	txContext, tx := repository.SetTxContext(ctx)
 	binding, err := d.deviceRepo.CheckMainDeviceBinding(txContext, req.UserId, req.MainDeviceId)
 	if err != nil {
-		d.logger.Error("", "d.deviceRepo.CheckMainDeviceBinding", req, err)
+		d.logger.Error(common.GetUUID(ctx), "d.deviceRepo.CheckMainDeviceBinding", req, err)
 		tx.Rollback()
 		return nil, d.errWarpper.NewDBServiceError(err)
 	} else if !binding {
-		d.logger.Info("", "d.deviceRepo.CheckMainDeviceBinding", req, nil)
+		d.logger.Info(common.GetUUID(ctx), "d.deviceRepo.CheckMainDeviceBinding", req, nil)
 		tx.Rollback()
 		return nil, d.errWarpper.NewMainDeviceNotBindingError()
 	}
 
-	err = d.deviceRepo.UnbindSubDevice(ctx, req.MainDeviceId, req.SubDeviceId)
+	err = d.deviceRepo.UnbindSubDevice(txContext, req.MainDeviceId, req.SubDeviceId)
 	if err != nil {
-		d.logger.Error("", "d.deviceRepo.UnbindSubDevice", req, err)
+		d.logger.Error(common.GetUUID(ctx), "d.deviceRepo.UnbindSubDevice", req, err)
+		tx.Rollback()
+		return nil, d.errWarpper.NewDBServiceError(err)
+	}
+
+	_, err = d.credentialRepo.RevokeCredentials(txContext, req.UserId, req.MainDeviceId, req.SubDeviceId, time.Now())
+	if err != nil {
+		d.logger.Error(common.GetUUID(ctx), "d.credentialRepo.RevokeCredentials", req, err)
+		tx.Rollback()
 		return nil, d.errWarpper.NewDBServiceError(err)
 	}
 
@@ -160,15 +279,22 @@ func (d *deviceServiceImpl) UnBindSubDevice(ctx context.Context, req *dto.Unbind
 	if err != nil {
 		return nil, d.errWarpper.NewDBCommitServiceError(err)
 	}
+	GetCommunicationSerivice().DisconnectDevice(ctx, req.UserId, req.MainDeviceId, req.SubDeviceId, "sub device unbound")
 
-	d.logger.Info("", "UnBindSubDevice.end", req, nil)
+	d.dispatcher.Publish(req.UserId, webhook.EventSubDeviceUnbind, map[string]any{
+		"main_device_id": req.MainDeviceId,
+		"sub_device_id":  req.SubDeviceId,
+	})
+	d.logger.Info(common.GetUUID(ctx), "UnBindSubDevice.end", req, nil)
 	return &dto.UnbindSubDeviceResponse{}, nil
 }
 
 func (d *deviceServiceImpl) GetDevicesByUserId(ctx context.Context, req *dto.GetDevicesByUserIdRequest) (*dto.GetDevicesByUserIdResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "DeviceService.GetDevicesByUserId")
+	defer span.End()
 	mainDevices, err := d.deviceRepo.GetAllDevicesByUserId(ctx, req.UserId)
 	if err != nil {
-		d.logger.Error("", "d.deviceRepo.GetAllDevicesByUserId", req, err)
+		d.logger.Error(common.GetUUID(ctx), "d.deviceRepo.GetAllDevicesByUserId", req, err)
 		return nil, d.errWarpper.NewDBServiceError(err)
 	}
 
@@ -201,6 +327,69 @@ func (d *deviceServiceImpl) GetDevicesByUserId(ctx context.Context, req *dto.Get
 	return response, nil
 }
 
+func (d *deviceServiceImpl) GetRoomPolicy(ctx context.Context, req *dto.GetRoomPolicyRequest) (*dto.GetRoomPolicyResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "DeviceService.GetRoomPolicy")
+	defer span.End()
+	ok, err := d.deviceRepo.CheckMainDeviceBinding(ctx, req.UserId, req.MainDeviceId)
+	if err != nil {
+		d.logger.Error(common.GetUUID(ctx), "d.deviceRepo.CheckMainDeviceBinding", req, err)
+		return nil, d.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		d.logger.Info(common.GetUUID(ctx), "d.deviceRepo.CheckMainDeviceBinding", req, nil)
+		return nil, d.errWarpper.NewMainDeviceNotBindingError()
+	}
+
+	policy, _, err := d.policyRepo.GetPolicy(ctx, req.MainDeviceId)
+	if err != nil {
+		d.logger.Error(common.GetUUID(ctx), "d.policyRepo.GetPolicy", req, err)
+		return nil, d.errWarpper.NewDBServiceError(err)
+	}
+	return &dto.GetRoomPolicyResponse{
+		Policy: newRoomPolicy(policy).toDto(req.MainDeviceId),
+	}, nil
+}
+
+func (d *deviceServiceImpl) UpdateRoomPolicy(ctx context.Context, req *dto.UpdateRoomPolicyRequest) (*dto.UpdateRoomPolicyResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "DeviceService.UpdateRoomPolicy")
+	defer span.End()
+	if req.MaxSubDevice > d.MAX_SUB_DEVICE_COUNT {
+		return nil, d.errWarpper.NewRoomPolicyInvalidError(fmt.Sprintf("max_sub_device should <= %d", d.MAX_SUB_DEVICE_COUNT))
+	}
+	if req.IdleTimeoutSecond > d.MAX_IDLE_SECOND {
+		return nil, d.errWarpper.NewRoomPolicyInvalidError(fmt.Sprintf("idle_timeout_second should <= %d", d.MAX_IDLE_SECOND))
+	}
+	if req.MaxMessageByte > d.MAX_MESSAGE_BYTE {
+		return nil, d.errWarpper.NewRoomPolicyInvalidError(fmt.Sprintf("max_message_byte should <= %d", d.MAX_MESSAGE_BYTE))
+	}
+
+	ok, err := d.deviceRepo.CheckMainDeviceBinding(ctx, req.UserId, req.MainDeviceId)
+	if err != nil {
+		d.logger.Error(common.GetUUID(ctx), "d.deviceRepo.CheckMainDeviceBinding", req, err)
+		return nil, d.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		d.logger.Info(common.GetUUID(ctx), "d.deviceRepo.CheckMainDeviceBinding", req, nil)
+		return nil, d.errWarpper.NewMainDeviceNotBindingError()
+	}
+
+	policy := &model.RoomPolicy{
+		MainDeviceId:      req.MainDeviceId,
+		MaxSubDevice:      req.MaxSubDevice,
+		IdleTimeoutSecond: req.IdleTimeoutSecond,
+		AllowedFrameTypes: strings.Join(req.AllowedFrameTypes, ","),
+		MaxMessageByte:    req.MaxMessageByte,
+	}
+	err = d.policyRepo.UpsertPolicy(ctx, policy)
+	if err != nil {
+		d.logger.Error(common.GetUUID(ctx), "d.policyRepo.UpsertPolicy", req, err)
+		return nil, d.errWarpper.NewDBServiceError(err)
+	}
+
+	d.logger.Info(common.GetUUID(ctx), "UpdateRoomPolicy.end", req, nil)
+	return &dto.UpdateRoomPolicyResponse{
+		Policy: newRoomPolicy(policy).toDto(req.MainDeviceId),
+	}, nil
+}
+
 func GetDeviceService() DeviceService {
 	return device
 }
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"device-communication/src/common"
+	"device-communication/src/config"
+	"device-communication/src/dtoError"
+	logger "device-communication/src/log"
+	"device-communication/src/model"
+	"device-communication/src/repository"
+	"strings"
+	"time"
+)
+
+func accountLockSubject(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func ipLockSubject(ip string) string {
+	return "ip:" + ip
+}
+
+// loginLockout counts failed logins per account and per client ip and locks them out progressively.
+type loginLockout struct {
+	lockoutRepo             repository.LockoutRepository
+	errWarpper              dtoError.ServiceErrorWarpper
+	logger                  logger.Logger
+	MAX_FAILURE_PER_ACCOUNT int64
+	MAX_FAILURE_PER_IP      int64
+	WINDOW                  time.Duration
+	LOCKOUT_BASE            time.Duration
+	LOCKOUT_MAX             time.Duration
+}
+
+func newLoginLockout() *loginLockout {
+	l := config.GlobalConfig.YamlConfig.LoginLockout
+	return &loginLockout{
+		lockoutRepo:             repository.GetLockoutRepository(),
+		errWarpper:              dtoError.GetServiceErrorWarpper(),
+		logger:                  logger.NewInfoLogger(),
+		MAX_FAILURE_PER_ACCOUNT: l.MaxFailurePerAccount,
+		MAX_FAILURE_PER_IP:      l.MaxFailurePerIp,
+		WINDOW:                  time.Duration(l.Window) * time.Second,
+		LOCKOUT_BASE:            time.Duration(l.LockoutBase) * time.Second,
+		LOCKOUT_MAX:             time.Duration(l.LockoutMax) * time.Second,
+	}
+}
+
+// check runs before the password is compared so a locked out client never costs a bcrypt.
+func (l *loginLockout) check(ctx context.Context, username string, ip string) *dtoError.ServiceError {
+	retryAfter, err := l.lockoutRepo.GetLock(ctx, accountLockSubject(username))
+	if err != nil {
+		return l.errWarpper.NewDBServiceError(err)
+	} else if retryAfter > 0 {
+		return l.errWarpper.NewAccountLockedError(retryAfter)
+	}
+	if ip == "" {
+		return nil
+	}
+
+	retryAfter, err = l.lockoutRepo.GetLock(ctx, ipLockSubject(ip))
+	if err != nil {
+		return l.errWarpper.NewDBServiceError(err)
+	} else if retryAfter > 0 {
+		return l.errWarpper.NewTooManyRequestsError(retryAfter)
+	}
+	return nil
+}
+
+func (l *loginLockout) fail(ctx context.Context, username string, ip string) {
+	l.count(ctx, accountLockSubject(username), l.MAX_FAILURE_PER_ACCOUNT, username, ip)
+	if ip != "" {
+		l.count(ctx, ipLockSubject(ip), l.MAX_FAILURE_PER_IP, username, ip)
+	}
+}
+
+func (l *loginLockout) count(ctx context.Context, subject string, max int64, username string, ip string) {
+	data := map[string]any{"subject": subject}
+	count, err := l.lockoutRepo.AddFailure(ctx, subject, l.WINDOW)
+	if err != nil {
+		l.logger.Error(common.GetUUID(ctx), "l.lockoutRepo.AddFailure", data, err)
+		return
+	} else if count < max {
+		return
+	}
+
+	duration, err := l.lockoutRepo.Lock(ctx, subject, l.LOCKOUT_BASE, l.LOCKOUT_MAX)
+	if err != nil {
+		l.logger.Error(common.GetUUID(ctx), "l.lockoutRepo.Lock", data, err)
+		return
+	}
+
+	lockedUntil := time.Now().Add(duration)
+	err = l.lockoutRepo.CreateEvent(ctx, &model.LockoutEvent{
+		Username:    username,
+		Ip:          ip,
+		Event:       model.LockoutEventLocked,
+		LockedUntil: &lockedUntil,
+	})
+	if err != nil {
+		l.logger.Error(common.GetUUID(ctx), "l.lockoutRepo.CreateEvent", data, err)
+	}
+	l.logger.Warning(common.GetUUID(ctx), "loginLockout.lock", data, nil)
+}
+
+func (l *loginLockout) succeed(ctx context.Context, username string) {
+	if err := l.lockoutRepo.ClearFailures(ctx, accountLockSubject(username)); err != nil {
+		l.logger.Error(common.GetUUID(ctx), "l.lockoutRepo.ClearFailures", username, err)
+	}
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+const maxLoginCacheEntries = 10000
+
+type loginCacheEntry struct {
+	userId uint64
+	// hashed is the stored password hash when the verdict was made, empty if the user did not exist
+	hashed   string
+	verified bool
+	expire   time.Time
+}
+
+// loginCache remembers the verdict for a username and password pair, so devices that log in with a password
+// on every connect or every call do not cost a bcrypt each time, and a device stuck on an old password
+// is counted once by the lockout instead of on every retry.
+type loginCache struct {
+	mu           sync.Mutex
+	secret       []byte
+	entries      map[[sha256.Size]byte]loginCacheEntry
+	VERIFIED_TTL time.Duration
+	FAILED_TTL   time.Duration
+}
+
+func newLoginCache(verifiedTTL time.Duration, failedTTL time.Duration) *loginCache {
+	// the passwords are only kept as a keyed hash that is useless outside this process
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	return &loginCache{
+		secret:       secret,
+		entries:      make(map[[sha256.Size]byte]loginCacheEntry),
+		VERIFIED_TTL: verifiedTTL,
+		FAILED_TTL:   failedTTL,
+	}
+}
+
+func (l *loginCache) key(username string, password string) [sha256.Size]byte {
+	mac := hmac.New(sha256.New, l.secret)
+	mac.Write([]byte(username))
+	mac.Write([]byte{0})
+	mac.Write([]byte(password))
+	var key [sha256.Size]byte
+	copy(key[:], mac.Sum(nil))
+	return key
+}
+
+func (l *loginCache) get(username string, password string) (loginCacheEntry, bool) {
+	key := l.key(username, password)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry, ok := l.entries[key]
+	if ok && time.Now().After(entry.expire) {
+		delete(l.entries, key)
+		return loginCacheEntry{}, false
+	}
+	return entry, ok
+}
+
+func (l *loginCache) put(username string, password string, userId uint64, hashed string, verified bool) {
+	ttl := l.FAILED_TTL
+	if verified {
+		ttl = l.VERIFIED_TTL
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	key := l.key(username, password)
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.entries) >= maxLoginCacheEntries {
+		for k, entry := range l.entries {
+			if now.After(entry.expire) {
+				delete(l.entries, k)
+			}
+		}
+		if len(l.entries) >= maxLoginCacheEntries {
+			l.entries = make(map[[sha256.Size]byte]loginCacheEntry)
+		}
+	}
+	l.entries[key] = loginCacheEntry{userId: userId, hashed: hashed, verified: verified, expire: now.Add(ttl)}
+}
+
+func (l *loginCache) remove(username string, password string) {
+	key := l.key(username, password)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, key)
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"device-communication/src/config"
+	"device-communication/src/dto"
+	"device-communication/src/model"
+	"slices"
+	"strings"
+	"time"
+)
+
+var allDeviceFrameTypes = []string{
+	dto.DeviceFrameTypeRequest,
+	dto.DeviceFrameTypeResponse,
+	dto.DeviceFrameTypeError,
+	dto.DeviceFrameTypeSubscribe,
+	dto.DeviceFrameTypeUnsubscribe,
+	dto.DeviceFrameTypeControl,
+}
+
+// roomPolicy is read when a room opens and stays fixed until the room closes.
+type roomPolicy struct {
+	maxSubDevice      int64
+	idleTimeout       time.Duration
+	allowedFrameTypes map[string]bool
+	maxMessageByte    int64
+	isDefault         bool
+}
+
+func defaultRoomPolicy() *roomPolicy {
+	limit := config.GlobalConfig.YamlConfig.Limit
+	return &roomPolicy{
+		maxSubDevice:      limit.MaxSubDevice,
+		idleTimeout:       time.Duration(limit.MainDeviceIdle) * time.Second,
+		allowedFrameTypes: frameTypeSet(allDeviceFrameTypes),
+		maxMessageByte:    limit.MaxMessageByte,
+		isDefault:         true,
+	}
+}
+
+func newRoomPolicy(policy *model.RoomPolicy) *roomPolicy {
+	if policy == nil {
+		return defaultRoomPolicy()
+	}
+	return &roomPolicy{
+		maxSubDevice:      policy.MaxSubDevice,
+		idleTimeout:       time.Duration(policy.IdleTimeoutSecond) * time.Second,
+		allowedFrameTypes: frameTypeSet(splitFrameTypes(policy.AllowedFrameTypes)),
+		maxMessageByte:    policy.MaxMessageByte,
+	}
+}
+
+func frameTypeSet(frameTypes []string) map[string]bool {
+	set := make(map[string]bool, len(frameTypes))
+	for _, frameType := range frameTypes {
+		set[frameType] = true
+	}
+	return set
+}
+
+func splitFrameTypes(frameTypes string) []string {
+	if frameTypes == "" {
+		return []string{}
+	}
+	return strings.Split(frameTypes, ",")
+}
+
+// only the envelope types the server understands are policed, application defined types are relayed as before.
+func (p *roomPolicy) allowFrame(frameType string) bool {
+	if !slices.Contains(allDeviceFrameTypes, frameType) {
+		return true
+	}
+	return p.allowedFrameTypes[frameType]
+}
+
+func (p *roomPolicy) toDto(mainDeviceId uint64) *dto.RoomPolicy {
+	frameTypes := make([]string, 0, len(p.allowedFrameTypes))
+	for _, frameType := range allDeviceFrameTypes {
+		if p.allowedFrameTypes[frameType] {
+			frameTypes = append(frameTypes, frameType)
+		}
+	}
+	return &dto.RoomPolicy{
+		MainDeviceId:      mainDeviceId,
+		MaxSubDevice:      p.maxSubDevice,
+		IdleTimeoutSecond: int64(p.idleTimeout / time.Second),
+		AllowedFrameTypes: frameTypes,
+		MaxMessageByte:    p.maxMessageByte,
+		Default:           p.isDefault,
+	}
+}
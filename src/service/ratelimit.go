@@ -0,0 +1,19 @@
+package service
+
+import (
+	"context"
+	"device-communication/src/repository"
+	"time"
+)
+
+// hitRateLimit counts a request against key, a positive duration means the limit is used up until then.
+func hitRateLimit(ctx context.Context, rateLimitRepo repository.RateLimitRepository, key string, limit int64, window time.Duration) (time.Duration, error) {
+	count, ttl, err := rateLimitRepo.Hit(ctx, key, window)
+	if err != nil {
+		return 0, err
+	}
+	if count > limit {
+		return ttl, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,319 @@
+package service
+
+import (
+	"device-communication/src/metrics"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const ResumeTokenHeader = "X-Resume-Token"
+
+var errSessionEnded = errors.New("session ended")
+
+type bufferedMessage struct {
+	messageType int
+	data        []byte
+}
+
+// sessionSlot is the connection a device session writes to. When the device drops it keeps the
+// session's place in the room and buffers writes until the device resumes or the grace window ends.
+type sessionSlot struct {
+	mu        sync.Mutex
+	conn      DeviceConnection
+	buffer    []bufferedMessage
+	maxBuffer int
+	timer     *time.Timer
+	token     string
+	ended     bool
+}
+
+func (s *sessionSlot) WriteMessage(messageType int, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		return s.conn.WriteMessage(messageType, data)
+	}
+	if s.ended {
+		return errSessionEnded
+	}
+	if messageType == websocket.CloseMessage {
+		return nil
+	}
+	s.append(messageType, data)
+	return nil
+}
+
+func (s *sessionSlot) WriteBroadcast(b *broadcast) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		return writeBroadcast(s.conn, b)
+	}
+	if s.ended {
+		return errSessionEnded
+	}
+	s.append(b.messageType, b.data)
+	return nil
+}
+
+func (s *sessionSlot) append(messageType int, data []byte) {
+	if len(s.buffer) >= s.maxBuffer {
+		s.buffer = s.buffer[1:]
+		metrics.Dropped(metrics.DropResumeBuffer)
+	}
+	s.buffer = append(s.buffer, bufferedMessage{messageType: messageType, data: data})
+}
+
+func (s *sessionSlot) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	// nobody will resume into a closed room, end the session now instead of after the grace window
+	if !s.ended && s.timer != nil {
+		s.timer.Reset(0)
+	}
+	return nil
+}
+
+func (s *sessionSlot) attachedTo(conn DeviceConnection) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn == conn
+}
+
+func (s *sessionSlot) getToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token
+}
+
+// detach starts the grace window, it reports false when the session has to end now.
+func (s *sessionSlot) detach(conn DeviceConnection, grace time.Duration, expire func()) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != conn {
+		// another connection already took the session over
+		return true
+	}
+	if s.ended || grace <= 0 {
+		return false
+	}
+	s.conn = nil
+	s.timer = time.AfterFunc(grace, expire)
+	return true
+}
+
+// resume flushes the buffered writes to conn and makes it the current connection.
+func (s *sessionSlot) resume(conn DeviceConnection, token string) bool {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return false
+	}
+	if s.timer != nil {
+		if !s.timer.Stop() {
+			// the grace window ended while the device was reconnecting
+			s.mu.Unlock()
+			return false
+		}
+		s.timer = nil
+	}
+	for _, message := range s.buffer {
+		conn.WriteMessage(message.messageType, message.data)
+	}
+	previous := s.conn
+	s.buffer = nil
+	s.conn = conn
+	s.token = token
+	s.mu.Unlock()
+
+	if previous != nil {
+		_ = previous.WriteMessage(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, "session resumed by another connection"))
+		_ = previous.Close()
+	}
+	return true
+}
+
+// end forbids resuming, it reports whether the session was waiting for a resume.
+func (s *sessionSlot) end() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	detached := !s.ended && s.conn == nil
+	s.ended = true
+	s.buffer = nil
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	return detached
+}
+
+type resumableSession interface {
+	HandleMessage(messageType int, message []byte)
+	Close()
+	getSlot() *sessionSlot
+	owner() (uint64, uint64, uint64)
+	roomClosed() bool
+	role() string
+}
+
+type resumeRegistry struct {
+	sessions          map[string]resumableSession
+	mu                sync.Mutex
+	GRACE_PERIOD      time.Duration
+	MAX_BUFFER_NUMBER int
+}
+
+func newResumeToken() string {
+	return uuid.New().String()
+}
+
+func (r *resumeRegistry) newSlot(conn DeviceConnection, token string) *sessionSlot {
+	return &sessionSlot{
+		conn:      conn,
+		token:     token,
+		maxBuffer: r.MAX_BUFFER_NUMBER,
+	}
+}
+
+func (r *resumeRegistry) add(token string, session resumableSession) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[token] = session
+}
+
+func (r *resumeRegistry) remove(token string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, token)
+}
+
+func (r *resumeRegistry) find(token string, userId uint64, mainDeviceId uint64, subDeviceId uint64) (resumableSession, bool) {
+	if token == "" {
+		return nil, false
+	}
+	session, ok := r.sessions[token]
+	if !ok {
+		return nil, false
+	}
+	ownerUserId, ownerMainDeviceId, ownerSubDeviceId := session.owner()
+	if ownerUserId != userId || ownerMainDeviceId != mainDeviceId || ownerSubDeviceId != subDeviceId {
+		return nil, false
+	}
+	return session, true
+}
+
+func (r *resumeRegistry) lookup(token string, userId uint64, mainDeviceId uint64, subDeviceId uint64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.find(token, userId, mainDeviceId, subDeviceId)
+	return ok
+}
+
+func (r *resumeRegistry) take(token string, userId uint64, mainDeviceId uint64, subDeviceId uint64) (resumableSession, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.find(token, userId, mainDeviceId, subDeviceId)
+	if ok {
+		delete(r.sessions, token)
+	}
+	return session, ok
+}
+
+// revoke ends every session of a device so a kicked device cannot resume.
+func (r *resumeRegistry) revoke(userId uint64, mainDeviceId uint64, subDeviceId uint64) {
+	r.mu.Lock()
+	var sessions []resumableSession
+	for _, session := range r.sessions {
+		ownerUserId, ownerMainDeviceId, ownerSubDeviceId := session.owner()
+		if ownerUserId == userId && ownerMainDeviceId == mainDeviceId && ownerSubDeviceId == subDeviceId {
+			sessions = append(sessions, session)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, session := range sessions {
+		if session.getSlot().end() {
+			session.Close()
+		}
+	}
+}
+
+// deviceAttachment ties one transport connection to a session, a resumed session gets a new attachment.
+type deviceAttachment struct {
+	communication *communicationSeriviceImpl
+	session       resumableSession
+	conn          DeviceConnection
+	token         string
+}
+
+func (c *communicationSeriviceImpl) attach(session resumableSession, conn DeviceConnection, token string) *deviceAttachment {
+	return &deviceAttachment{
+		communication: c,
+		session:       session,
+		conn:          conn,
+		token:         token,
+	}
+}
+
+func (a *deviceAttachment) HandleMessage(messageType int, message []byte) {
+	a.session.HandleMessage(messageType, message)
+}
+
+func (a *deviceAttachment) ResumeToken() string {
+	return a.token
+}
+
+func (a *deviceAttachment) Suspend() {
+	if a.session.roomClosed() || !a.session.getSlot().detach(a.conn, a.communication.resumes.GRACE_PERIOD, a.session.Close) {
+		a.Close()
+	}
+}
+
+// Kick ends the session for good and closes the connection.
+func (a *deviceAttachment) Kick(reason string) {
+	slot := a.session.getSlot()
+	if slot.attachedTo(a.conn) {
+		_ = slot.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, reason))
+		a.session.Close()
+	}
+	_ = a.conn.Close()
+}
+
+func (a *deviceAttachment) Close() {
+	if a.session.getSlot().attachedTo(a.conn) {
+		a.session.Close()
+	}
+}
+
+func (c *communicationSeriviceImpl) resumeDevice(token string, userId uint64, mainDeviceId uint64, subDeviceId uint64, conn DeviceConnection, newToken string) (DeviceSession, string) {
+	session, ok := c.resumes.take(token, userId, mainDeviceId, subDeviceId)
+	if !ok {
+		return nil, "resume token expired"
+	}
+
+	// registered before the slot switches tokens so a concurrent Close always finds it
+	c.resumes.add(newToken, session)
+	if session.roomClosed() || !session.getSlot().resume(conn, newToken) {
+		c.resumes.remove(newToken)
+		return nil, "resume token expired"
+	}
+
+	metrics.Resumes.WithLabelValues(session.role()).Inc()
+	return c.attach(session, conn, newToken), ""
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
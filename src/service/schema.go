@@ -0,0 +1,176 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"device-communication/src/common"
+	"device-communication/src/dto"
+	"device-communication/src/dtoError"
+	logger "device-communication/src/log"
+	"device-communication/src/model"
+	"device-communication/src/repository"
+	"device-communication/src/telemetry"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+type SchemaService interface {
+	RegisterMessageSchema(ctx context.Context, req *dto.RegisterMessageSchemaRequest) (*dto.RegisterMessageSchemaResponse, *dtoError.ServiceError)
+	GetMessageSchemas(ctx context.Context, req *dto.GetMessageSchemasRequest) (*dto.GetMessageSchemasResponse, *dtoError.ServiceError)
+	DeleteMessageSchema(ctx context.Context, req *dto.DeleteMessageSchemaRequest) (*dto.DeleteMessageSchemaResponse, *dtoError.ServiceError)
+	GetSchemaViolations(ctx context.Context, req *dto.GetSchemaViolationsRequest) (*dto.GetSchemaViolationsResponse, *dtoError.ServiceError)
+}
+
+type schemaServiceImpl struct {
+	schemaRepo           repository.SchemaRepository
+	errWarpper           dtoError.ServiceErrorWarpper
+	MAX_VIOLATION_NUMBER int
+	logger               logger.Logger
+}
+
+var schema SchemaService
+
+func init() {
+	schema = &schemaServiceImpl{
+		schemaRepo:           repository.GetSchemaRepository(),
+		errWarpper:           dtoError.GetServiceErrorWarpper(),
+		MAX_VIOLATION_NUMBER: 100,
+		logger:               logger.NewInfoLogger(),
+	}
+}
+
+func GetSchemaService() SchemaService {
+	return schema
+}
+
+type messageValidator struct {
+	schemaId uint64
+	policy   string
+	schema   *jsonschema.Schema
+}
+
+var errSchemaRefNotAllowed = errors.New("only refs inside the schema are allowed")
+
+func compileMessageSchema(content string) (*jsonschema.Schema, error) {
+	compiler := jsonschema.NewCompiler()
+	// the default loaders read file:// and http(s):// urls on behalf of the user
+	compiler.LoadURL = func(url string) (io.ReadCloser, error) {
+		return nil, errSchemaRefNotAllowed
+	}
+	if err := compiler.AddResource("schema.json", strings.NewReader(content)); err != nil {
+		return nil, err
+	}
+	return compiler.Compile("schema.json")
+}
+
+func newMessageValidator(messageSchema *model.MessageSchema) (*messageValidator, error) {
+	compiled, err := compileMessageSchema(messageSchema.Schema)
+	if err != nil {
+		return nil, err
+	}
+	return &messageValidator{
+		schemaId: messageSchema.Id,
+		policy:   messageSchema.Policy,
+		schema:   compiled,
+	}, nil
+}
+
+func (m *messageValidator) Validate(message []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(message))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return err
+	}
+	return m.schema.Validate(v)
+}
+
+func (s *schemaServiceImpl) RegisterMessageSchema(ctx context.Context, req *dto.RegisterMessageSchemaRequest) (*dto.RegisterMessageSchemaResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "SchemaService.RegisterMessageSchema")
+	defer span.End()
+	data := map[string]any{"user_id": req.UserId, "platform": req.Platform, "version": req.Version, "policy": req.Policy}
+	if _, err := compileMessageSchema(string(req.Schema)); err != nil {
+		s.logger.Info(common.GetUUID(ctx), "compileMessageSchema", data, err)
+		return nil, s.errWarpper.NewSchemaInvalidError(err)
+	}
+
+	messageSchema, err := s.schemaRepo.UpsertSchema(ctx, req.UserId, req.Platform, req.Version, string(req.Schema), req.Policy)
+	if err != nil {
+		s.logger.Error(common.GetUUID(ctx), "s.schemaRepo.UpsertSchema", data, err)
+		return nil, s.errWarpper.NewDBServiceError(err)
+	}
+
+	s.logger.Info(common.GetUUID(ctx), "RegisterMessageSchema.end", data, nil)
+	return &dto.RegisterMessageSchemaResponse{SchemaId: messageSchema.Id}, nil
+}
+
+func (s *schemaServiceImpl) GetMessageSchemas(ctx context.Context, req *dto.GetMessageSchemasRequest) (*dto.GetMessageSchemasResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "SchemaService.GetMessageSchemas")
+	defer span.End()
+	schemas, err := s.schemaRepo.GetSchemasByUserId(ctx, req.UserId)
+	if err != nil {
+		s.logger.Error(common.GetUUID(ctx), "s.schemaRepo.GetSchemasByUserId", req, err)
+		return nil, s.errWarpper.NewDBServiceError(err)
+	}
+
+	response := &dto.GetMessageSchemasResponse{
+		Schemas: make([]*dto.MessageSchema, 0, len(schemas)),
+	}
+	for _, messageSchema := range schemas {
+		response.Schemas = append(response.Schemas, &dto.MessageSchema{
+			Id:         messageSchema.Id,
+			Platform:   messageSchema.Platform,
+			Version:    messageSchema.Version,
+			Schema:     json.RawMessage(messageSchema.Schema),
+			Policy:     messageSchema.Policy,
+			UpdateTime: messageSchema.UpdatedAt,
+		})
+	}
+	return response, nil
+}
+
+func (s *schemaServiceImpl) DeleteMessageSchema(ctx context.Context, req *dto.DeleteMessageSchemaRequest) (*dto.DeleteMessageSchemaResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "SchemaService.DeleteMessageSchema")
+	defer span.End()
+	ok, err := s.schemaRepo.DeleteSchema(ctx, req.UserId, req.SchemaId)
+	if err != nil {
+		s.logger.Error(common.GetUUID(ctx), "s.schemaRepo.DeleteSchema", req, err)
+		return nil, s.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		s.logger.Info(common.GetUUID(ctx), "s.schemaRepo.DeleteSchema", req, nil)
+		return nil, s.errWarpper.NewSchemaNotFoundError()
+	}
+
+	s.logger.Info(common.GetUUID(ctx), "DeleteMessageSchema.end", req, nil)
+	return &dto.DeleteMessageSchemaResponse{Ok: true}, nil
+}
+
+func (s *schemaServiceImpl) GetSchemaViolations(ctx context.Context, req *dto.GetSchemaViolationsRequest) (*dto.GetSchemaViolationsResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "SchemaService.GetSchemaViolations")
+	defer span.End()
+	violations, err := s.schemaRepo.GetViolations(ctx, req.UserId, req.MainDeviceId, req.Action, s.MAX_VIOLATION_NUMBER)
+	if err != nil {
+		s.logger.Error(common.GetUUID(ctx), "s.schemaRepo.GetViolations", req, err)
+		return nil, s.errWarpper.NewDBServiceError(err)
+	}
+
+	response := &dto.GetSchemaViolationsResponse{
+		Violations: make([]*dto.SchemaViolation, 0, len(violations)),
+	}
+	for _, violation := range violations {
+		response.Violations = append(response.Violations, &dto.SchemaViolation{
+			Id:           violation.Id,
+			SchemaId:     violation.SchemaId,
+			MainDeviceId: violation.MainDeviceId,
+			Action:       violation.Action,
+			Message:      violation.Message,
+			Reason:       violation.Reason,
+			CreateTime:   violation.CreatedAt,
+		})
+	}
+	return response, nil
+}
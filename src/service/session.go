@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"device-communication/src/common"
+	"device-communication/src/config"
+	"device-communication/src/dto"
+	"device-communication/src/dtoError"
+	logger "device-communication/src/log"
+	"device-communication/src/model"
+	"device-communication/src/repository"
+	"device-communication/src/telemetry"
+	"sync"
+	"time"
+)
+
+type LoginSessionService interface {
+	RecordSession(ctx context.Context, req *dto.RecordLoginSessionRequest) *dtoError.ServiceError
+	Logout(ctx context.Context, req *dto.LogoutRequest) *dtoError.ServiceError
+	GetSessions(ctx context.Context, req *dto.GetLoginSessionsRequest) (*dto.GetLoginSessionsResponse, *dtoError.ServiceError)
+	RevokeSession(ctx context.Context, req *dto.RevokeLoginSessionRequest) (*dto.RevokeLoginSessionResponse, *dtoError.ServiceError)
+	RevokeAllSessions(ctx context.Context, req *dto.RevokeAllLoginSessionsRequest) (*dto.RevokeAllLoginSessionsResponse, *dtoError.ServiceError)
+}
+
+type loginSessionServiceImpl struct {
+	sessionRepo repository.LoginSessionRepository
+	errWarpper  dtoError.ServiceErrorWarpper
+	logger      logger.Logger
+	sessionAge  time.Duration
+}
+
+var loginSession LoginSessionService
+
+func init() {
+	loginSession = &loginSessionServiceImpl{
+		sessionRepo: repository.GetLoginSessionRepository(),
+		errWarpper:  dtoError.GetServiceErrorWarpper(),
+		logger:      logger.NewInfoLogger(),
+		sessionAge:  time.Duration(config.GlobalConfig.YamlConfig.Server.Session.Age) * time.Second,
+	}
+}
+
+func GetLoginSessionService() LoginSessionService {
+	return loginSession
+}
+
+func (l *loginSessionServiceImpl) RecordSession(ctx context.Context, req *dto.RecordLoginSessionRequest) *dtoError.ServiceError {
+	ctx, span := telemetry.Start(ctx, "LoginSessionService.RecordSession")
+	defer span.End()
+	err := l.sessionRepo.AddSession(ctx, req.UserId, &model.LoginSession{
+		SessionId:  req.SessionId,
+		CreateTime: time.Now(),
+		Ip:         req.Ip,
+		UserAgent:  req.UserAgent,
+	}, l.sessionAge)
+	if err != nil {
+		l.logger.Error(common.GetUUID(ctx), "l.sessionRepo.AddSession", req.UserId, err)
+		return l.errWarpper.NewDBServiceError(err)
+	}
+	return nil
+}
+
+// revoke deletes the session from redis and closes the websockets opened with it.
+func (l *loginSessionServiceImpl) revoke(ctx context.Context, userId uint64, sessionId string) (bool, error) {
+	ok, err := l.sessionRepo.RemoveSession(ctx, userId, sessionId)
+	if err != nil {
+		return false, err
+	}
+	GetCommunicationSerivice().DisconnectLoginSession(ctx, sessionId, "login session revoked")
+	return ok, nil
+}
+
+func (l *loginSessionServiceImpl) Logout(ctx context.Context, req *dto.LogoutRequest) *dtoError.ServiceError {
+	ctx, span := telemetry.Start(ctx, "LoginSessionService.Logout")
+	defer span.End()
+	if _, err := l.revoke(ctx, req.UserId, req.SessionId); err != nil {
+		l.logger.Error(common.GetUUID(ctx), "l.revoke", req.UserId, err)
+		return l.errWarpper.NewDBServiceError(err)
+	}
+
+	l.logger.Info(common.GetUUID(ctx), "Logout.end", req.UserId, nil)
+	return nil
+}
+
+func (l *loginSessionServiceImpl) GetSessions(ctx context.Context, req *dto.GetLoginSessionsRequest) (*dto.GetLoginSessionsResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "LoginSessionService.GetSessions")
+	defer span.End()
+	sessions, err := l.sessionRepo.GetSessions(ctx, req.UserId)
+	if err != nil {
+		l.logger.Error(common.GetUUID(ctx), "l.sessionRepo.GetSessions", req.UserId, err)
+		return nil, l.errWarpper.NewDBServiceError(err)
+	}
+
+	response := &dto.GetLoginSessionsResponse{
+		Sessions: make([]*dto.LoginSession, 0, len(sessions)),
+	}
+	for _, session := range sessions {
+		response.Sessions = append(response.Sessions, &dto.LoginSession{
+			SessionId:  session.SessionId,
+			CreateTime: session.CreateTime,
+			Ip:         session.Ip,
+			UserAgent:  session.UserAgent,
+			Current:    session.SessionId == req.CurrentSessionId,
+		})
+	}
+	return response, nil
+}
+
+func (l *loginSessionServiceImpl) RevokeSession(ctx context.Context, req *dto.RevokeLoginSessionRequest) (*dto.RevokeLoginSessionResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "LoginSessionService.RevokeSession")
+	defer span.End()
+	ok, err := l.revoke(ctx, req.UserId, req.SessionId)
+	if err != nil {
+		l.logger.Error(common.GetUUID(ctx), "l.revoke", req, err)
+		return nil, l.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		l.logger.Info(common.GetUUID(ctx), "l.revoke", req, nil)
+		return nil, l.errWarpper.NewLoginSessionNotFoundError()
+	}
+
+	l.logger.Info(common.GetUUID(ctx), "RevokeSession.end", req, nil)
+	return &dto.RevokeLoginSessionResponse{Ok: true}, nil
+}
+
+func (l *loginSessionServiceImpl) RevokeAllSessions(ctx context.Context, req *dto.RevokeAllLoginSessionsRequest) (*dto.RevokeAllLoginSessionsResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "LoginSessionService.RevokeAllSessions")
+	defer span.End()
+	sessions, err := l.sessionRepo.GetSessions(ctx, req.UserId)
+	if err != nil {
+		l.logger.Error(common.GetUUID(ctx), "l.sessionRepo.GetSessions", req.UserId, err)
+		return nil, l.errWarpper.NewDBServiceError(err)
+	}
+
+	count := 0
+	for _, session := range sessions {
+		if req.ExceptCurrent && session.SessionId == req.CurrentSessionId {
+			continue
+		}
+		ok, err := l.revoke(ctx, req.UserId, session.SessionId)
+		if err != nil {
+			l.logger.Error(common.GetUUID(ctx), "l.revoke", req.UserId, err)
+			return nil, l.errWarpper.NewDBServiceError(err)
+		} else if ok {
+			count++
+		}
+	}
+
+	l.logger.Info(common.GetUUID(ctx), "RevokeAllSessions.end", req, nil)
+	return &dto.RevokeAllLoginSessionsResponse{Count: count}, nil
+}
+
+type loginConnection struct {
+	conn    DeviceConnection
+	session DeviceSession
+}
+
+// loginConnections remembers which websockets were opened with which login session.
+type loginConnections struct {
+	connections map[string][]loginConnection
+	mu          sync.Mutex
+}
+
+func (l *loginConnections) add(sessionId string, conn DeviceConnection, session DeviceSession) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.connections[sessionId] = append(l.connections[sessionId], loginConnection{conn: conn, session: session})
+}
+
+func (l *loginConnections) remove(sessionId string, conn DeviceConnection) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	connections := l.connections[sessionId]
+	for i, connection := range connections {
+		if connection.conn == conn {
+			connections = append(connections[:i], connections[i+1:]...)
+			break
+		}
+	}
+	if len(connections) == 0 {
+		delete(l.connections, sessionId)
+		return
+	}
+	l.connections[sessionId] = connections
+}
+
+func (l *loginConnections) take(sessionId string) []loginConnection {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	connections := l.connections[sessionId]
+	delete(l.connections, sessionId)
+	return connections
+}
@@ -0,0 +1,295 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"device-communication/src/common"
+	"device-communication/src/config"
+	"device-communication/src/dto"
+	"device-communication/src/dtoError"
+	logger "device-communication/src/log"
+	"device-communication/src/repository"
+	"device-communication/src/telemetry"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	// totpSkew is how many steps a code may be off, for clock drift between server and phone
+	totpSkew = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// totpCode is the RFC 6238 code of a time step with the RFC 4226 defaults (SHA1, 6 digits).
+func totpCode(secret []byte, step int64) string {
+	var message [8]byte
+	binary.BigEndian.PutUint64(message[:], uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(message[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+// matchTotp returns the time step a code belongs to.
+func matchTotp(secret string, code string, now time.Time) (int64, bool) {
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if hmac.Equal([]byte(totpCode(key, step)), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func newTotpSecret() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(buf), nil
+}
+
+func totpProvisioningUri(issuer string, username string, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(issuer + ":" + username)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// newRecoveryCodes returns the codes shown to the user once and the hashes that are stored.
+func newRecoveryCodes(number int) ([]string, []string, error) {
+	codes := make([]string, 0, number)
+	hashes := make([]string, 0, number)
+	for i := 0; i < number; i++ {
+		buf := make([]byte, 10)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(totpEncoding.EncodeToString(buf))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashOneTimeToken(code))
+	}
+	return codes, hashes, nil
+}
+
+// verifySecondFactor accepts a TOTP code or an unused recovery code of an enabled TOTP.
+func verifySecondFactor(ctx context.Context, totpRepo repository.TotpRepository, userId uint64, code string) (bool, error) {
+	totp, ok, err := totpRepo.GetTotp(ctx, userId)
+	if err != nil || !ok || !totp.Enabled {
+		return false, err
+	}
+
+	if step, ok := matchTotp(totp.Secret, code, time.Now()); ok {
+		return totpRepo.UseStep(ctx, userId, step)
+	}
+	return totpRepo.UseRecoveryCode(ctx, userId, hashOneTimeToken(normalizeRecoveryCode(code)), time.Now())
+}
+
+type TwoFactorService interface {
+	EnrollTotp(ctx context.Context, req *dto.EnrollTotpRequest) (*dto.EnrollTotpResponse, *dtoError.ServiceError)
+	ConfirmTotp(ctx context.Context, req *dto.ConfirmTotpRequest) (*dto.ConfirmTotpResponse, *dtoError.ServiceError)
+	DisableTotp(ctx context.Context, req *dto.DisableTotpRequest) *dtoError.ServiceError
+}
+
+type twoFactorServiceImpl struct {
+	totpRepo             repository.TotpRepository
+	userRepo             repository.UserRepository
+	lockout              *loginLockout
+	errWarpper           dtoError.ServiceErrorWarpper
+	ISSUER               string
+	RECOVERY_CODE_NUMBER int
+	logger               logger.Logger
+}
+
+var twoFactor TwoFactorService
+
+func init() {
+	t := config.GlobalConfig.YamlConfig.TwoFactor
+	twoFactor = &twoFactorServiceImpl{
+		totpRepo:             repository.GetTotpRepository(),
+		userRepo:             repository.GetuserRepository(),
+		lockout:              newLoginLockout(),
+		errWarpper:           dtoError.GetServiceErrorWarpper(),
+		ISSUER:               t.Issuer,
+		RECOVERY_CODE_NUMBER: t.RecoveryCodeNumber,
+		logger:               logger.NewInfoLogger(),
+	}
+}
+
+func GetTwoFactorService() TwoFactorService {
+	return twoFactor
+}
+
+func (t *twoFactorServiceImpl) EnrollTotp(ctx context.Context, req *dto.EnrollTotpRequest) (*dto.EnrollTotpResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "TwoFactorService.EnrollTotp")
+	defer span.End()
+	userModel, ok, err := t.userRepo.GetUserById(ctx, req.UserId)
+	if err != nil {
+		t.logger.Error(common.GetUUID(ctx), "t.userRepo.GetUserById", req, err)
+		return nil, t.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		t.logger.Info(common.GetUUID(ctx), "t.userRepo.GetUserById", req, nil)
+		return nil, t.errWarpper.NewUserNotExist(req.UserId)
+	}
+
+	totp, ok, err := t.totpRepo.GetTotp(ctx, req.UserId)
+	if err != nil {
+		t.logger.Error(common.GetUUID(ctx), "t.totpRepo.GetTotp", req, err)
+		return nil, t.errWarpper.NewDBServiceError(err)
+	} else if ok && totp.Enabled {
+		return nil, t.errWarpper.NewTotpAlreadyEnabledError()
+	}
+
+	secret, err := newTotpSecret()
+	if err != nil {
+		t.logger.Error(common.GetUUID(ctx), "newTotpSecret", req, err)
+		return nil, t.errWarpper.NewDBServiceError(err)
+	}
+	if err := t.totpRepo.SavePendingTotp(ctx, req.UserId, secret); err != nil {
+		t.logger.Error(common.GetUUID(ctx), "t.totpRepo.SavePendingTotp", req, err)
+		return nil, t.errWarpper.NewDBServiceError(err)
+	}
+
+	t.logger.Info(common.GetUUID(ctx), "EnrollTotp.end", req, nil)
+	return &dto.EnrollTotpResponse{
+		Secret:          secret,
+		ProvisioningUri: totpProvisioningUri(t.ISSUER, userModel.Username, secret),
+	}, nil
+}
+
+func (t *twoFactorServiceImpl) ConfirmTotp(ctx context.Context, req *dto.ConfirmTotpRequest) (*dto.ConfirmTotpResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "TwoFactorService.ConfirmTotp")
+	defer span.End()
+	data := map[string]any{"user_id": req.UserId}
+	totp, ok, err := t.totpRepo.GetTotp(ctx, req.UserId)
+	if err != nil {
+		t.logger.Error(common.GetUUID(ctx), "t.totpRepo.GetTotp", data, err)
+		return nil, t.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		return nil, t.errWarpper.NewTotpNotEnrolledError()
+	} else if totp.Enabled {
+		return nil, t.errWarpper.NewTotpAlreadyEnabledError()
+	}
+
+	step, ok := matchTotp(totp.Secret, req.Code, time.Now())
+	if !ok {
+		t.logger.Info(common.GetUUID(ctx), "matchTotp", data, nil)
+		return nil, t.errWarpper.NewTwoFactorCodeInvalidError()
+	}
+
+	codes, hashes, err := newRecoveryCodes(t.RECOVERY_CODE_NUMBER)
+	if err != nil {
+		t.logger.Error(common.GetUUID(ctx), "newRecoveryCodes", data, err)
+		return nil, t.errWarpper.NewDBServiceError(err)
+	}
+
+	txContext, tx := repository.SetTxContext(ctx)
+	ok, err = t.totpRepo.EnableTotp(txContext, req.UserId)
+	if err != nil {
+		t.logger.Error(common.GetUUID(ctx), "t.totpRepo.EnableTotp", data, err)
+		tx.Rollback()
+		return nil, t.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		t.logger.Info(common.GetUUID(ctx), "t.totpRepo.EnableTotp", data, nil)
+		tx.Rollback()
+		return nil, t.errWarpper.NewTotpAlreadyEnabledError()
+	}
+
+	if _, err := t.totpRepo.UseStep(txContext, req.UserId, step); err != nil {
+		t.logger.Error(common.GetUUID(ctx), "t.totpRepo.UseStep", data, err)
+		tx.Rollback()
+		return nil, t.errWarpper.NewDBServiceError(err)
+	}
+
+	if err := t.totpRepo.ReplaceRecoveryCodes(txContext, req.UserId, hashes); err != nil {
+		t.logger.Error(common.GetUUID(ctx), "t.totpRepo.ReplaceRecoveryCodes", data, err)
+		tx.Rollback()
+		return nil, t.errWarpper.NewDBServiceError(err)
+	}
+
+	err = tx.Commit().Error
+	if err != nil {
+		t.logger.Error(common.GetUUID(ctx), "tx.Commit", data, err)
+		return nil, t.errWarpper.NewDBCommitServiceError(err)
+	}
+
+	t.logger.Info(common.GetUUID(ctx), "ConfirmTotp.end", data, nil)
+	return &dto.ConfirmTotpResponse{RecoveryCodes: codes}, nil
+}
+
+func (t *twoFactorServiceImpl) DisableTotp(ctx context.Context, req *dto.DisableTotpRequest) *dtoError.ServiceError {
+	ctx, span := telemetry.Start(ctx, "TwoFactorService.DisableTotp")
+	defer span.End()
+	data := map[string]any{"user_id": req.UserId, "ip": req.Ip}
+	userModel, ok, err := t.userRepo.GetUserById(ctx, req.UserId)
+	if err != nil {
+		t.logger.Error(common.GetUUID(ctx), "t.userRepo.GetUserById", data, err)
+		return t.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		t.logger.Info(common.GetUUID(ctx), "t.userRepo.GetUserById", data, nil)
+		return t.errWarpper.NewUserNotExist(req.UserId)
+	}
+
+	// a stolen session must not become a way around the login lockout
+	if serviceErr := t.lockout.check(ctx, userModel.Username, req.Ip); serviceErr != nil {
+		t.logger.Info(common.GetUUID(ctx), "t.lockout.check", data, serviceErr.InternalError)
+		return serviceErr
+	}
+
+	password := newPasswordByHashed(userModel.Password)
+	if !password.Check(req.Password) {
+		t.logger.Info(common.GetUUID(ctx), "password.Check", data, nil)
+		t.lockout.fail(ctx, userModel.Username, req.Ip)
+		return t.errWarpper.NewLoginFailedServiceError(nil)
+	}
+
+	ok, err = verifySecondFactor(ctx, t.totpRepo, req.UserId, req.Code)
+	if err != nil {
+		t.logger.Error(common.GetUUID(ctx), "verifySecondFactor", data, err)
+		return t.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		t.logger.Info(common.GetUUID(ctx), "verifySecondFactor", data, nil)
+		t.lockout.fail(ctx, userModel.Username, req.Ip)
+		return t.errWarpper.NewTwoFactorCodeInvalidError()
+	}
+	t.lockout.succeed(ctx, userModel.Username)
+
+	txContext, tx := repository.SetTxContext(ctx)
+	if err := t.totpRepo.DeleteTotp(txContext, req.UserId); err != nil {
+		t.logger.Error(common.GetUUID(ctx), "t.totpRepo.DeleteTotp", data, err)
+		tx.Rollback()
+		return t.errWarpper.NewDBServiceError(err)
+	}
+
+	err = tx.Commit().Error
+	if err != nil {
+		t.logger.Error(common.GetUUID(ctx), "tx.Commit", data, err)
+		return t.errWarpper.NewDBCommitServiceError(err)
+	}
+
+	t.logger.Info(common.GetUUID(ctx), "DisableTotp.end", data, nil)
+	return nil
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"device-communication/src/dto"
+	"device-communication/src/dtoError"
+	logger "device-communication/src/log"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDisableTotpCountsWrongPassword(t *testing.T) {
+	_, userRepo, totpRepo, lockoutRepo := newTestUserService(t)
+	s := &twoFactorServiceImpl{
+		totpRepo:   totpRepo,
+		userRepo:   userRepo,
+		lockout:    newTestLockout(lockoutRepo),
+		errWarpper: dtoError.GetServiceErrorWarpper(),
+		logger:     logger.NewInfoLogger(),
+	}
+
+	req := &dto.DisableTotpRequest{UserId: 1, Password: "guess", Code: "000000", Ip: "10.0.0.3"}
+	serviceErr := s.DisableTotp(context.Background(), req)
+	if serviceErr == nil || serviceErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("DisableTotp() = %v, want a login failure", serviceErr)
+	}
+	if got := lockoutRepo.accountFailures("owner"); got != 1 {
+		t.Fatalf("account failures = %d, want 1", got)
+	}
+	if got := lockoutRepo.failures[ipLockSubject("10.0.0.3")]; got != 1 {
+		t.Fatalf("ip failures = %d, want 1", got)
+	}
+
+	// a locked account is refused before the password is looked at
+	lockoutRepo.locks[accountLockSubject("owner")] = time.Minute
+	req.Password = "current-password"
+	serviceErr = s.DisableTotp(context.Background(), req)
+	if serviceErr == nil || serviceErr.RetryAfter == 0 {
+		t.Fatalf("DisableTotp() = %v, want the lockout", serviceErr)
+	}
+}
@@ -2,11 +2,22 @@ package service
 
 import (
 	"context"
+	"device-communication/src/common"
+	"device-communication/src/config"
 	"device-communication/src/dto"
 	"device-communication/src/dtoError"
 	logger "device-communication/src/log"
+	"device-communication/src/mail"
+	"device-communication/src/model"
 	"device-communication/src/repository"
+	"device-communication/src/telemetry"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -14,20 +25,42 @@ import (
 type UserService interface {
 	UserRegisterService(ctx context.Context, req *dto.UserRegisterRequest) (*dto.UserRegisterResponse, *dtoError.ServiceError)
 	UserLoginService(ctx context.Context, req *dto.UserLoginRequest) (*dto.UserLoginResponse, *dtoError.ServiceError)
+	DeviceLoginService(ctx context.Context, req *dto.UserLoginRequest) (*dto.UserLoginResponse, *dtoError.ServiceError)
 	ResetPasswordService(ctx context.Context, req *dto.ResetPasswordRequest) *dtoError.ServiceError
+	ForgotPasswordService(ctx context.Context, req *dto.ForgotPasswordRequest) *dtoError.ServiceError
+	ConfirmPasswordResetService(ctx context.Context, req *dto.ConfirmPasswordResetRequest) *dtoError.ServiceError
+	VerifyLoginChallengeService(ctx context.Context, req *dto.VerifyLoginChallengeRequest) (*dto.UserLoginResponse, *dtoError.ServiceError)
+	VerifyEmailService(ctx context.Context, req *dto.VerifyEmailRequest) *dtoError.ServiceError
+	ResendVerificationService(ctx context.Context, req *dto.ResendVerificationRequest) *dtoError.ServiceError
+	GetProfileService(ctx context.Context, req *dto.GetProfileRequest) (*dto.ProfileResponse, *dtoError.ServiceError)
+	UpdateProfileService(ctx context.Context, req *dto.UpdateProfileRequest) (*dto.ProfileResponse, *dtoError.ServiceError)
 }
 
 type userServiceImpl struct {
-	userRepo   repository.UserRepository
-	errWarpper dtoError.ServiceErrorWarpper
-	logger     logger.Logger
+	userRepo      repository.UserRepository
+	tokenRepo     repository.OneTimeTokenRepository
+	totpRepo      repository.TotpRepository
+	rateLimitRepo repository.RateLimitRepository
+	mailSender    mail.Sender
+	lockout       *loginLockout
+	loginCache    *loginCache
+	errWarpper    dtoError.ServiceErrorWarpper
+	logger        logger.Logger
 }
 
 var user UserService
 
 func init() {
+	l := config.GlobalConfig.YamlConfig.LoginLockout
 	user = &userServiceImpl{
-		userRepo:   repository.GetuserRepository(),
+		userRepo:      repository.GetuserRepository(),
+		tokenRepo:     repository.GetOneTimeTokenRepository(),
+		totpRepo:      repository.GetTotpRepository(),
+		rateLimitRepo: repository.GetRateLimitRepository(),
+		mailSender:    mail.GetSender(),
+		lockout:       newLoginLockout(),
+		// a failed password is remembered for the whole lockout window, so it is counted once per window
+		loginCache: newLoginCache(time.Duration(l.DeviceLoginCache)*time.Second, time.Duration(l.Window)*time.Second),
 		errWarpper: dtoError.GetServiceErrorWarpper(),
 		logger:     logger.NewInfoLogger(),
 	}
@@ -67,6 +100,8 @@ func (p *Password) Check(password string) bool {
 }
 
 func (u *userServiceImpl) UserRegisterService(ctx context.Context, req *dto.UserRegisterRequest) (*dto.UserRegisterResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "UserService.UserRegisterService")
+	defer span.End()
 	password, err := newPasswordByRaw(req.Password)
 	data := map[string]any{
 		"username": req.Username,
@@ -80,37 +115,175 @@ func (u *userServiceImpl) UserRegisterService(ctx context.Context, req *dto.User
 
 	userModel, ok, err := u.userRepo.UserRegister(ctx, req.Username, password.Hashed(), req.Name, req.Email)
 	if err != nil {
-		u.logger.Error("", "u.userRepo.UserRegister", data, err)
+		u.logger.Error(common.GetUUID(ctx), "u.userRepo.UserRegister", data, err)
 		return nil, u.errWarpper.NewDBServiceError(err)
 	} else if !ok {
-		u.logger.Info("", "u.userRepo.UserRegister", data, nil)
+		u.logger.Info(common.GetUUID(ctx), "u.userRepo.UserRegister", data, nil)
 		return nil, u.errWarpper.NewUserHasRegisterdError(req.Username)
 	}
 
-	u.logger.Info("", "UserRegisterService.end", data, err)
+	if err := u.sendVerification(ctx, userModel); err != nil {
+		// the account is created anyway, the user can ask for another mail
+		u.logger.Error(common.GetUUID(ctx), "u.sendVerification", data, err)
+	}
+
+	u.logger.Info(common.GetUUID(ctx), "UserRegisterService.end", data, nil)
 	return &dto.UserRegisterResponse{ID: userModel.Id}, nil
 }
 
 func (u *userServiceImpl) UserLoginService(ctx context.Context, req *dto.UserLoginRequest) (*dto.UserLoginResponse, *dtoError.ServiceError) {
-	userModel, exist, err := u.userRepo.SelectUserByName(ctx, req.Username)
-	data := map[string]any{"username": req.Username}
+	ctx, span := telemetry.Start(ctx, "UserService.UserLoginService")
+	defer span.End()
+	data := map[string]any{"username": req.Username, "ip": req.Ip}
+	if serviceErr := u.lockout.check(ctx, req.Username, req.Ip); serviceErr != nil {
+		u.logger.Info(common.GetUUID(ctx), "u.lockout.check", data, serviceErr.InternalError)
+		return nil, serviceErr
+	}
 
+	userModel, exist, err := u.userRepo.SelectUserByName(ctx, req.Username)
 	if err != nil {
-		u.logger.Error("", "u.userRepo.SelectUserByName", data, err)
+		u.logger.Error(common.GetUUID(ctx), "u.userRepo.SelectUserByName", data, err)
 		return nil, u.errWarpper.NewDBServiceError(err)
 	} else if !exist {
-		u.logger.Info("", "u.userRepo.SelectUserByName", data, nil)
+		u.logger.Info(common.GetUUID(ctx), "u.userRepo.SelectUserByName", data, nil)
+		u.lockout.fail(ctx, req.Username, req.Ip)
 		return nil, u.errWarpper.NewLoginFailedServiceError(nil)
 	}
 
 	password := newPasswordByHashed(userModel.Password)
 	passwordMatch := password.Check(req.Password)
 	if !passwordMatch {
-		u.logger.Info("", "password.Check", data, nil)
+		u.logger.Info(common.GetUUID(ctx), "password.Check", data, nil)
+		u.lockout.fail(ctx, req.Username, req.Ip)
 		return nil, u.errWarpper.NewLoginFailedServiceError(err)
 	}
 
-	u.logger.Info("", "UserLoginService.end", data, nil)
+	totp, ok, err := u.totpRepo.GetTotp(ctx, userModel.Id)
+	if err != nil {
+		u.logger.Error(common.GetUUID(ctx), "u.totpRepo.GetTotp", data, err)
+		return nil, u.errWarpper.NewDBServiceError(err)
+	} else if ok && totp.Enabled {
+		// the failure counter is only cleared once the second step passes too
+		challenge, hash, err := newOneTimeToken()
+		if err != nil {
+			u.logger.Error(common.GetUUID(ctx), "newOneTimeToken", data, err)
+			return nil, u.errWarpper.NewDBServiceError(err)
+		}
+		ttl := time.Duration(config.GlobalConfig.YamlConfig.TwoFactor.ChallengeTTL) * time.Second
+		err = u.tokenRepo.SaveToken(ctx, repository.TokenPurposeLoginChallenge, userModel.Id, hash, ttl)
+		if err != nil {
+			u.logger.Error(common.GetUUID(ctx), "u.tokenRepo.SaveToken", data, err)
+			return nil, u.errWarpper.NewDBServiceError(err)
+		}
+
+		u.logger.Info(common.GetUUID(ctx), "UserLoginService.challenge", data, nil)
+		return &dto.UserLoginResponse{
+			ID:        userModel.Id,
+			Username:  userModel.Username,
+			Challenge: challenge,
+		}, nil
+	}
+	u.lockout.succeed(ctx, req.Username)
+
+	u.logger.Info(common.GetUUID(ctx), "UserLoginService.end", data, nil)
+	return &dto.UserLoginResponse{
+		ID:       userModel.Id,
+		Username: userModel.Username,
+	}, nil
+}
+
+// DeviceLoginService is the password login of mqtt and grpc, which authenticate on every connect or call.
+// A cached verdict is trusted while the stored password hash is unchanged and two-factor authentication is off,
+// anything else falls back to UserLoginService.
+func (u *userServiceImpl) DeviceLoginService(ctx context.Context, req *dto.UserLoginRequest) (*dto.UserLoginResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "UserService.DeviceLoginService")
+	defer span.End()
+	data := map[string]any{"username": req.Username, "ip": req.Ip}
+	if entry, ok := u.loginCache.get(req.Username, req.Password); ok {
+		userModel, exist, err := u.userRepo.SelectUserByName(ctx, req.Username)
+		if err != nil {
+			u.logger.Error(common.GetUUID(ctx), "u.userRepo.SelectUserByName", data, err)
+			return nil, u.errWarpper.NewDBServiceError(err)
+		}
+		var hashed string
+		if exist {
+			hashed = userModel.Password
+		}
+
+		if hashed == entry.hashed && !entry.verified {
+			u.logger.Info(common.GetUUID(ctx), "u.loginCache.get", data, nil)
+			return nil, u.errWarpper.NewLoginFailedServiceError(nil)
+		}
+		if hashed == entry.hashed && userModel.Id == entry.userId {
+			totp, ok, err := u.totpRepo.GetTotp(ctx, userModel.Id)
+			if err != nil {
+				u.logger.Error(common.GetUUID(ctx), "u.totpRepo.GetTotp", data, err)
+				return nil, u.errWarpper.NewDBServiceError(err)
+			} else if !ok || !totp.Enabled {
+				return &dto.UserLoginResponse{ID: userModel.Id, Username: userModel.Username}, nil
+			}
+		}
+		u.loginCache.remove(req.Username, req.Password)
+	}
+
+	res, serviceErr := u.UserLoginService(ctx, req)
+	if serviceErr != nil && serviceErr.StatusCode != http.StatusUnauthorized {
+		return nil, serviceErr
+	} else if serviceErr == nil && res.Challenge != "" {
+		return res, nil
+	}
+
+	userModel, exist, err := u.userRepo.SelectUserByName(ctx, req.Username)
+	if err != nil {
+		u.logger.Error(common.GetUUID(ctx), "u.userRepo.SelectUserByName", data, err)
+	} else if !exist {
+		u.loginCache.put(req.Username, req.Password, 0, "", false)
+	} else {
+		u.loginCache.put(req.Username, req.Password, userModel.Id, userModel.Password, serviceErr == nil)
+	}
+	return res, serviceErr
+}
+
+// VerifyLoginChallengeService is the second login step, a challenge takes one code only.
+func (u *userServiceImpl) VerifyLoginChallengeService(ctx context.Context, req *dto.VerifyLoginChallengeRequest) (*dto.UserLoginResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "UserService.VerifyLoginChallengeService")
+	defer span.End()
+	userId, ok, err := u.tokenRepo.TakeToken(ctx, repository.TokenPurposeLoginChallenge, hashOneTimeToken(req.Challenge))
+	if err != nil {
+		u.logger.Error(common.GetUUID(ctx), "u.tokenRepo.TakeToken", nil, err)
+		return nil, u.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		u.logger.Info(common.GetUUID(ctx), "u.tokenRepo.TakeToken", nil, nil)
+		return nil, u.errWarpper.NewLoginChallengeInvalidError()
+	}
+
+	data := map[string]any{"user_id": userId, "ip": req.Ip}
+	userModel, ok, err := u.userRepo.GetUserById(ctx, userId)
+	if err != nil {
+		u.logger.Error(common.GetUUID(ctx), "u.userRepo.GetUserById", data, err)
+		return nil, u.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		u.logger.Info(common.GetUUID(ctx), "u.userRepo.GetUserById", data, nil)
+		return nil, u.errWarpper.NewLoginChallengeInvalidError()
+	}
+
+	if serviceErr := u.lockout.check(ctx, userModel.Username, req.Ip); serviceErr != nil {
+		u.logger.Info(common.GetUUID(ctx), "u.lockout.check", data, serviceErr.InternalError)
+		return nil, serviceErr
+	}
+
+	ok, err = verifySecondFactor(ctx, u.totpRepo, userId, req.Code)
+	if err != nil {
+		u.logger.Error(common.GetUUID(ctx), "verifySecondFactor", data, err)
+		return nil, u.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		u.logger.Info(common.GetUUID(ctx), "verifySecondFactor", data, nil)
+		u.lockout.fail(ctx, userModel.Username, req.Ip)
+		return nil, u.errWarpper.NewTwoFactorCodeInvalidError()
+	}
+	u.lockout.succeed(ctx, userModel.Username)
+
+	u.logger.Info(common.GetUUID(ctx), "VerifyLoginChallengeService.end", data, nil)
 	return &dto.UserLoginResponse{
 		ID:       userModel.Id,
 		Username: userModel.Username,
@@ -118,49 +291,366 @@ func (u *userServiceImpl) UserLoginService(ctx context.Context, req *dto.UserLog
 }
 
 func (u *userServiceImpl) ResetPasswordService(ctx context.Context, req *dto.ResetPasswordRequest) *dtoError.ServiceError {
+	ctx, span := telemetry.Start(ctx, "UserService.ResetPasswordService")
+	defer span.End()
+	data := map[string]any{"username": req.Username, "ip": req.Ip}
+	if serviceErr := u.lockout.check(ctx, req.Username, req.Ip); serviceErr != nil {
+		u.logger.Info(common.GetUUID(ctx), "u.lockout.check", data, serviceErr.InternalError)
+		return serviceErr
+	}
+
 	txContext, tx := repository.SetTxContext(ctx)
 	user, ok, err := u.userRepo.SelectUserByName(txContext, req.Username)
-	data := map[string]any{"username": req.Username}
-
 	if err != nil {
-		u.logger.Error("", "u.userRepo.SelectUserByName", data, err)
+		u.logger.Error(common.GetUUID(ctx), "u.userRepo.SelectUserByName", data, err)
 		tx.Rollback()
 		return u.errWarpper.NewDBServiceError(err)
 	} else if !ok {
-		u.logger.Info("", "u.userRepo.SelectUserByName", data, nil)
+		u.logger.Info(common.GetUUID(ctx), "u.userRepo.SelectUserByName", data, nil)
 		tx.Rollback()
+		u.lockout.fail(ctx, req.Username, req.Ip)
 		return u.errWarpper.NewRessetPasswordServiceError()
 	}
 
 	password := newPasswordByHashed(user.Password)
 	passwordMatch := password.Check(req.Password)
 	if !passwordMatch {
-		u.logger.Info("", "password.Check", data, nil)
+		u.logger.Info(common.GetUUID(ctx), "password.Check", data, nil)
+		tx.Rollback()
+		u.lockout.fail(ctx, req.Username, req.Ip)
 		return u.errWarpper.NewLoginFailedServiceError(err)
 	}
+	u.lockout.succeed(ctx, req.Username)
 
 	newPassword, err := newPasswordByRaw(req.NewPassword)
 	if err != nil {
-		u.logger.Error("", "newPasswordByRaw", data, err)
+		u.logger.Error(common.GetUUID(ctx), "newPasswordByRaw", data, err)
 		tx.Rollback()
 		return u.errWarpper.NewPasswordInvaildError(err)
 	}
 
 	ok, err = u.userRepo.UpdatePassword(txContext, user.Id, newPassword.Hashed())
 	if err != nil {
-		u.logger.Error("", "u.userRepo.UpdatePassword", data, err)
+		u.logger.Error(common.GetUUID(ctx), "u.userRepo.UpdatePassword", data, err)
 		tx.Rollback()
 		return u.errWarpper.NewDBServiceError(err)
 	} else if !ok {
-		u.logger.Info("", "u.userRepo.UpdatePassword", data, nil)
+		u.logger.Info(common.GetUUID(ctx), "u.userRepo.UpdatePassword", data, nil)
 		tx.Rollback()
 		return u.errWarpper.NewDBNoAffectedServiceError()
 	}
 
 	err = tx.Commit().Error
 	if err != nil {
-		u.logger.Error("", "tx.Commit", data, err)
+		u.logger.Error(common.GetUUID(ctx), "tx.Commit", data, err)
 		return u.errWarpper.NewDBCommitServiceError(err)
 	}
 	return nil
 }
+
+// ForgotPasswordService answers the same whether the user exists or not, the token only goes to the registered email.
+func (u *userServiceImpl) ForgotPasswordService(ctx context.Context, req *dto.ForgotPasswordRequest) *dtoError.ServiceError {
+	ctx, span := telemetry.Start(ctx, "UserService.ForgotPasswordService")
+	defer span.End()
+	r := config.GlobalConfig.YamlConfig.PasswordReset
+	window := time.Duration(r.Window) * time.Second
+	data := map[string]any{"username": req.Username, "ip": req.Ip}
+
+	limits := map[string]int64{
+		"password_reset:ip:" + req.Ip:                          r.MaxRequestPerIp,
+		"password_reset:user:" + strings.ToLower(req.Username): r.MaxRequestPerAccount,
+	}
+	for key, limit := range limits {
+		retryAfter, err := hitRateLimit(ctx, u.rateLimitRepo, key, limit, window)
+		if err != nil {
+			u.logger.Error(common.GetUUID(ctx), "hitRateLimit", data, err)
+			return u.errWarpper.NewDBServiceError(err)
+		} else if retryAfter > 0 {
+			u.logger.Info(common.GetUUID(ctx), "hitRateLimit", data, nil)
+			return u.errWarpper.NewTooManyRequestsError(retryAfter)
+		}
+	}
+
+	userModel, exist, err := u.userRepo.SelectUserByName(ctx, req.Username)
+	if err != nil {
+		u.logger.Error(common.GetUUID(ctx), "u.userRepo.SelectUserByName", data, err)
+		return u.errWarpper.NewDBServiceError(err)
+	} else if !exist {
+		u.logger.Info(common.GetUUID(ctx), "u.userRepo.SelectUserByName", data, nil)
+		return nil
+	}
+
+	token, hash, err := newOneTimeToken()
+	if err != nil {
+		u.logger.Error(common.GetUUID(ctx), "newOneTimeToken", data, err)
+		return u.errWarpper.NewDBServiceError(err)
+	}
+	ttl := time.Duration(r.TokenTTL) * time.Second
+	err = u.tokenRepo.SaveToken(ctx, repository.TokenPurposePasswordReset, userModel.Id, hash, ttl)
+	if err != nil {
+		u.logger.Error(common.GetUUID(ctx), "u.tokenRepo.SaveToken", data, err)
+		return u.errWarpper.NewDBServiceError(err)
+	}
+
+	message := &mail.Message{
+		To:      userModel.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\r\n\r\nOpen the link below within %d minutes to set a new password:\r\n%s?token=%s\r\n\r\nIf you did not ask for it, ignore this mail.",
+			userModel.Name, r.TokenTTL/60, r.Url, url.QueryEscape(token)),
+	}
+	// sent in the background so the response time does not tell whether the user exists
+	u.sendMail(ctx, message)
+
+	u.logger.Info(common.GetUUID(ctx), "ForgotPasswordService.end", data, nil)
+	return nil
+}
+
+func (u *userServiceImpl) ConfirmPasswordResetService(ctx context.Context, req *dto.ConfirmPasswordResetRequest) *dtoError.ServiceError {
+	ctx, span := telemetry.Start(ctx, "UserService.ConfirmPasswordResetService")
+	defer span.End()
+	// checked before the token is used up so a weak password can be retried
+	newPassword, err := newPasswordByRaw(req.NewPassword)
+	if err != nil {
+		u.logger.Info(common.GetUUID(ctx), "newPasswordByRaw", nil, err)
+		return u.errWarpper.NewPasswordInvaildError(err)
+	}
+
+	userId, ok, err := u.tokenRepo.TakeToken(ctx, repository.TokenPurposePasswordReset, hashOneTimeToken(req.Token))
+	if err != nil {
+		u.logger.Error(common.GetUUID(ctx), "u.tokenRepo.TakeToken", nil, err)
+		return u.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		u.logger.Info(common.GetUUID(ctx), "u.tokenRepo.TakeToken", nil, nil)
+		return u.errWarpper.NewPasswordResetTokenInvalidError()
+	}
+
+	data := map[string]any{"user_id": userId}
+	ok, err = u.userRepo.UpdatePassword(ctx, userId, newPassword.Hashed())
+	if err != nil {
+		u.logger.Error(common.GetUUID(ctx), "u.userRepo.UpdatePassword", data, err)
+		return u.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		u.logger.Info(common.GetUUID(ctx), "u.userRepo.UpdatePassword", data, nil)
+		return u.errWarpper.NewPasswordResetTokenInvalidError()
+	}
+
+	// whoever knew the old password is logged out
+	_, serviceErr := GetLoginSessionService().RevokeAllSessions(ctx, &dto.RevokeAllLoginSessionsRequest{UserId: userId})
+	if serviceErr != nil {
+		return serviceErr
+	}
+
+	u.logger.Info(common.GetUUID(ctx), "ConfirmPasswordResetService.end", data, nil)
+	return nil
+}
+
+func (u *userServiceImpl) sendMail(ctx context.Context, message *mail.Message) {
+	requestId := common.GetUUID(ctx)
+	go func() {
+		if err := u.mailSender.Send(context.Background(), message); err != nil {
+			u.logger.Error(requestId, "u.mailSender.Send", message.To, err)
+		}
+	}()
+}
+
+func (u *userServiceImpl) sendVerification(ctx context.Context, userModel *model.User) error {
+	v := config.GlobalConfig.YamlConfig.EmailVerification
+	token, hash, err := newOneTimeToken()
+	if err != nil {
+		return err
+	}
+	ttl := time.Duration(v.TokenTTL) * time.Second
+	err = u.tokenRepo.SaveToken(ctx, repository.TokenPurposeEmailVerification, userModel.Id, hash, ttl)
+	if err != nil {
+		return err
+	}
+
+	u.sendMail(ctx, &mail.Message{
+		To:      userModel.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hi %s,\r\n\r\nOpen the link below within %d hours to verify your email:\r\n%s?token=%s",
+			userModel.Name, v.TokenTTL/3600, v.Url, url.QueryEscape(token)),
+	})
+	return nil
+}
+
+func (u *userServiceImpl) VerifyEmailService(ctx context.Context, req *dto.VerifyEmailRequest) *dtoError.ServiceError {
+	ctx, span := telemetry.Start(ctx, "UserService.VerifyEmailService")
+	defer span.End()
+	userId, ok, err := u.tokenRepo.TakeToken(ctx, repository.TokenPurposeEmailVerification, hashOneTimeToken(req.Token))
+	if err != nil {
+		u.logger.Error(common.GetUUID(ctx), "u.tokenRepo.TakeToken", nil, err)
+		return u.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		u.logger.Info(common.GetUUID(ctx), "u.tokenRepo.TakeToken", nil, nil)
+		return u.errWarpper.NewEmailVerificationTokenInvalidError()
+	}
+
+	data := map[string]any{"user_id": userId}
+	ok, err = u.userRepo.VerifyEmail(ctx, userId)
+	if err != nil {
+		u.logger.Error(common.GetUUID(ctx), "u.userRepo.VerifyEmail", data, err)
+		return u.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		u.logger.Info(common.GetUUID(ctx), "u.userRepo.VerifyEmail", data, nil)
+		return u.errWarpper.NewEmailVerificationTokenInvalidError()
+	}
+
+	u.logger.Info(common.GetUUID(ctx), "VerifyEmailService.end", data, nil)
+	return nil
+}
+
+func (u *userServiceImpl) ResendVerificationService(ctx context.Context, req *dto.ResendVerificationRequest) *dtoError.ServiceError {
+	ctx, span := telemetry.Start(ctx, "UserService.ResendVerificationService")
+	defer span.End()
+	userModel, ok, err := u.userRepo.GetUserById(ctx, req.UserId)
+	if err != nil {
+		u.logger.Error(common.GetUUID(ctx), "u.userRepo.GetUserById", req, err)
+		return u.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		u.logger.Info(common.GetUUID(ctx), "u.userRepo.GetUserById", req, nil)
+		return u.errWarpper.NewUserNotExist(req.UserId)
+	} else if userModel.EmailVerified {
+		return u.errWarpper.NewEmailAlreadyVerifiedError()
+	}
+
+	v := config.GlobalConfig.YamlConfig.EmailVerification
+	key := fmt.Sprintf("email_verification:user:%d", req.UserId)
+	retryAfter, err := hitRateLimit(ctx, u.rateLimitRepo, key, v.MaxResendPerAccount, time.Duration(v.Window)*time.Second)
+	if err != nil {
+		u.logger.Error(common.GetUUID(ctx), "hitRateLimit", req, err)
+		return u.errWarpper.NewDBServiceError(err)
+	} else if retryAfter > 0 {
+		u.logger.Info(common.GetUUID(ctx), "hitRateLimit", req, nil)
+		return u.errWarpper.NewTooManyRequestsError(retryAfter)
+	}
+
+	if err := u.sendVerification(ctx, userModel); err != nil {
+		u.logger.Error(common.GetUUID(ctx), "u.sendVerification", req, err)
+		return u.errWarpper.NewDBServiceError(err)
+	}
+
+	u.logger.Info(common.GetUUID(ctx), "ResendVerificationService.end", req, nil)
+	return nil
+}
+
+func newProfileResponse(userModel *model.User) *dto.ProfileResponse {
+	return &dto.ProfileResponse{
+		ID:            userModel.Id,
+		Username:      userModel.Username,
+		Name:          userModel.Name,
+		Email:         userModel.Email,
+		EmailVerified: userModel.EmailVerified,
+		CreateTime:    userModel.CreatedAt,
+	}
+}
+
+func (u *userServiceImpl) GetProfileService(ctx context.Context, req *dto.GetProfileRequest) (*dto.ProfileResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "UserService.GetProfileService")
+	defer span.End()
+	userModel, ok, err := u.userRepo.GetUserById(ctx, req.UserId)
+	if err != nil {
+		u.logger.Error(common.GetUUID(ctx), "u.userRepo.GetUserById", req, err)
+		return nil, u.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		u.logger.Info(common.GetUUID(ctx), "u.userRepo.GetUserById", req, nil)
+		return nil, u.errWarpper.NewUserNotExist(req.UserId)
+	}
+	return newProfileResponse(userModel), nil
+}
+
+func (u *userServiceImpl) UpdateProfileService(ctx context.Context, req *dto.UpdateProfileRequest) (*dto.ProfileResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "UserService.UpdateProfileService")
+	defer span.End()
+	data := map[string]any{"user_id": req.UserId}
+	userModel, ok, err := u.userRepo.GetUserById(ctx, req.UserId)
+	if err != nil {
+		u.logger.Error(common.GetUUID(ctx), "u.userRepo.GetUserById", data, err)
+		return nil, u.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		u.logger.Info(common.GetUUID(ctx), "u.userRepo.GetUserById", data, nil)
+		return nil, u.errWarpper.NewUserNotExist(req.UserId)
+	}
+
+	fields := map[string]interface{}{}
+	if req.Username != nil && *req.Username != userModel.Username {
+		// the admin filter trusts the username of the session, so admin names can not be taken over
+		if slices.Contains(config.GlobalConfig.YamlConfig.Admin.Usernames, *req.Username) {
+			return nil, u.errWarpper.NewUsernameExist(*req.Username)
+		}
+		used, err := u.userRepo.CheckUsernameUsed(ctx, *req.Username)
+		if err != nil {
+			u.logger.Error(common.GetUUID(ctx), "u.userRepo.CheckUsernameUsed", data, err)
+			return nil, u.errWarpper.NewDBServiceError(err)
+		} else if used {
+			u.logger.Info(common.GetUUID(ctx), "u.userRepo.CheckUsernameUsed", data, nil)
+			return nil, u.errWarpper.NewUsernameExist(*req.Username)
+		}
+		fields["username"] = *req.Username
+		data["username"] = *req.Username
+		userModel.Username = *req.Username
+	}
+	if req.Name != nil && *req.Name != userModel.Name {
+		fields["name"] = *req.Name
+		userModel.Name = *req.Name
+	}
+	emailChanged := req.Email != nil && !strings.EqualFold(*req.Email, userModel.Email)
+	if emailChanged {
+		// a new address is verified again, and sending to it shares the limit of resending
+		v := config.GlobalConfig.YamlConfig.EmailVerification
+		key := fmt.Sprintf("email_verification:user:%d", req.UserId)
+		retryAfter, err := hitRateLimit(ctx, u.rateLimitRepo, key, v.MaxResendPerAccount, time.Duration(v.Window)*time.Second)
+		if err != nil {
+			u.logger.Error(common.GetUUID(ctx), "hitRateLimit", data, err)
+			return nil, u.errWarpper.NewDBServiceError(err)
+		} else if retryAfter > 0 {
+			u.logger.Info(common.GetUUID(ctx), "hitRateLimit", data, nil)
+			return nil, u.errWarpper.NewTooManyRequestsError(retryAfter)
+		}
+		fields["email"] = *req.Email
+		fields["email_verified"] = false
+		data["email"] = *req.Email
+		userModel.Email = *req.Email
+		userModel.EmailVerified = false
+	}
+	if len(fields) == 0 {
+		return newProfileResponse(userModel), nil
+	}
+
+	ok, err = u.userRepo.UpdateProfile(ctx, req.UserId, fields)
+	if err != nil {
+		u.logger.Error(common.GetUUID(ctx), "u.userRepo.UpdateProfile", data, err)
+		return nil, u.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		u.logger.Info(common.GetUUID(ctx), "u.userRepo.UpdateProfile", data, nil)
+		return nil, u.errWarpper.NewUserNotExist(req.UserId)
+	}
+
+	if emailChanged {
+		// replaces the token of the old address
+		if err := u.sendVerification(ctx, userModel); err != nil {
+			u.logger.Error(common.GetUUID(ctx), "u.sendVerification", data, err)
+		}
+	}
+
+	u.logger.Info(common.GetUUID(ctx), "UpdateProfileService.end", data, nil)
+	return newProfileResponse(userModel), nil
+}
+
+// checkEmailVerified lets an unverified user through only when the config allows it.
+func checkEmailVerified(ctx context.Context, userRepo repository.UserRepository, userId uint64, allowUnverified bool) *dtoError.ServiceError {
+	if allowUnverified {
+		return nil
+	}
+
+	errWarpper := dtoError.GetServiceErrorWarpper()
+	userModel, ok, err := userRepo.GetUserById(ctx, userId)
+	if err != nil {
+		return errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		return errWarpper.NewUserNotExist(userId)
+	} else if !userModel.EmailVerified {
+		return errWarpper.NewEmailNotVerifiedError()
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"device-communication/src/dto"
+	"device-communication/src/dtoError"
+	logger "device-communication/src/log"
+	"device-communication/src/model"
+	"device-communication/src/repository"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakeUserRepository struct {
+	repository.UserRepository
+	users map[string]*model.User
+}
+
+func (f *fakeUserRepository) SelectUserByName(ctx context.Context, username string) (*model.User, bool, error) {
+	userModel, ok := f.users[username]
+	return userModel, ok, nil
+}
+
+func (f *fakeUserRepository) GetUserById(ctx context.Context, ID uint64) (*model.User, bool, error) {
+	for _, userModel := range f.users {
+		if userModel.Id == ID {
+			return userModel, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+type fakeTotpRepository struct {
+	repository.TotpRepository
+	enabled map[uint64]bool
+}
+
+func (f *fakeTotpRepository) GetTotp(ctx context.Context, userId uint64) (*model.UserTotp, bool, error) {
+	enabled, ok := f.enabled[userId]
+	return &model.UserTotp{UserId: userId, Enabled: enabled}, ok, nil
+}
+
+type fakeTokenRepository struct {
+	repository.OneTimeTokenRepository
+	deleted []uint64
+}
+
+func (f *fakeTokenRepository) SaveToken(ctx context.Context, purpose string, userId uint64, tokenHash string, ttl time.Duration) error {
+	return nil
+}
+
+func (f *fakeTokenRepository) DeleteTokens(ctx context.Context, userId uint64) error {
+	f.deleted = append(f.deleted, userId)
+	return nil
+}
+
+// fakeLockoutRepository counts failures without ever locking, the tests look at the counters.
+type fakeLockoutRepository struct {
+	repository.LockoutRepository
+	mu       sync.Mutex
+	failures map[string]int64
+	locks    map[string]time.Duration
+	clears   int
+}
+
+func (f *fakeLockoutRepository) GetLock(ctx context.Context, subject string) (time.Duration, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.locks[subject], nil
+}
+
+func (f *fakeLockoutRepository) AddFailure(ctx context.Context, subject string, window time.Duration) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures[subject]++
+	return f.failures[subject], nil
+}
+
+func (f *fakeLockoutRepository) ClearFailures(ctx context.Context, subject string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.clears++
+	delete(f.failures, subject)
+	return nil
+}
+
+func (f *fakeLockoutRepository) accountFailures(username string) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.failures[accountLockSubject(username)]
+}
+
+func newTestLockout(repo repository.LockoutRepository) *loginLockout {
+	return &loginLockout{
+		lockoutRepo:             repo,
+		errWarpper:              dtoError.GetServiceErrorWarpper(),
+		logger:                  logger.NewInfoLogger(),
+		MAX_FAILURE_PER_ACCOUNT: 1 << 20,
+		MAX_FAILURE_PER_IP:      1 << 20,
+		WINDOW:                  time.Minute,
+	}
+}
+
+func hashTestPassword(t *testing.T, password string) string {
+	t.Helper()
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt.GenerateFromPassword() = %v", err)
+	}
+	return string(hashed)
+}
+
+func newTestUserService(t *testing.T) (*userServiceImpl, *fakeUserRepository, *fakeTotpRepository, *fakeLockoutRepository) {
+	userRepo := &fakeUserRepository{users: map[string]*model.User{
+		"owner": {Id: 1, Username: "owner", Password: hashTestPassword(t, "current-password")},
+	}}
+	totpRepo := &fakeTotpRepository{enabled: map[uint64]bool{}}
+	lockoutRepo := &fakeLockoutRepository{failures: map[string]int64{}, locks: map[string]time.Duration{}}
+	u := &userServiceImpl{
+		userRepo:   userRepo,
+		tokenRepo:  &fakeTokenRepository{},
+		totpRepo:   totpRepo,
+		lockout:    newTestLockout(lockoutRepo),
+		loginCache: newLoginCache(time.Minute, time.Minute),
+		errWarpper: dtoError.GetServiceErrorWarpper(),
+		logger:     logger.NewInfoLogger(),
+	}
+	return u, userRepo, totpRepo, lockoutRepo
+}
+
+func TestDeviceLoginStalePasswordCountedOnce(t *testing.T) {
+	u, _, _, lockoutRepo := newTestUserService(t)
+	req := &dto.UserLoginRequest{Username: "owner", Password: "old-password", Ip: "10.0.0.2"}
+	for i := 0; i < 10; i++ {
+		_, serviceErr := u.DeviceLoginService(context.Background(), req)
+		if serviceErr == nil || serviceErr.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("DeviceLoginService() = %v, want a login failure", serviceErr)
+		}
+	}
+	if got := lockoutRepo.accountFailures("owner"); got != 1 {
+		t.Fatalf("account failures = %d, want the retries of one stale password counted once", got)
+	}
+
+	// a different wrong password is a new guess and counts again
+	u.DeviceLoginService(context.Background(), &dto.UserLoginRequest{Username: "owner", Password: "guess", Ip: "10.0.0.2"})
+	if got := lockoutRepo.accountFailures("owner"); got != 2 {
+		t.Fatalf("account failures = %d, want 2", got)
+	}
+}
+
+func TestDeviceLoginCachesVerifiedPassword(t *testing.T) {
+	u, userRepo, totpRepo, lockoutRepo := newTestUserService(t)
+	req := &dto.UserLoginRequest{Username: "owner", Password: "current-password"}
+	for i := 0; i < 5; i++ {
+		res, serviceErr := u.DeviceLoginService(context.Background(), req)
+		if serviceErr != nil || res.ID != 1 {
+			t.Fatalf("DeviceLoginService() = %+v, %v, want user 1", res, serviceErr)
+		}
+	}
+	if lockoutRepo.clears != 1 {
+		t.Fatalf("full logins = %d, want the password verified once", lockoutRepo.clears)
+	}
+
+	// enabling two-factor authentication stops the cached verdict
+	totpRepo.enabled[1] = true
+	res, serviceErr := u.DeviceLoginService(context.Background(), req)
+	if serviceErr != nil || res.Challenge == "" {
+		t.Fatalf("DeviceLoginService() = %+v, %v, want a challenge", res, serviceErr)
+	}
+	totpRepo.enabled[1] = false
+
+	// a password change invalidates both the old verdict and an earlier failure of the new password
+	u.DeviceLoginService(context.Background(), &dto.UserLoginRequest{Username: "owner", Password: "new-password"})
+	userRepo.users["owner"] = &model.User{Id: 1, Username: "owner", Password: hashTestPassword(t, "new-password")}
+	if _, serviceErr := u.DeviceLoginService(context.Background(), req); serviceErr == nil {
+		t.Fatal("DeviceLoginService() accepted the old password after a change")
+	}
+	if _, serviceErr := u.DeviceLoginService(context.Background(), &dto.UserLoginRequest{Username: "owner", Password: "new-password"}); serviceErr != nil {
+		t.Fatalf("DeviceLoginService() = %v, want the new password accepted", serviceErr)
+	}
+}
+
+func TestDeviceLoginUnknownUser(t *testing.T) {
+	u, userRepo, _, lockoutRepo := newTestUserService(t)
+	req := &dto.UserLoginRequest{Username: "nobody", Password: "secret-password"}
+	for i := 0; i < 3; i++ {
+		if _, serviceErr := u.DeviceLoginService(context.Background(), req); serviceErr == nil {
+			t.Fatal("DeviceLoginService() accepted an unknown user")
+		}
+	}
+	if got := lockoutRepo.accountFailures("nobody"); got != 1 {
+		t.Fatalf("account failures = %d, want 1", got)
+	}
+
+	userRepo.users["nobody"] = &model.User{Id: 2, Username: "nobody", Password: hashTestPassword(t, "secret-password")}
+	if _, serviceErr := u.DeviceLoginService(context.Background(), req); serviceErr != nil {
+		t.Fatalf("DeviceLoginService() = %v, want the registered user accepted", serviceErr)
+	}
+}
+
+func TestResetPasswordLockedOut(t *testing.T) {
+	u, _, _, lockoutRepo := newTestUserService(t)
+	lockoutRepo.locks[accountLockSubject("owner")] = time.Minute
+	serviceErr := u.ResetPasswordService(context.Background(), &dto.ResetPasswordRequest{
+		Username:    "owner",
+		Password:    "current-password",
+		NewPassword: "next-password",
+		Ip:          "10.0.0.5",
+	})
+	if serviceErr == nil || serviceErr.RetryAfter == 0 {
+		t.Fatalf("ResetPasswordService() = %v, want the lockout", serviceErr)
+	}
+}
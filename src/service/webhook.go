@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"device-communication/src/common"
+	"device-communication/src/config"
+	"device-communication/src/dto"
+	"device-communication/src/dtoError"
+	logger "device-communication/src/log"
+	"device-communication/src/model"
+	"device-communication/src/repository"
+	"device-communication/src/telemetry"
+	"device-communication/src/webhook"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type WebhookService interface {
+	CreateWebhook(ctx context.Context, req *dto.CreateWebhookRequest) (*dto.CreateWebhookResponse, *dtoError.ServiceError)
+	GetWebhooks(ctx context.Context, req *dto.GetWebhooksRequest) (*dto.GetWebhooksResponse, *dtoError.ServiceError)
+	DeleteWebhook(ctx context.Context, req *dto.DeleteWebhookRequest) (*dto.DeleteWebhookResponse, *dtoError.ServiceError)
+	GetWebhookDeliveries(ctx context.Context, req *dto.GetWebhookDeliveriesRequest) (*dto.GetWebhookDeliveriesResponse, *dtoError.ServiceError)
+	RedeliverWebhook(ctx context.Context, req *dto.RedeliverWebhookRequest) (*dto.RedeliverWebhookResponse, *dtoError.ServiceError)
+}
+
+type webhookServiceImpl struct {
+	webhookRepo           repository.WebhookRepository
+	dispatcher            webhook.Dispatcher
+	errWarpper            dtoError.ServiceErrorWarpper
+	MAX_WEBHOOK_COUNT     int64
+	MAX_DELIVERY_NUMBER   int
+	ALLOW_PRIVATE_ADDRESS bool
+	logger                logger.Logger
+}
+
+var webhookService WebhookService
+
+func init() {
+	webhookService = &webhookServiceImpl{
+		webhookRepo:           repository.GetWebhookRepository(),
+		dispatcher:            webhook.GetDispatcher(),
+		errWarpper:            dtoError.GetServiceErrorWarpper(),
+		MAX_WEBHOOK_COUNT:     5,
+		MAX_DELIVERY_NUMBER:   100,
+		ALLOW_PRIVATE_ADDRESS: config.GlobalConfig.YamlConfig.Webhook.AllowPrivateAddress,
+		logger:                logger.NewInfoLogger(),
+	}
+}
+
+func GetWebhookService() WebhookService {
+	return webhookService
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (w *webhookServiceImpl) CreateWebhook(ctx context.Context, req *dto.CreateWebhookRequest) (*dto.CreateWebhookResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "WebhookService.CreateWebhook")
+	defer span.End()
+	data := map[string]any{"user_id": req.UserId, "url": req.Url, "events": req.Events}
+	for _, event := range req.Events {
+		if !webhook.IsValidEvent(event) {
+			return nil, w.errWarpper.NewParseParametersFailedError(fmt.Errorf("unknown webhook event %s", event))
+		}
+	}
+
+	if err := webhook.CheckUrl(ctx, req.Url, w.ALLOW_PRIVATE_ADDRESS); err != nil {
+		w.logger.Info(common.GetUUID(ctx), "webhook.CheckUrl", data, err)
+		return nil, w.errWarpper.NewWebhookUrlNotAllowedError(err)
+	}
+
+	count, err := w.webhookRepo.GetWebhookCount(ctx, req.UserId)
+	if err != nil {
+		w.logger.Error(common.GetUUID(ctx), "w.webhookRepo.GetWebhookCount", data, err)
+		return nil, w.errWarpper.NewDBServiceError(err)
+	} else if count >= w.MAX_WEBHOOK_COUNT {
+		w.logger.Info(common.GetUUID(ctx), "w.webhookRepo.GetWebhookCount", data, nil)
+		return nil, w.errWarpper.NewWebhookTooManyError(w.MAX_WEBHOOK_COUNT)
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		w.logger.Error(common.GetUUID(ctx), "newWebhookSecret", data, err)
+		return nil, w.errWarpper.NewDBServiceError(err)
+	}
+
+	hook, err := w.webhookRepo.CreateWebhook(ctx, req.UserId, req.Url, secret, strings.Join(req.Events, ","))
+	if err != nil {
+		w.logger.Error(common.GetUUID(ctx), "w.webhookRepo.CreateWebhook", data, err)
+		return nil, w.errWarpper.NewDBServiceError(err)
+	}
+
+	w.dispatcher.Invalidate(req.UserId)
+	w.logger.Info(common.GetUUID(ctx), "CreateWebhook.end", data, nil)
+	return &dto.CreateWebhookResponse{
+		WebhookId: hook.Id,
+		Secret:    secret,
+	}, nil
+}
+
+func (w *webhookServiceImpl) GetWebhooks(ctx context.Context, req *dto.GetWebhooksRequest) (*dto.GetWebhooksResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "WebhookService.GetWebhooks")
+	defer span.End()
+	hooks, err := w.webhookRepo.GetWebhooksByUserId(ctx, req.UserId)
+	if err != nil {
+		w.logger.Error(common.GetUUID(ctx), "w.webhookRepo.GetWebhooksByUserId", req, err)
+		return nil, w.errWarpper.NewDBServiceError(err)
+	}
+
+	response := &dto.GetWebhooksResponse{
+		Webhooks: make([]*dto.Webhook, 0, len(hooks)),
+	}
+	for _, hook := range hooks {
+		response.Webhooks = append(response.Webhooks, &dto.Webhook{
+			Id:         hook.Id,
+			Url:        hook.Url,
+			Events:     strings.Split(hook.Events, ","),
+			CreateTime: hook.CreatedAt,
+		})
+	}
+	return response, nil
+}
+
+func (w *webhookServiceImpl) DeleteWebhook(ctx context.Context, req *dto.DeleteWebhookRequest) (*dto.DeleteWebhookResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "WebhookService.DeleteWebhook")
+	defer span.End()
+	ok, err := w.webhookRepo.DeleteWebhook(ctx, req.UserId, req.WebhookId)
+	if err != nil {
+		w.logger.Error(common.GetUUID(ctx), "w.webhookRepo.DeleteWebhook", req, err)
+		return nil, w.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		w.logger.Info(common.GetUUID(ctx), "w.webhookRepo.DeleteWebhook", req, nil)
+		return nil, w.errWarpper.NewWebhookNotFoundError()
+	}
+
+	w.dispatcher.Invalidate(req.UserId)
+	w.logger.Info(common.GetUUID(ctx), "DeleteWebhook.end", req, nil)
+	return &dto.DeleteWebhookResponse{Ok: true}, nil
+}
+
+func (w *webhookServiceImpl) GetWebhookDeliveries(ctx context.Context, req *dto.GetWebhookDeliveriesRequest) (*dto.GetWebhookDeliveriesResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "WebhookService.GetWebhookDeliveries")
+	defer span.End()
+	deliveries, err := w.webhookRepo.GetDeliveriesByUserId(ctx, req.UserId, req.WebhookId, req.Status, w.MAX_DELIVERY_NUMBER)
+	if err != nil {
+		w.logger.Error(common.GetUUID(ctx), "w.webhookRepo.GetDeliveriesByUserId", req, err)
+		return nil, w.errWarpper.NewDBServiceError(err)
+	}
+
+	response := &dto.GetWebhookDeliveriesResponse{
+		Deliveries: make([]*dto.WebhookDelivery, 0, len(deliveries)),
+	}
+	for _, delivery := range deliveries {
+		response.Deliveries = append(response.Deliveries, toWebhookDeliveryDto(delivery))
+	}
+	return response, nil
+}
+
+func (w *webhookServiceImpl) RedeliverWebhook(ctx context.Context, req *dto.RedeliverWebhookRequest) (*dto.RedeliverWebhookResponse, *dtoError.ServiceError) {
+	ctx, span := telemetry.Start(ctx, "WebhookService.RedeliverWebhook")
+	defer span.End()
+	ok, err := w.webhookRepo.ResetDelivery(ctx, req.UserId, req.DeliveryId, time.Now())
+	if err != nil {
+		w.logger.Error(common.GetUUID(ctx), "w.webhookRepo.ResetDelivery", req, err)
+		return nil, w.errWarpper.NewDBServiceError(err)
+	} else if !ok {
+		w.logger.Info(common.GetUUID(ctx), "w.webhookRepo.ResetDelivery", req, nil)
+		return nil, w.errWarpper.NewWebhookDeliveryNotFoundError()
+	}
+
+	w.logger.Info(common.GetUUID(ctx), "RedeliverWebhook.end", req, nil)
+	return &dto.RedeliverWebhookResponse{Ok: true}, nil
+}
+
+func toWebhookDeliveryDto(delivery *model.WebhookDelivery) *dto.WebhookDelivery {
+	return &dto.WebhookDelivery{
+		Id:          delivery.Id,
+		WebhookId:   delivery.WebhookId,
+		Event:       delivery.Event,
+		Payload:     delivery.Payload,
+		Status:      delivery.Status,
+		Attempts:    delivery.Attempts,
+		LastError:   delivery.LastError,
+		NextRetryAt: delivery.NextRetryAt,
+		CreateTime:  delivery.CreatedAt,
+		UpdateTime:  delivery.UpdatedAt,
+	}
+}
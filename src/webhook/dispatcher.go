@@ -0,0 +1,314 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"device-communication/src/config"
+	logger "device-communication/src/log"
+	"device-communication/src/model"
+	"device-communication/src/repository"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultPollInterval = 5 * time.Second
+
+const (
+	EventMainDeviceMessage    = "main_device.message"
+	EventMainDeviceConnect    = "main_device.connect"
+	EventMainDeviceDisconnect = "main_device.disconnect"
+	EventSubDeviceConnect     = "sub_device.connect"
+	EventSubDeviceDisconnect  = "sub_device.disconnect"
+	EventMainDeviceBind       = "main_device.bind"
+	EventMainDeviceUnbind     = "main_device.unbind"
+	EventSubDeviceBind        = "sub_device.bind"
+	EventSubDeviceUnbind      = "sub_device.unbind"
+	EventAll                  = "*"
+)
+
+var events = map[string]bool{
+	EventMainDeviceMessage:    true,
+	EventMainDeviceConnect:    true,
+	EventMainDeviceDisconnect: true,
+	EventSubDeviceConnect:     true,
+	EventSubDeviceDisconnect:  true,
+	EventMainDeviceBind:       true,
+	EventMainDeviceUnbind:     true,
+	EventSubDeviceBind:        true,
+	EventSubDeviceUnbind:      true,
+	EventAll:                  true,
+}
+
+func IsValidEvent(event string) bool {
+	return events[event]
+}
+
+func Subscribed(hook *model.Webhook, event string) bool {
+	for _, e := range strings.Split(hook.Events, ",") {
+		if e == event || e == EventAll {
+			return true
+		}
+	}
+	return false
+}
+
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type Dispatcher interface {
+	Publish(userId uint64, event string, data any)
+	Invalidate(userId uint64)
+}
+
+type event struct {
+	userId    uint64
+	name      string
+	data      any
+	createdAt time.Time
+}
+
+type payload struct {
+	Event      string    `json:"event"`
+	UserId     uint64    `json:"user_id"`
+	CreateTime time.Time `json:"create_time"`
+	Data       any       `json:"data"`
+}
+
+type cachedWebhooks struct {
+	hooks    []*model.Webhook
+	expireAt time.Time
+}
+
+type dispatcherImpl struct {
+	webhookRepo  repository.WebhookRepository
+	client       *http.Client
+	logger       logger.Logger
+	events       chan *event
+	deliveries   chan *model.WebhookDelivery
+	cache        map[uint64]*cachedWebhooks
+	cacheMu      sync.Mutex
+	cacheTTL     time.Duration
+	maxAttempts  int
+	backoffBase  time.Duration
+	backoffMax   time.Duration
+	lease        time.Duration
+	pollInterval time.Duration
+}
+
+var dispatcher Dispatcher
+
+func init() {
+	w := config.GlobalConfig.YamlConfig.Webhook
+	pollInterval := time.Duration(w.PollInterval) * time.Second
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+	d := &dispatcherImpl{
+		webhookRepo:  repository.GetWebhookRepository(),
+		client:       newClient(time.Duration(w.Timeout)*time.Second, w.AllowPrivateAddress),
+		logger:       logger.NewInfoLogger(),
+		events:       make(chan *event, 1024),
+		deliveries:   make(chan *model.WebhookDelivery, 1024),
+		cache:        make(map[uint64]*cachedWebhooks),
+		cacheTTL:     30 * time.Second,
+		maxAttempts:  w.MaxAttempts,
+		backoffBase:  time.Duration(w.BackoffBase) * time.Second,
+		backoffMax:   time.Duration(w.BackoffMax) * time.Second,
+		lease:        time.Duration(w.Timeout)*time.Second + pollInterval,
+		pollInterval: pollInterval,
+	}
+
+	go d.dispatchLoop()
+	go d.pollLoop()
+	for i := 0; i < w.Workers; i++ {
+		go d.deliverLoop()
+	}
+	dispatcher = d
+}
+
+func GetDispatcher() Dispatcher {
+	return dispatcher
+}
+
+func (d *dispatcherImpl) Publish(userId uint64, name string, data any) {
+	e := &event{userId: userId, name: name, data: data, createdAt: time.Now()}
+	select {
+	case d.events <- e:
+	default:
+		d.logger.Warning("", "dispatcher.Publish", map[string]any{"user_id": userId, "event": name}, errors.New("webhook event queue is full"))
+	}
+}
+
+func (d *dispatcherImpl) Invalidate(userId uint64) {
+	d.cacheMu.Lock()
+	defer d.cacheMu.Unlock()
+	delete(d.cache, userId)
+}
+
+func (d *dispatcherImpl) getWebhooks(userId uint64) ([]*model.Webhook, error) {
+	d.cacheMu.Lock()
+	cached, ok := d.cache[userId]
+	d.cacheMu.Unlock()
+	if ok && time.Now().Before(cached.expireAt) {
+		return cached.hooks, nil
+	}
+
+	hooks, err := d.webhookRepo.GetWebhooksByUserId(context.Background(), userId)
+	if err != nil {
+		return nil, err
+	}
+
+	d.cacheMu.Lock()
+	d.cache[userId] = &cachedWebhooks{hooks: hooks, expireAt: time.Now().Add(d.cacheTTL)}
+	d.cacheMu.Unlock()
+	return hooks, nil
+}
+
+func (d *dispatcherImpl) dispatchLoop() {
+	for e := range d.events {
+		hooks, err := d.getWebhooks(e.userId)
+		if err != nil {
+			d.logger.Error("", "d.getWebhooks", map[string]any{"user_id": e.userId, "event": e.name}, err)
+			continue
+		}
+
+		for _, hook := range hooks {
+			if !Subscribed(hook, e.name) {
+				continue
+			}
+			d.enqueue(hook, e)
+		}
+	}
+}
+
+func (d *dispatcherImpl) enqueue(hook *model.Webhook, e *event) {
+	body, err := json.Marshal(&payload{
+		Event:      e.name,
+		UserId:     e.userId,
+		CreateTime: e.createdAt,
+		Data:       e.data,
+	})
+	if err != nil {
+		d.logger.Error("", "json.Marshal", e.name, err)
+		return
+	}
+
+	delivery, err := d.webhookRepo.CreateDelivery(context.Background(), hook.Id, e.name, string(body), time.Now().Add(d.lease))
+	if err != nil {
+		d.logger.Error("", "d.webhookRepo.CreateDelivery", e.name, err)
+		return
+	}
+
+	select {
+	case d.deliveries <- delivery:
+	default:
+		// the lease expires and pollLoop picks the delivery up
+	}
+}
+
+func (d *dispatcherImpl) pollLoop() {
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		now := time.Now()
+		deliveries, err := d.webhookRepo.LeaseDueDeliveries(context.Background(), now, now.Add(d.lease), cap(d.deliveries))
+		if err != nil {
+			d.logger.Error("", "d.webhookRepo.LeaseDueDeliveries", nil, err)
+			continue
+		}
+		for _, delivery := range deliveries {
+			d.deliveries <- delivery
+		}
+	}
+}
+
+func (d *dispatcherImpl) deliverLoop() {
+	for delivery := range d.deliveries {
+		d.deliver(delivery)
+	}
+}
+
+func (d *dispatcherImpl) deliver(delivery *model.WebhookDelivery) {
+	ctx := context.Background()
+	hook, ok, err := d.webhookRepo.GetWebhook(ctx, delivery.WebhookId)
+	if err != nil {
+		d.logger.Error("", "d.webhookRepo.GetWebhook", delivery.Id, err)
+		return
+	} else if !ok {
+		// the webhook is gone, the delivery would be leased again forever
+		delivery.Status = model.WebhookDeliveryStatusDead
+		delivery.LastError = "webhook deleted"
+		if err := d.webhookRepo.UpdateDelivery(ctx, delivery); err != nil {
+			d.logger.Error("", "d.webhookRepo.UpdateDelivery", delivery.Id, err)
+		}
+		return
+	}
+
+	delivery.Attempts++
+	err = d.send(hook, delivery)
+	if err == nil {
+		delivery.Status = model.WebhookDeliveryStatusSucceeded
+		delivery.LastError = ""
+	} else if delivery.Attempts >= d.maxAttempts {
+		delivery.Status = model.WebhookDeliveryStatusDead
+		delivery.LastError = err.Error()
+		d.logger.Warning("", "d.send", map[string]any{"delivery_id": delivery.Id, "attempts": delivery.Attempts}, err)
+	} else {
+		delivery.Status = model.WebhookDeliveryStatusPending
+		delivery.LastError = err.Error()
+		delivery.NextRetryAt = time.Now().Add(d.backoff(delivery.Attempts))
+	}
+
+	if err := d.webhookRepo.UpdateDelivery(ctx, delivery); err != nil {
+		d.logger.Error("", "d.webhookRepo.UpdateDelivery", delivery.Id, err)
+	}
+}
+
+func (d *dispatcherImpl) backoff(attempts int) time.Duration {
+	backoff := d.backoffBase
+	for i := 1; i < attempts && backoff < d.backoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > d.backoffMax {
+		backoff = d.backoffMax
+	}
+	return backoff
+}
+
+func (d *dispatcherImpl) send(hook *model.Webhook, delivery *model.WebhookDelivery) error {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequest(http.MethodPost, hook.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatUint(delivery.Id, 10))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", Sign(hook.Secret, timestamp, body))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook receiver responded %d", res.StatusCode)
+	}
+	return nil
+}
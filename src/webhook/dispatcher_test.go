@@ -0,0 +1,164 @@
+package webhook
+
+import (
+	"context"
+	"device-communication/src/model"
+	"device-communication/src/repository"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	logger "device-communication/src/log"
+)
+
+type fakeWebhookRepository struct {
+	repository.WebhookRepository
+	hooks   map[uint64]*model.Webhook
+	updated []model.WebhookDelivery
+}
+
+func (f *fakeWebhookRepository) GetWebhook(ctx context.Context, webhookId uint64) (*model.Webhook, bool, error) {
+	hook, ok := f.hooks[webhookId]
+	return hook, ok, nil
+}
+
+func (f *fakeWebhookRepository) UpdateDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	f.updated = append(f.updated, *delivery)
+	return nil
+}
+
+func newTestDispatcher(repo repository.WebhookRepository) *dispatcherImpl {
+	return &dispatcherImpl{
+		webhookRepo: repo,
+		client:      newClient(time.Second, true),
+		logger:      logger.NewInfoLogger(),
+		maxAttempts: 3,
+		backoffBase: 5 * time.Second,
+		backoffMax:  time.Minute,
+	}
+}
+
+func TestSign(t *testing.T) {
+	got := Sign("secret", "1700000000", []byte(`{"event":"main_device.connect"}`))
+	want := "sha256=59cf0a041e7196248810b7140414937c37327dccfba4d69bdea34b65566a2b92"
+	if got != want {
+		t.Fatalf("Sign() = %s, want %s", got, want)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := newTestDispatcher(nil)
+	cases := map[int]time.Duration{
+		1:  5 * time.Second,
+		2:  10 * time.Second,
+		3:  20 * time.Second,
+		4:  40 * time.Second,
+		5:  time.Minute,
+		30: time.Minute,
+	}
+	for attempts, want := range cases {
+		if got := d.backoff(attempts); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestDeliverTransitions(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusInternalServerError)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp := r.Header.Get("X-Webhook-Timestamp")
+		if r.Header.Get("X-Webhook-Signature") != Sign("secret", timestamp, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	repo := &fakeWebhookRepository{hooks: map[uint64]*model.Webhook{
+		1: {Id: 1, Url: server.URL, Secret: "secret"},
+	}}
+	d := newTestDispatcher(repo)
+	delivery := &model.WebhookDelivery{Id: 10, WebhookId: 1, Event: EventMainDeviceConnect, Payload: `{}`}
+
+	before := time.Now()
+	d.deliver(delivery)
+	got := repo.updated[len(repo.updated)-1]
+	if got.Status != model.WebhookDeliveryStatusPending || got.Attempts != 1 || !strings.Contains(got.LastError, "500") {
+		t.Fatalf("first failure = %+v, want pending with 1 attempt", got)
+	}
+	if retry := got.NextRetryAt.Sub(before); retry < 5*time.Second || retry > 6*time.Second {
+		t.Fatalf("next retry in %s, want the base backoff", retry)
+	}
+
+	d.deliver(delivery)
+	d.deliver(delivery)
+	got = repo.updated[len(repo.updated)-1]
+	if got.Status != model.WebhookDeliveryStatusDead || got.Attempts != 3 {
+		t.Fatalf("after max attempts = %+v, want dead", got)
+	}
+
+	status.Store(http.StatusOK)
+	retried := &model.WebhookDelivery{Id: 11, WebhookId: 1, Event: EventMainDeviceConnect, Payload: `{}`, Attempts: 1, LastError: "timeout"}
+	d.deliver(retried)
+	got = repo.updated[len(repo.updated)-1]
+	if got.Status != model.WebhookDeliveryStatusSucceeded || got.LastError != "" || got.Attempts != 2 {
+		t.Fatalf("success = %+v, want succeeded", got)
+	}
+}
+
+func TestDeliverDeletedWebhook(t *testing.T) {
+	repo := &fakeWebhookRepository{hooks: map[uint64]*model.Webhook{}}
+	d := newTestDispatcher(repo)
+	d.deliver(&model.WebhookDelivery{Id: 12, WebhookId: 2, Payload: `{}`})
+	if len(repo.updated) != 1 || repo.updated[0].Status != model.WebhookDeliveryStatusDead {
+		t.Fatalf("updated = %+v, want the delivery marked dead", repo.updated)
+	}
+}
+
+func TestCheckUrl(t *testing.T) {
+	cases := map[string]bool{
+		"http://127.0.0.1:8080/hook":         false,
+		"http://10.1.2.3/hook":               false,
+		"http://192.168.0.10/hook":           false,
+		"http://169.254.169.254/latest":      false,
+		"http://100.64.0.1/hook":             false,
+		"http://[::1]/hook":                  false,
+		"http://[::ffff:127.0.0.1]/hook":     false,
+		"http://[fd00::1]/hook":              false,
+		"ftp://93.184.216.34/hook":           false,
+		"https://93.184.216.34/hook":         true,
+		"http://[2606:4700:4700::1111]/hook": true,
+	}
+	for url, allowed := range cases {
+		err := CheckUrl(context.Background(), url, false)
+		if (err == nil) != allowed {
+			t.Errorf("CheckUrl(%s) = %v, want allowed %v", url, err, allowed)
+		}
+	}
+	if err := CheckUrl(context.Background(), "http://127.0.0.1/hook", true); err != nil {
+		t.Errorf("CheckUrl with allowPrivate = %v", err)
+	}
+}
+
+func TestClientRefusesPrivateAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	_, err := newClient(time.Second, false).Get(server.URL)
+	if !errors.Is(err, ErrAddressNotAllowed) {
+		t.Fatalf("Get() = %v, want %v", err, ErrAddressNotAllowed)
+	}
+	res, err := newClient(time.Second, true).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() with allowPrivate = %v", err)
+	}
+	res.Body.Close()
+}
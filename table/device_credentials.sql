@@ -0,0 +1,15 @@
+CREATE TABLE public.device_credentials (
+	id bigserial NOT NULL,
+	user_id int8 NOT NULL,
+	main_device_id int8 NOT NULL,
+	sub_device_id int8 NOT NULL DEFAULT 0,
+	token_hash varchar NOT NULL,
+	token_prefix varchar NOT NULL,
+	last_used_time timestamptz NULL,
+	revoke_time timestamptz NULL,
+	create_time timestamptz NOT NULL,
+	update_time timestamptz NOT NULL,
+	CONSTRAINT device_credentials_pkey PRIMARY KEY (id),
+	CONSTRAINT device_credentials_token_hash_unique UNIQUE (token_hash)
+);
+CREATE INDEX idx_device_credentials_device ON public.device_credentials USING btree (user_id, main_device_id, sub_device_id);
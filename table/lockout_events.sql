@@ -0,0 +1,12 @@
+CREATE TABLE public.lockout_events (
+	id bigserial NOT NULL,
+	username varchar NOT NULL,
+	ip varchar NOT NULL,
+	"event" varchar NOT NULL,
+	locked_until timestamptz NULL,
+	"operator" varchar NOT NULL,
+	create_time timestamptz NOT NULL,
+	update_time timestamptz NOT NULL,
+	CONSTRAINT lockout_events_pkey PRIMARY KEY (id)
+);
+CREATE INDEX idx_lockout_events_username ON public.lockout_events USING btree (username, create_time);
@@ -0,0 +1,12 @@
+CREATE TABLE public.message_schemas (
+	id bigserial NOT NULL,
+	user_id int8 NOT NULL,
+	platform varchar NOT NULL,
+	"version" varchar NOT NULL,
+	"schema" text NOT NULL,
+	policy varchar NOT NULL,
+	create_time timestamptz NOT NULL,
+	update_time timestamptz NOT NULL,
+	CONSTRAINT message_schemas_pkey PRIMARY KEY (id),
+	CONSTRAINT message_schemas_unique UNIQUE (user_id, platform, version)
+);
@@ -0,0 +1,11 @@
+CREATE TABLE public.recovery_codes (
+	id bigserial NOT NULL,
+	user_id int8 NOT NULL,
+	code_hash varchar NOT NULL,
+	used_time timestamptz NULL,
+	create_time timestamptz NOT NULL,
+	update_time timestamptz NOT NULL,
+	CONSTRAINT recovery_codes_pkey PRIMARY KEY (id),
+	CONSTRAINT recovery_codes_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE
+);
+CREATE INDEX idx_recovery_codes_user_id ON public.recovery_codes USING btree (user_id, code_hash);
@@ -0,0 +1,13 @@
+CREATE TABLE public.room_policies (
+	id bigserial NOT NULL,
+	main_device_id int8 NOT NULL,
+	max_sub_device int8 NOT NULL,
+	idle_timeout_second int8 NOT NULL,
+	allowed_frame_types varchar NOT NULL,
+	max_message_byte int8 NOT NULL,
+	create_time timestamptz NOT NULL,
+	update_time timestamptz NOT NULL,
+	CONSTRAINT room_policies_pkey PRIMARY KEY (id),
+	CONSTRAINT room_policies_unique UNIQUE (main_device_id),
+	CONSTRAINT room_policies_main_device_fkey FOREIGN KEY (main_device_id) REFERENCES public.main_devices(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
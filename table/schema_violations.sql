@@ -0,0 +1,13 @@
+CREATE TABLE public.schema_violations (
+	id bigserial NOT NULL,
+	schema_id int8 NOT NULL,
+	user_id int8 NOT NULL,
+	main_device_id int8 NOT NULL,
+	"action" varchar NOT NULL,
+	message text NOT NULL,
+	reason varchar NOT NULL,
+	create_time timestamptz NOT NULL,
+	update_time timestamptz NOT NULL,
+	CONSTRAINT schema_violations_pkey PRIMARY KEY (id)
+);
+CREATE INDEX idx_schema_violations_user_id ON public.schema_violations USING btree (user_id, main_device_id);
@@ -0,0 +1,12 @@
+CREATE TABLE public.user_totps (
+	id bigserial NOT NULL,
+	user_id int8 NOT NULL,
+	secret varchar NOT NULL,
+	enabled bool DEFAULT false NOT NULL,
+	last_used_step int8 DEFAULT 0 NOT NULL,
+	create_time timestamptz NOT NULL,
+	update_time timestamptz NOT NULL,
+	CONSTRAINT user_totps_pkey PRIMARY KEY (id),
+	CONSTRAINT user_totps_user_id_unique UNIQUE (user_id),
+	CONSTRAINT user_totps_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE
+);
@@ -4,6 +4,7 @@ CREATE TABLE public.users (
 	"password" varchar NOT NULL,
 	"name" varchar NOT NULL,
 	email varchar NOT NULL,
+	email_verified bool DEFAULT false NOT NULL,
 	create_time timestamptz NOT NULL,
 	update_time timestamptz NOT NULL,
 	delete_time timestamptz NULL,
@@ -0,0 +1,15 @@
+CREATE TABLE public.webhook_deliveries (
+	id bigserial NOT NULL,
+	webhook_id int8 NOT NULL,
+	"event" varchar NOT NULL,
+	payload text NOT NULL,
+	status varchar NOT NULL,
+	attempts int4 NOT NULL DEFAULT 0,
+	last_error varchar NOT NULL DEFAULT '',
+	next_retry_time timestamptz NOT NULL,
+	create_time timestamptz NOT NULL,
+	update_time timestamptz NOT NULL,
+	CONSTRAINT webhook_deliveries_pkey PRIMARY KEY (id),
+	CONSTRAINT webhook_deliveries_webhook_fkey FOREIGN KEY (webhook_id) REFERENCES public.webhooks(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+CREATE INDEX idx_webhook_deliveries_status ON public.webhook_deliveries USING btree (status, next_retry_time);
@@ -0,0 +1,11 @@
+CREATE TABLE public.webhooks (
+	id bigserial NOT NULL,
+	user_id int8 NOT NULL,
+	url varchar NOT NULL,
+	secret varchar NOT NULL,
+	events varchar NOT NULL,
+	create_time timestamptz NOT NULL,
+	update_time timestamptz NOT NULL,
+	CONSTRAINT webhooks_pkey PRIMARY KEY (id)
+);
+CREATE INDEX idx_webhooks_user_id ON public.webhooks USING btree (user_id);